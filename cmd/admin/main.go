@@ -0,0 +1,171 @@
+// Command admin manages admins and feature grants:
+//
+//	admin grant -email user@example.com -feature daily_responses -reason "QA account" [-days 30] [-value 100]
+//	admin revoke -email user@example.com -feature daily_responses
+//	admin promote -email staff@example.com
+//	admin demote -email staff@example.com
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/config"
+	"datingapp/internal/repository"
+	"datingapp/internal/service"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage:
+  admin grant -email EMAIL -feature NAME -reason REASON [-days N] [-value N]
+  admin revoke -email EMAIL -feature NAME
+  admin promote -email EMAIL
+  admin demote -email EMAIL`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	db, err := sqlx.Connect("postgres", cfg.DBConfig.DSN())
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewRepository(db)
+	// Grants and revocations make no payments, so no provider is needed.
+	featureSvc := service.NewFeatureService(repo, nil, cfg.DefaultCurrency, cfg.PaymentWebhookSecret, cfg.SubscriptionGracePeriod, cfg.BoostPrice)
+
+	ctx := context.Background()
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "grant":
+		err = grant(ctx, repo, featureSvc, args)
+	case "revoke":
+		err = revoke(ctx, repo, featureSvc, args)
+	case "promote":
+		err = setAdmin(ctx, repo, args, true)
+	case "demote":
+		err = setAdmin(ctx, repo, args, false)
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func grant(ctx context.Context, repo repository.Repository, featureSvc internal.FeatureService, args []string) error {
+	flags := flag.NewFlagSet("grant", flag.ExitOnError)
+	email := flags.String("email", "", "email of the user to grant the feature to")
+	featureName := flags.String("feature", "", "name of the feature to grant")
+	reason := flags.String("reason", "", "why the feature is granted")
+	days := flags.Int("days", 0, "days until the grant expires, 0 for no expiry")
+	value := flags.Int("value", 0, "entitlement value of the grant")
+	flags.Parse(args) //nolint:errcheck // ExitOnError exits on failure
+
+	if *email == "" || *featureName == "" || *reason == "" {
+		return fmt.Errorf("grant needs -email, -feature and -reason")
+	}
+
+	user, err := repo.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	feature, err := repo.GetFeatureByName(ctx, *featureName)
+	if err != nil {
+		return fmt.Errorf("get feature: %w", err)
+	}
+
+	req := &internal.GrantRequest{
+		UserID:    user.ID,
+		FeatureID: feature.ID,
+		Reason:    *reason,
+	}
+	// Without -value the grant gets the feature's premium quota.
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "value" {
+			req.Value = value
+		}
+	})
+	if *days > 0 {
+		expiresAt := time.Now().AddDate(0, 0, *days)
+		req.ExpiresAt = &expiresAt
+	}
+
+	granted, err := featureSvc.GrantFeature(ctx, req)
+	if err != nil {
+		return fmt.Errorf("grant feature: %w", err)
+	}
+
+	if granted.EndDate != nil {
+		log.Printf("granted %s to %s until %s", feature.Name, user.Email, granted.EndDate.Format(time.RFC3339))
+	} else {
+		log.Printf("granted %s to %s until revoked", feature.Name, user.Email)
+	}
+	return nil
+}
+
+func revoke(ctx context.Context, repo repository.Repository, featureSvc internal.FeatureService, args []string) error {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	email := flags.String("email", "", "email of the user to revoke the grant from")
+	featureName := flags.String("feature", "", "name of the granted feature")
+	flags.Parse(args) //nolint:errcheck // ExitOnError exits on failure
+
+	if *email == "" || *featureName == "" {
+		return fmt.Errorf("revoke needs -email and -feature")
+	}
+
+	user, err := repo.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	feature, err := repo.GetFeatureByName(ctx, *featureName)
+	if err != nil {
+		return fmt.Errorf("get feature: %w", err)
+	}
+
+	if _, err := featureSvc.RevokeGrant(ctx, user.ID, feature.ID); err != nil {
+		return fmt.Errorf("revoke grant: %w", err)
+	}
+
+	log.Printf("revoked %s from %s", feature.Name, user.Email)
+	return nil
+}
+
+func setAdmin(ctx context.Context, repo repository.Repository, args []string, isAdmin bool) error {
+	flags := flag.NewFlagSet("admin", flag.ExitOnError)
+	email := flags.String("email", "", "email of the user")
+	flags.Parse(args) //nolint:errcheck // ExitOnError exits on failure
+
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+
+	user, err := repo.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	if err := repo.SetUserAdmin(ctx, user.ID, isAdmin); err != nil {
+		return fmt.Errorf("set admin: %w", err)
+	}
+
+	log.Printf("set admin=%t for %s", isAdmin, user.Email)
+	return nil
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRSAKey(t *testing.T, dir, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writePEM(t, dir, kid, key)
+}
+
+func writeEd25519Key(t *testing.T, dir, kid string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePEM(t, dir, kid, key)
+}
+
+func writePEM(t *testing.T, dir, kid string, key interface{}) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600))
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"user_id": "123e4567-e89b-12d3-a456-426614174000",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "2024-01")
+	writeEd25519Key(t, dir, "2024-02")
+
+	ks, err := LoadKeySet(dir)
+	require.NoError(t, err)
+
+	signed, err := ks.Sign(testClaims())
+	require.NoError(t, err)
+
+	token, err := jwt.Parse(signed, ks.Keyfunc)
+	require.NoError(t, err)
+	assert.True(t, token.Valid)
+	assert.Equal(t, "2024-02", token.Header["kid"])
+	assert.Equal(t, "EdDSA", token.Header["alg"])
+
+	jwks := ks.JWKS()
+	if assert.Len(t, jwks.Keys, 2) {
+		assert.Equal(t, "RSA", jwks.Keys[0].KeyType)
+		assert.Equal(t, "RS256", jwks.Keys[0].Algorithm)
+		assert.NotEmpty(t, jwks.Keys[0].N)
+		assert.Equal(t, "OKP", jwks.Keys[1].KeyType)
+		assert.Equal(t, "Ed25519", jwks.Keys[1].Curve)
+		assert.NotEmpty(t, jwks.Keys[1].X)
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "2024-01")
+
+	ks, err := LoadKeySet(dir)
+	require.NoError(t, err)
+
+	oldToken, err := ks.Sign(testClaims())
+	require.NoError(t, err)
+
+	writeEd25519Key(t, dir, "2024-02")
+	require.NoError(t, ks.Reload())
+
+	newToken, err := ks.Sign(testClaims())
+	require.NoError(t, err)
+
+	parsed, err := jwt.Parse(newToken, ks.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02", parsed.Header["kid"])
+
+	_, err = jwt.Parse(oldToken, ks.Keyfunc)
+	assert.NoError(t, err, "tokens signed by a previous key verify until it is removed")
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "2024-01.pem")))
+	require.NoError(t, ks.Reload())
+
+	_, err = jwt.Parse(oldToken, ks.Keyfunc)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestKeySet_RejectsAlgorithmMismatch(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "2024-01")
+
+	ks, err := LoadKeySet(dir)
+	require.NoError(t, err)
+
+	// An HS256 token that names the RSA key must not be verified with it.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	token.Header["kid"] = "2024-01"
+	signed, err := token.SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(signed, ks.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeySet_HMAC(t *testing.T) {
+	ks := NewHMACKeySet("test-secret")
+
+	signed, err := ks.Sign(testClaims())
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(signed, ks.Keyfunc)
+	assert.NoError(t, err)
+	assert.Empty(t, ks.JWKS().Keys)
+}
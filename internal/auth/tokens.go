@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const accessTokenTTL = 24 * time.Hour
+
+var ErrInvalidClaims = errors.New("invalid token claims")
+
+// Claims are the claims carried by every token this service issues. Purpose is
+// empty for access tokens and names the flow for purpose-bound tokens such as
+// two-factor challenges, which must never be accepted as access tokens.
+type Claims struct {
+	UserID  string `json:"user_id"`
+	Email   string `json:"email,omitempty"`
+	Purpose string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Tokens issues and validates tokens for a single issuer and audience.
+type Tokens struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+}
+
+func NewTokens(keys *KeySet, issuer, audience string) *Tokens {
+	return &Tokens{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+func (t *Tokens) IssueAccessToken(userID uuid.UUID, email string) (string, error) {
+	return t.issue(userID, email, "", accessTokenTTL)
+}
+
+func (t *Tokens) IssuePurposeToken(userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	return t.issue(userID, "", purpose, ttl)
+}
+
+// ParseAccessToken validates signature, expiry, issuer and audience and
+// requires a user_id. Purpose-bound tokens are rejected.
+func (t *Tokens) ParseAccessToken(tokenString string) (*Claims, error) {
+	return t.parse(tokenString, "")
+}
+
+// ParsePurposeToken is ParseAccessToken for tokens bound to purpose.
+func (t *Tokens) ParsePurposeToken(tokenString, purpose string) (*Claims, error) {
+	return t.parse(tokenString, purpose)
+}
+
+func (t *Tokens) issue(userID uuid.UUID, email, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID:  userID.String(),
+		Email:   email,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.issuer,
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{t.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	signed, err := t.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("sign token: %w", err)
+	}
+
+	return signed, nil
+}
+
+func (t *Tokens) parse(tokenString, purpose string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, t.keys.Keyfunc,
+		jwt.WithIssuer(t.issuer),
+		jwt.WithAudience(t.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != purpose {
+		return nil, ErrInvalidClaims
+	}
+
+	if _, err := uuid.Parse(claims.UserID); err != nil {
+		return nil, ErrInvalidClaims
+	}
+
+	return claims, nil
+}
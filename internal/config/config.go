@@ -2,13 +2,58 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"datingapp/internal"
 )
 
 type Config struct {
 	Port      string
 	DBConfig  DBConfig
 	JWTSecret string
+	// JWTKeyDir holds "<kid>.pem" private keys for RS256/EdDSA signing.
+	// When empty, tokens are signed with JWTSecret using HS256.
+	JWTKeyDir   string
+	JWTIssuer   string
+	JWTAudience string
+	TOTPIssuer  string
+	OIDC        []OIDCProviderConfig
+	// DefaultCurrency prices subscriptions that do not ask for a currency.
+	DefaultCurrency string
+	// PaymentProvider names the provider charging subscriptions. Only "fake"
+	// is available.
+	PaymentProvider string
+	// PaymentWebhookSecret keys the HMAC signature of payment webhooks.
+	PaymentWebhookSecret string
+	// SubscriptionGracePeriod keeps a past due subscription usable while its
+	// renewal payment is retried.
+	SubscriptionGracePeriod time.Duration
+	// FreeDailyResponses is the daily response limit of users without the
+	// daily responses feature.
+	FreeDailyResponses int
+	// FreeWeeklySuperLikes is the weekly super like limit of users without
+	// the super likes feature.
+	FreeWeeklySuperLikes int
+	// RewindWindow is how long after responding a user can rewind.
+	RewindWindow time.Duration
+	// DiscoveryRadiusKm is how far away candidates can be from users who
+	// set a location.
+	DiscoveryRadiusKm int
+	// BoostPrice is the price of a boost in the minor unit of
+	// DefaultCurrency.
+	BoostPrice int64
+}
+
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
 }
 
 type DBConfig struct {
@@ -38,13 +83,78 @@ func Load() (*Config, error) {
 			DBName:   getEnv("DB_NAME", "dating_app_db"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
-		JWTSecret: getEnv("JWT_SECRET", "your-secret-key"),
+		JWTSecret:               getEnv("JWT_SECRET", "your-secret-key"),
+		JWTKeyDir:               getEnv("JWT_KEY_DIR", ""),
+		JWTIssuer:               getEnv("JWT_ISSUER", "datingapp"),
+		JWTAudience:             getEnv("JWT_AUDIENCE", "datingapp-api"),
+		TOTPIssuer:              getEnv("TOTP_ISSUER", "DatingApp"),
+		OIDC:                    loadOIDCProviders(),
+		DefaultCurrency:         getEnv("DEFAULT_CURRENCY", "USD"),
+		PaymentProvider:         getEnv("PAYMENT_PROVIDER", "fake"),
+		PaymentWebhookSecret:    getEnv("PAYMENT_WEBHOOK_SECRET", ""),
+		SubscriptionGracePeriod: getEnvDuration("SUBSCRIPTION_GRACE_PERIOD", 72*time.Hour),
+		FreeDailyResponses:      getEnvInt("FREE_DAILY_RESPONSES", internal.DefaultQuota(internal.FeatureDailyResponses)),
+		FreeWeeklySuperLikes:    getEnvInt("FREE_WEEKLY_SUPER_LIKES", internal.DefaultQuota(internal.FeatureSuperLikes)),
+		RewindWindow:            getEnvDuration("REWIND_WINDOW", 5*time.Minute),
+		DiscoveryRadiusKm:       getEnvInt("DISCOVERY_RADIUS_KM", 100),
+		BoostPrice:              int64(getEnvInt("BOOST_PRICE", 499)),
 	}, nil
 }
 
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS, e.g.
+// "google,apple", each configured by OIDC_<NAME>_ISSUER, _CLIENT_ID,
+// _CLIENT_SECRET and _REDIRECT_URL.
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", ""),
+		})
+	}
+	return providers
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid %s %q, using %s: %v", key, value, defaultValue, err)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid %s %q, using %d: %v", key, value, defaultValue, err)
+		return defaultValue
+	}
+	return n
+}
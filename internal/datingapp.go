@@ -8,16 +8,57 @@ import (
 
 type UserService interface {
 	SignUp(ctx context.Context, user *User, password string) error
-	Login(ctx context.Context, email, password string) (string, error)
+	Login(ctx context.Context, email, password, ip string) (*LoginResult, error)
+	VerifyTwoFactor(ctx context.Context, challengeToken, code string) (string, error)
+	EnrollTOTP(ctx context.Context, userID uuid.UUID) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	StartOAuthLogin(ctx context.Context, provider string) (string, error)
+	CompleteOAuthLogin(ctx context.Context, provider string, callback *OAuthCallback) (*LoginResult, error)
+	SetTimezone(ctx context.Context, userID uuid.UUID, timezone string) error
+	SetIncognito(ctx context.Context, userID uuid.UUID, incognito bool) error
+	SetLocation(ctx context.Context, userID uuid.UUID, location *Location) error
+	SetPassport(ctx context.Context, userID uuid.UUID, location *Location) error
 }
 
 type ProfileService interface {
-	GetProfiles(ctx context.Context, userID uuid.UUID) ([]*User, error)
-	CreateProfileResponse(ctx context.Context, fromUserID, toUserID uuid.UUID, responseType string) error
+	GetProfiles(ctx context.Context, userID uuid.UUID) ([]*Candidate, *Quota, error)
+	CreateProfileResponse(ctx context.Context, fromUserID, toUserID uuid.UUID, responseType string) (*Quota, error)
+	RewindProfileResponse(ctx context.Context, userID uuid.UUID) (*User, error)
+	GetQuota(ctx context.Context, userID uuid.UUID) (*Quota, error)
+	GetNotifications(ctx context.Context, userID uuid.UUID) ([]*Notification, error)
+	GetLikesReceived(ctx context.Context, userID uuid.UUID) (*LikesReceived, error)
 }
 
 type FeatureService interface {
 	GetFeatures(ctx context.Context) ([]*SubscriptionFeature, error)
-	SubscribeToFeature(ctx context.Context, feature *UserFeature, period string) error
+	GetPlans(ctx context.Context) ([]*Plan, error)
+	SubscribeToFeature(ctx context.Context, req *SubscribeRequest) (*UserFeature, error)
 	GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*UserFeature, error)
+	HandlePaymentWebhook(ctx context.Context, payload []byte, signature string) error
+	SetAutoRenew(ctx context.Context, userID, featureID uuid.UUID, autoRenew bool) error
+	CancelSubscription(ctx context.Context, req *CancelRequest) (*CancelResult, error)
+	PreviewPlanChange(ctx context.Context, req *PlanChangeRequest) (*PlanChangePreview, error)
+	ChangePlan(ctx context.Context, req *PlanChangeRequest) (*UserFeature, error)
+	GrantFeature(ctx context.Context, req *GrantRequest) (*UserFeature, error)
+	RevokeGrant(ctx context.Context, userID, featureID uuid.UUID) (*UserFeature, error)
+	ActivateBoost(ctx context.Context, req *BoostRequest) (*Boost, error)
+	GetBoosts(ctx context.Context, userID uuid.UUID) ([]*Boost, error)
+	GetBillingHistory(ctx context.Context, userID uuid.UUID) ([]*Invoice, error)
+	GetReceipt(ctx context.Context, userID, invoiceID uuid.UUID) (*Receipt, error)
+	RenewSubscriptions(ctx context.Context) error
+	RetryRefunds(ctx context.Context) error
+	ReconcilePayments(ctx context.Context) error
+	ExpireSubscriptions(ctx context.Context) error
+}
+
+// PaymentProvider charges users through an external payment service.
+// CreateCheckout returns an authorized payment that must be captured, or a
+// pending or failed one. Refund is idempotent per reference, so a refund
+// whose outcome was lost can be retried.
+type PaymentProvider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, checkout *Checkout) (*PaymentResult, error)
+	Capture(ctx context.Context, providerPaymentID string) (*PaymentResult, error)
+	Refund(ctx context.Context, providerPaymentID string, amount int64, reference string) (*PaymentResult, error)
+	Status(ctx context.Context, providerPaymentID string) (*PaymentResult, error)
 }
@@ -1,6 +1,9 @@
 package internal
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	ErrConflictingResponse           = errors.New("conflicting response")
@@ -11,4 +14,95 @@ var (
 	ErrFeatureNotFound               = errors.New("feature not found")
 	ErrFeatureAlreadySubscribed      = errors.New("feature already subscribed")
 	ErrUserNotFound                  = errors.New("user not found")
+	ErrInvalidTwoFactorCode          = errors.New("invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled       = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnrolled          = errors.New("two-factor authentication not enrolled")
+	ErrInvalidChallengeToken         = errors.New("invalid challenge token")
+	ErrTooManyLoginAttempts          = errors.New("too many login attempts")
+	ErrUnknownOAuthProvider          = errors.New("unknown oauth provider")
+	ErrInvalidOAuthState             = errors.New("invalid or expired oauth state")
+	ErrOAuthEmailNotVerified         = errors.New("oauth email not verified")
+	ErrOAuthIdentityExists           = errors.New("oauth identity already linked")
+	ErrProfileIncomplete             = errors.New("profile incomplete")
+	ErrPlanPriceNotFound             = errors.New("plan price not found")
+	ErrPaymentDeclined               = errors.New("payment declined")
+	ErrPaymentNotFound               = errors.New("payment not found")
+	ErrInvalidWebhookSignature       = errors.New("invalid webhook signature")
+	ErrInvalidWebhookPayload         = errors.New("invalid webhook payload")
+	ErrSubscriptionNotFound          = errors.New("subscription not found")
+	ErrRefundNotFound                = errors.New("refund not found")
+	ErrTrialUnavailable              = errors.New("trial unavailable")
+	ErrTrialAlreadyUsed              = errors.New("trial already used")
+	ErrPromoCodeNotFound             = errors.New("promo code not found")
+	ErrPromoCodeExpired              = errors.New("promo code expired")
+	ErrPromoCodeExhausted            = errors.New("promo code fully redeemed")
+	ErrPromoCodeNotApplicable        = errors.New("promo code not applicable")
+	ErrPromoCodeAlreadyRedeemed      = errors.New("promo code already redeemed")
+	ErrInvoiceNotFound               = errors.New("invoice not found")
+	ErrPlanUnchanged                 = errors.New("plan unchanged")
+	ErrPlanChangeNotAllowed          = errors.New("plan change not allowed")
+	ErrGrantNotFound                 = errors.New("grant not found")
+	ErrInvalidTimezone               = errors.New("invalid timezone")
+	ErrSuperLikeLimitExceeded        = errors.New("weekly super like limit exceeded")
+	ErrNothingToRewind               = errors.New("nothing to rewind")
+	ErrBoostActive                   = errors.New("boost already active")
+	ErrNoBoostsLeft                  = errors.New("no boosts left")
+	ErrBoostNotFound                 = errors.New("boost not found")
+	ErrFeatureRequired               = errors.New("feature required")
 )
+
+// LockoutError reports until when further login attempts are rejected.
+// It matches ErrTooManyLoginAttempts with errors.Is.
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return ErrTooManyLoginAttempts.Error()
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// DailyLimitError reports when the user's daily response limit resets. It
+// matches ErrDailyInteractionLimitExceeded with errors.Is.
+type DailyLimitError struct {
+	ResetAt time.Time
+}
+
+func (e *DailyLimitError) Error() string {
+	return ErrDailyInteractionLimitExceeded.Error()
+}
+
+func (e *DailyLimitError) Unwrap() error {
+	return ErrDailyInteractionLimitExceeded
+}
+
+// SuperLikeLimitError reports when the user's weekly super like limit resets.
+// It matches ErrSuperLikeLimitExceeded with errors.Is.
+type SuperLikeLimitError struct {
+	ResetAt time.Time
+}
+
+func (e *SuperLikeLimitError) Error() string {
+	return ErrSuperLikeLimitExceeded.Error()
+}
+
+func (e *SuperLikeLimitError) Unwrap() error {
+	return ErrSuperLikeLimitExceeded
+}
+
+// FeatureRequiredError reports the feature a request needs but the user does
+// not have. It matches ErrFeatureRequired with errors.Is.
+type FeatureRequiredError struct {
+	Feature string
+}
+
+func (e *FeatureRequiredError) Error() string {
+	return ErrFeatureRequired.Error() + ": " + e.Feature
+}
+
+func (e *FeatureRequiredError) Unwrap() error {
+	return ErrFeatureRequired
+}
@@ -2,16 +2,133 @@ package internal
 
 import (
 	"context"
+	"fmt"
 )
 
 type activeFeaturesKey struct{}
 
 const (
 	FeatureDailyResponses = "daily_responses"
+	FeatureSuperLikes     = "super_likes"
+	FeatureRewind         = "rewind"
+	FeatureLikesReceived  = "likes_received"
+	FeatureBoosts         = "boosts"
+	FeatureIncognito      = "incognito"
+	FeaturePassport       = "passport"
 )
 
+// Unlimited is the quota of a feature without a limit.
+const Unlimited = -1
+
+// FeatureDefinition describes a subscription feature: the quota users get
+// without it and the quota it grants when no value is given. The default
+// quota is only the fallback of its config setting, like FREE_DAILY_RESPONSES.
+type FeatureDefinition struct {
+	Name         string
+	Description  string
+	DefaultQuota int
+	PremiumQuota int
+}
+
+// featureDefinitions is the registry of features the code knows about. Every
+// name must also exist in the subscription_features table.
+var featureDefinitions = map[string]FeatureDefinition{
+	FeatureDailyResponses: {
+		Name:         FeatureDailyResponses,
+		Description:  "Unlimited daily responses",
+		DefaultQuota: 10,
+		PremiumQuota: Unlimited,
+	},
+	FeatureSuperLikes: {
+		Name:         FeatureSuperLikes,
+		Description:  "More super likes every week",
+		DefaultQuota: 1,
+		PremiumQuota: 5,
+	},
+	FeatureRewind: {
+		Name:         FeatureRewind,
+		Description:  "Undo the last response",
+		DefaultQuota: 0,
+		PremiumQuota: Unlimited,
+	},
+	FeatureLikesReceived: {
+		Name:         FeatureLikesReceived,
+		Description:  "See who liked you",
+		DefaultQuota: 0,
+		PremiumQuota: Unlimited,
+	},
+	FeatureBoosts: {
+		Name:         FeatureBoosts,
+		Description:  "Free profile boosts every week",
+		DefaultQuota: 0,
+		PremiumQuota: 1,
+	},
+	FeatureIncognito: {
+		Name:         FeatureIncognito,
+		Description:  "Only be shown to people you liked",
+		DefaultQuota: 0,
+		PremiumQuota: Unlimited,
+	},
+	FeaturePassport: {
+		Name:         FeaturePassport,
+		Description:  "Browse candidates in another city",
+		DefaultQuota: 0,
+		PremiumQuota: Unlimited,
+	},
+}
+
+// LookupFeature returns the definition of the named feature.
+func LookupFeature(name string) (FeatureDefinition, bool) {
+	definition, ok := featureDefinitions[name]
+	return definition, ok
+}
+
+// DefaultQuota returns the quota users get without the named feature when
+// its config setting is unset.
+func DefaultQuota(name string) int {
+	return featureDefinitions[name].DefaultQuota
+}
+
+// FeatureQuota resolves the user's quota for the named feature from the
+// active features in ctx: the most generous of their values, or free when
+// none grants more.
+func FeatureQuota(ctx context.Context, name string, free int) int {
+	if free == Unlimited {
+		return Unlimited
+	}
+
+	features, _ := GetActiveFeatures(ctx)
+	quota := free
+	for _, f := range features {
+		if f.FeatureName != name {
+			continue
+		}
+		if f.Value == Unlimited {
+			return Unlimited
+		}
+		quota = max(quota, f.Value)
+	}
+	return quota
+}
+
+// RequireFeature returns a *FeatureRequiredError unless the named feature is
+// active in ctx. Services check with it when only some requests need the
+// feature, like turning a setting on; routes that always need it declare it
+// with middleware.RequireFeature instead. It panics if the feature is not in
+// the registry.
+func RequireFeature(ctx context.Context, name string) error {
+	if _, ok := LookupFeature(name); !ok {
+		panic(fmt.Sprintf("unknown feature %q", name))
+	}
+
+	if !HasFeature(ctx, name) {
+		return &FeatureRequiredError{Feature: name}
+	}
+	return nil
+}
+
 func HasFeature(ctx context.Context, featureName string) bool {
-	features, ok := ctx.Value("active_features").([]*UserFeature)
+	features, ok := GetActiveFeatures(ctx)
 	if !ok {
 		return false
 	}
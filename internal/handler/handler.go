@@ -1,12 +1,21 @@
 package handler
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
 	"time"
 
 	"datingapp/internal"
+	"datingapp/internal/auth"
+	"datingapp/internal/middleware"
+	"datingapp/internal/oidc"
+	"datingapp/internal/receipt"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -38,6 +47,7 @@ type SignUpRequest struct {
 	Bio             string    `json:"bio"`
 	BirthDate       time.Time `json:"birth_date" validate:"required"`
 	Gender          string    `json:"gender" validate:"required,oneof=male female other"`
+	Timezone        string    `json:"timezone" validate:"omitempty,timezone"`
 }
 
 type LoginRequest struct {
@@ -46,7 +56,32 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token          string `json:"token,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type OAuthCallbackRequest struct {
+	State     string     `json:"state" validate:"required"`
+	Code      string     `json:"code" validate:"required"`
+	BirthDate *time.Time `json:"birth_date"`
+	Gender    string     `json:"gender" validate:"omitempty,oneof=male female other"`
+}
+
+type OAuthAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type ConfirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
 // Custom password validator
@@ -61,6 +96,52 @@ func PasswordValidator(fl validator.FieldLevel) bool {
 	return hasUpper && hasLower && hasNumber && hasSpecial
 }
 
+// principalID returns the ID of the authenticated user set by JWTMiddleware.
+func (h *Handler) principalID(c echo.Context) (uuid.UUID, error) {
+	principal, ok := internal.PrincipalFromContext(c.Request().Context())
+	if !ok {
+		h.log.Errorf("principal missing from request context")
+		return uuid.Nil, echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+	return principal.UserID, nil
+}
+
+// JWKS publishes the public token verification keys so that other services
+// can verify access tokens without sharing a secret.
+func JWKS(keys *auth.KeySet) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+		return c.JSON(http.StatusOK, keys.JWKS())
+	}
+}
+
+// setRetryAfter advertises when a locked out or rate limited client may try
+// again.
+func setRetryAfter(c echo.Context, err error) {
+	var (
+		until      time.Time
+		lockoutErr *internal.LockoutError
+		limitErr   *internal.DailyLimitError
+		superErr   *internal.SuperLikeLimitError
+	)
+	switch {
+	case errors.As(err, &lockoutErr):
+		until = lockoutErr.Until
+	case errors.As(err, &limitErr):
+		until = limitErr.ResetAt
+	case errors.As(err, &superErr):
+		until = superErr.ResetAt
+	default:
+		return
+	}
+
+	seconds := int(math.Ceil(time.Until(until).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
 func (h *Handler) SignUp(c echo.Context) error {
 	var req SignUpRequest
 	if err := c.Bind(&req); err != nil {
@@ -88,6 +169,7 @@ func (h *Handler) SignUp(c echo.Context) error {
 		Bio:       req.Bio,
 		BirthDate: req.BirthDate,
 		Gender:    req.Gender,
+		Timezone:  req.Timezone,
 	}
 
 	if err := h.userSvc.SignUp(c.Request().Context(), user, req.Password); err != nil {
@@ -113,10 +195,13 @@ func (h *Handler) Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	token, err := h.userSvc.Login(c.Request().Context(), req.Email, req.Password)
+	result, err := h.userSvc.Login(c.Request().Context(), req.Email, req.Password, c.RealIP())
 	if err != nil {
 		h.log.Errorf("failed to login: %v", err)
 		switch {
+		case errors.Is(err, internal.ErrTooManyLoginAttempts):
+			setRetryAfter(c, err)
+			return echo.NewHTTPError(http.StatusTooManyRequests, "too many login attempts, try again later")
 		case errors.Is(err, internal.ErrInvalidCredentials):
 			return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
 		case errors.Is(err, internal.ErrUserNotFound):
@@ -126,59 +211,360 @@ func (h *Handler) Login(c echo.Context) error {
 		}
 	}
 
+	return c.JSON(http.StatusOK, LoginResponse{
+		Token:          result.Token,
+		ChallengeToken: result.ChallengeToken,
+	})
+}
+
+func (h *Handler) VerifyTwoFactor(c echo.Context) error {
+	var req VerifyTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind two-factor request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate two-factor request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	token, err := h.userSvc.VerifyTwoFactor(c.Request().Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		h.log.Errorf("failed to verify two-factor code: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrTooManyLoginAttempts):
+			setRetryAfter(c, err)
+			return echo.NewHTTPError(http.StatusTooManyRequests, "too many login attempts, try again later")
+		case errors.Is(err, internal.ErrInvalidChallengeToken):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired challenge token")
+		case errors.Is(err, internal.ErrInvalidTwoFactorCode),
+			errors.Is(err, internal.ErrTwoFactorNotEnrolled),
+			errors.Is(err, internal.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid two-factor code")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify two-factor code")
+		}
+	}
+
 	return c.JSON(http.StatusOK, LoginResponse{Token: token})
 }
 
-func (h *Handler) GetProfiles(c echo.Context) error {
-	uidCtx := c.Get("user_id")
-	if uidCtx == nil {
-		h.log.Errorf("user ID is nil")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
+func (h *Handler) StartOAuthLogin(c echo.Context) error {
+	provider := c.Param("provider")
+
+	authURL, err := h.userSvc.StartOAuthLogin(c.Request().Context(), provider)
+	if err != nil {
+		h.log.Errorf("failed to start oauth login with %s: %v", provider, err)
+		switch {
+		case errors.Is(err, internal.ErrUnknownOAuthProvider):
+			return echo.NewHTTPError(http.StatusNotFound, "unknown oauth provider")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to start oauth login")
+		}
 	}
 
-	uid, ok := uidCtx.(string)
-	if !ok || uid == "" {
-		h.log.Errorf("user ID is empty")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
+	return c.JSON(http.StatusOK, OAuthAuthorizeResponse{AuthorizationURL: authURL})
+}
+
+func (h *Handler) CompleteOAuthLogin(c echo.Context) error {
+	provider := c.Param("provider")
+
+	var req OAuthCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind oauth callback request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate oauth callback request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	userID, err := uuid.Parse(uid)
+	result, err := h.userSvc.CompleteOAuthLogin(c.Request().Context(), provider, &internal.OAuthCallback{
+		State:     req.State,
+		Code:      req.Code,
+		BirthDate: req.BirthDate,
+		Gender:    req.Gender,
+	})
 	if err != nil {
-		h.log.Errorf("invalid user ID: %v", err)
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+		h.log.Errorf("failed to complete oauth login with %s: %v", provider, err)
+		switch {
+		case errors.Is(err, internal.ErrUnknownOAuthProvider):
+			return echo.NewHTTPError(http.StatusNotFound, "unknown oauth provider")
+		case errors.Is(err, internal.ErrInvalidOAuthState):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired oauth state")
+		case errors.Is(err, internal.ErrOAuthEmailNotVerified):
+			return echo.NewHTTPError(http.StatusForbidden, "email address is not verified by the provider")
+		case errors.Is(err, internal.ErrProfileIncomplete):
+			return echo.NewHTTPError(http.StatusBadRequest, "birth_date and gender are required to create an account")
+		case errors.Is(err, internal.ErrOAuthIdentityExists):
+			return echo.NewHTTPError(http.StatusConflict, "identity already linked to another account")
+		case errors.Is(err, oidc.ErrExchangeFailed), errors.Is(err, oidc.ErrInvalidIDToken):
+			return echo.NewHTTPError(http.StatusUnauthorized, "failed to authenticate with provider")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to complete oauth login")
+		}
+	}
+
+	return c.JSON(http.StatusOK, LoginResponse{
+		Token:          result.Token,
+		ChallengeToken: result.ChallengeToken,
+	})
+}
+
+func (h *Handler) EnrollTOTP(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
 	}
 
-	profiles, err := h.profileSvc.GetProfiles(c.Request().Context(), userID)
+	enrollment, err := h.userSvc.EnrollTOTP(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to enroll totp for user %s: %v", userID, err)
+		switch {
+		case errors.Is(err, internal.ErrTwoFactorAlreadyEnabled):
+			return echo.NewHTTPError(http.StatusConflict, "two-factor authentication already enabled")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to enroll two-factor authentication")
+		}
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *Handler) ConfirmTOTP(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	var req ConfirmTOTPRequest
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind confirm totp request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate confirm totp request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	codes, err := h.userSvc.ConfirmTOTP(c.Request().Context(), userID, req.Code)
+	if err != nil {
+		h.log.Errorf("failed to confirm totp for user %s: %v", userID, err)
+		switch {
+		case errors.Is(err, internal.ErrInvalidTwoFactorCode):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid two-factor code")
+		case errors.Is(err, internal.ErrTwoFactorNotEnrolled):
+			return echo.NewHTTPError(http.StatusBadRequest, "two-factor authentication not enrolled")
+		case errors.Is(err, internal.ErrTwoFactorAlreadyEnabled):
+			return echo.NewHTTPError(http.StatusConflict, "two-factor authentication already enabled")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to confirm two-factor authentication")
+		}
+	}
+
+	return c.JSON(http.StatusOK, ConfirmTOTPResponse{RecoveryCodes: codes})
+}
+
+func (h *Handler) SetTimezone(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Timezone string `json:"timezone" validate:"required,timezone"`
+	}
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind timezone request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate timezone request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.userSvc.SetTimezone(c.Request().Context(), userID, req.Timezone); err != nil {
+		h.log.Errorf("failed to set timezone: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrInvalidTimezone):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid timezone")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set timezone")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) SetIncognito(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Incognito *bool `json:"incognito" validate:"required"`
+	}
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind incognito request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate incognito request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.userSvc.SetIncognito(c.Request().Context(), userID, *req.Incognito); err != nil {
+		h.log.Errorf("failed to set incognito: %v", err)
+		var required *internal.FeatureRequiredError
+		switch {
+		case errors.As(err, &required):
+			return h.featureRequired(c, required.Feature)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set incognito")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// featureRequired answers a request the service refused for lack of the
+// named feature the same way middleware.RequireFeature does.
+func (h *Handler) featureRequired(c echo.Context, name string) error {
+	plans, err := h.featureSvc.GetPlans(c.Request().Context())
+	if err != nil {
+		h.log.Errorf("failed to get plans for %s upsell: %v", name, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get plans")
+	}
+
+	return middleware.FeatureRequired(name, plans)
+}
+
+// locationRequest is the body of requests setting the real location. Real
+// locations are stored without a city.
+type locationRequest struct {
+	Latitude  *float64 `json:"latitude" validate:"required,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" validate:"required,min=-180,max=180"`
+}
+
+// passportRequest is the body of requests setting a passport location.
+type passportRequest struct {
+	Latitude  *float64 `json:"latitude" validate:"required,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" validate:"required,min=-180,max=180"`
+	City      string   `json:"city" validate:"omitempty,max=100"`
+}
+
+func (h *Handler) bindLocation(c echo.Context, req any) error {
+	if err := c.Bind(req); err != nil {
+		h.log.Errorf("failed to bind location request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(req); err != nil {
+		h.log.Errorf("failed to validate location request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return nil
+}
+
+func (h *Handler) SetLocation(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	var req locationRequest
+	if err := h.bindLocation(c, &req); err != nil {
+		return err
+	}
+
+	location := &internal.Location{
+		Latitude:  *req.Latitude,
+		Longitude: *req.Longitude,
+	}
+	if err := h.userSvc.SetLocation(c.Request().Context(), userID, location); err != nil {
+		h.log.Errorf("failed to set location: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to set location")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) SetPassport(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	var req passportRequest
+	if err := h.bindLocation(c, &req); err != nil {
+		return err
+	}
+
+	return h.setPassport(c, userID, &internal.Location{
+		Latitude:  *req.Latitude,
+		Longitude: *req.Longitude,
+		City:      req.City,
+	})
+}
+
+func (h *Handler) ClearPassport(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	return h.setPassport(c, userID, nil)
+}
+
+func (h *Handler) setPassport(c echo.Context, userID uuid.UUID, location *internal.Location) error {
+	if err := h.userSvc.SetPassport(c.Request().Context(), userID, location); err != nil {
+		h.log.Errorf("failed to set passport: %v", err)
+		var required *internal.FeatureRequiredError
+		switch {
+		case errors.As(err, &required):
+			return h.featureRequired(c, required.Feature)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set passport")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) GetProfiles(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	profiles, quota, err := h.profileSvc.GetProfiles(c.Request().Context(), userID)
 	if err != nil {
 		h.log.Errorf("failed to get profiles for user %s: %v", userID, err)
 		switch {
 		case errors.Is(err, internal.ErrDailyInteractionLimitExceeded):
+			setQuotaHeaders(c, quota)
+			setRetryAfter(c, err)
 			return echo.NewHTTPError(http.StatusTooManyRequests, "daily interaction limit exceeded")
 		default:
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
 	}
 
+	setQuotaHeaders(c, quota)
 	return c.JSON(http.StatusOK, profiles)
 }
 
 func (h *Handler) CreateProfileResponse(c echo.Context) error {
-	uidCtx := c.Get("user_id")
-	if uidCtx == nil {
-		h.log.Errorf("user ID is nil")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
-	}
-
-	uid, ok := uidCtx.(string)
-	if !ok || uid == "" {
-		h.log.Errorf("user ID is empty")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
-	}
-
-	fromUserID, err := uuid.Parse(uid)
+	fromUserID, err := h.principalID(c)
 	if err != nil {
-		h.log.Errorf("invalid user ID: %+v", err)
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+		return err
 	}
 
 	toUserID, err := uuid.Parse(c.Param("id"))
@@ -188,7 +574,7 @@ func (h *Handler) CreateProfileResponse(c echo.Context) error {
 	}
 
 	var req struct {
-		ResponseType string `json:"response_type" validate:"required,oneof=like pass"`
+		ResponseType string `json:"response_type" validate:"required,oneof=like pass super_like"`
 	}
 	if err := c.Bind(&req); err != nil {
 		h.log.Errorf("failed to bind response request: %v", err)
@@ -200,11 +586,18 @@ func (h *Handler) CreateProfileResponse(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	if err := h.profileSvc.CreateProfileResponse(c.Request().Context(), fromUserID, toUserID, req.ResponseType); err != nil {
+	quota, err := h.profileSvc.CreateProfileResponse(c.Request().Context(), fromUserID, toUserID, req.ResponseType)
+	if err != nil {
 		h.log.Errorf("failed to create profile response from %s to %s: %v", fromUserID, toUserID, err)
 		switch {
 		case errors.Is(err, internal.ErrDailyInteractionLimitExceeded):
+			setQuotaHeaders(c, quota)
+			setRetryAfter(c, err)
 			return echo.NewHTTPError(http.StatusTooManyRequests, "daily interaction limit exceeded")
+		case errors.Is(err, internal.ErrSuperLikeLimitExceeded):
+			setQuotaHeaders(c, quota)
+			setRetryAfter(c, err)
+			return echo.NewHTTPError(http.StatusTooManyRequests, "weekly super like limit exceeded")
 		case errors.Is(err, internal.ErrConflictingResponse):
 			return echo.NewHTTPError(http.StatusConflict, "you already responded to this profile")
 		default:
@@ -212,9 +605,95 @@ func (h *Handler) CreateProfileResponse(c echo.Context) error {
 		}
 	}
 
+	setQuotaHeaders(c, quota)
 	return c.NoContent(http.StatusCreated)
 }
 
+func (h *Handler) RewindProfileResponse(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	profile, err := h.profileSvc.RewindProfileResponse(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to rewind response for user %s: %v", userID, err)
+		switch {
+		case errors.Is(err, internal.ErrNothingToRewind):
+			return echo.NewHTTPError(http.StatusNotFound, "nothing to rewind")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to rewind response")
+		}
+	}
+
+	// A rewind gives a response back, so the quota is read again.
+	quota, err := h.profileSvc.GetQuota(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to get quota for user %s: %v", userID, err)
+	}
+	setQuotaHeaders(c, quota)
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (h *Handler) GetQuota(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	quota, err := h.profileSvc.GetQuota(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to get quota for user %s: %v", userID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get quota")
+	}
+
+	return c.JSON(http.StatusOK, quota)
+}
+
+func (h *Handler) GetNotifications(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	notifications, err := h.profileSvc.GetNotifications(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to get notifications for user %s: %v", userID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get notifications")
+	}
+
+	return c.JSON(http.StatusOK, notifications)
+}
+
+func (h *Handler) GetLikesReceived(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	likes, err := h.profileSvc.GetLikesReceived(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to get likes received for user %s: %v", userID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get likes received")
+	}
+
+	return c.JSON(http.StatusOK, likes)
+}
+
+// setQuotaHeaders reports the user's daily response quota on the response.
+// Unlimited users, and requests that could not tell the quota, get no
+// headers.
+func setQuotaHeaders(c echo.Context, quota *internal.Quota) {
+	if quota == nil || quota.Limit == nil {
+		return
+	}
+
+	header := c.Response().Header()
+	header.Set("X-Quota-Limit", strconv.Itoa(*quota.Limit))
+	header.Set("X-Quota-Remaining", strconv.Itoa(*quota.Remaining))
+	header.Set("X-Quota-Reset", strconv.FormatInt(quota.ResetAt.Unix(), 10))
+}
+
 func (h *Handler) GetFeatures(c echo.Context) error {
 	features, err := h.featureSvc.GetFeatures(c.Request().Context())
 	if err != nil {
@@ -225,23 +704,20 @@ func (h *Handler) GetFeatures(c echo.Context) error {
 	return c.JSON(http.StatusOK, features)
 }
 
-func (h *Handler) SubscribeToFeature(c echo.Context) error {
-	uidCtx := c.Get("user_id")
-	if uidCtx == nil {
-		h.log.Errorf("user ID is nil")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
+func (h *Handler) GetPlans(c echo.Context) error {
+	plans, err := h.featureSvc.GetPlans(c.Request().Context())
+	if err != nil {
+		h.log.Errorf("failed to get plans: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get plans")
 	}
 
-	uid, ok := uidCtx.(string)
-	if !ok || uid == "" {
-		h.log.Errorf("user ID is empty")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
-	}
+	return c.JSON(http.StatusOK, plans)
+}
 
-	userID, err := uuid.Parse(uid)
+func (h *Handler) SubscribeToFeature(c echo.Context) error {
+	userID, err := h.principalID(c)
 	if err != nil {
-		h.log.Errorf("invalid user ID: %+v", err)
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+		return err
 	}
 
 	featureID, err := uuid.Parse(c.Param("id"))
@@ -251,8 +727,12 @@ func (h *Handler) SubscribeToFeature(c echo.Context) error {
 	}
 
 	var req struct {
-		Period string `json:"period" validate:"required,oneof=1_month 3_months 6_months 12_months"`
-		Value  *int   `json:"value"`
+		Period        string     `json:"period" validate:"required_without=Trial"`
+		PlanID        *uuid.UUID `json:"plan_id"`
+		Currency      string     `json:"currency" validate:"omitempty,len=3,uppercase"`
+		PaymentMethod string     `json:"payment_method" validate:"required_without=Trial"`
+		PromoCode     string     `json:"promo_code" validate:"omitempty,max=64"`
+		Trial         bool       `json:"trial"`
 	}
 	if err := c.Bind(&req); err != nil {
 		h.log.Errorf("failed to bind subscribe request: %v", err)
@@ -264,22 +744,43 @@ func (h *Handler) SubscribeToFeature(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	userFeature := &internal.UserFeature{
-		UserID:    userID,
-		FeatureID: featureID,
-		Value:     0,
-		Status:    "active",
-	}
-
-	if req.Value != nil {
-		userFeature.Value = *req.Value
+	if req.Trial && req.PromoCode != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "promo codes cannot be used with a trial")
 	}
 
-	if err := h.featureSvc.SubscribeToFeature(c.Request().Context(), userFeature, req.Period); err != nil {
+	userFeature, err := h.featureSvc.SubscribeToFeature(c.Request().Context(), &internal.SubscribeRequest{
+		UserID:        userID,
+		FeatureID:     featureID,
+		PlanID:        req.PlanID,
+		Period:        req.Period,
+		Currency:      req.Currency,
+		PaymentMethod: req.PaymentMethod,
+		PromoCode:     req.PromoCode,
+		Trial:         req.Trial,
+	})
+	if err != nil {
 		h.log.Errorf("failed to subscribe to feature: %v", err)
 		switch {
 		case errors.Is(err, internal.ErrFeatureNotFound):
 			return echo.NewHTTPError(http.StatusNotFound, "feature not found")
+		case errors.Is(err, internal.ErrPlanPriceNotFound):
+			return echo.NewHTTPError(http.StatusBadRequest, "no active price for the requested plan, period and currency")
+		case errors.Is(err, internal.ErrPaymentDeclined):
+			return echo.NewHTTPError(http.StatusPaymentRequired, "payment declined")
+		case errors.Is(err, internal.ErrTrialUnavailable):
+			return echo.NewHTTPError(http.StatusBadRequest, "no trial is available for this feature")
+		case errors.Is(err, internal.ErrTrialAlreadyUsed):
+			return echo.NewHTTPError(http.StatusConflict, "trial already used for this feature")
+		case errors.Is(err, internal.ErrPromoCodeNotFound):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid promo code")
+		case errors.Is(err, internal.ErrPromoCodeExpired):
+			return echo.NewHTTPError(http.StatusBadRequest, "promo code has expired")
+		case errors.Is(err, internal.ErrPromoCodeExhausted):
+			return echo.NewHTTPError(http.StatusBadRequest, "promo code has been fully redeemed")
+		case errors.Is(err, internal.ErrPromoCodeNotApplicable):
+			return echo.NewHTTPError(http.StatusBadRequest, "promo code does not apply to this plan")
+		case errors.Is(err, internal.ErrPromoCodeAlreadyRedeemed):
+			return echo.NewHTTPError(http.StatusConflict, "promo code already redeemed")
 		case errors.Is(err, internal.ErrFeatureAlreadySubscribed):
 			return echo.NewHTTPError(http.StatusConflict, "already subscribed to this feature")
 		default:
@@ -287,28 +788,438 @@ func (h *Handler) SubscribeToFeature(c echo.Context) error {
 		}
 	}
 
+	// The subscription is granted once a pending payment settles.
+	if userFeature.Status == internal.FeatureStatusPending {
+		return c.JSON(http.StatusAccepted, userFeature)
+	}
+
 	return c.JSON(http.StatusCreated, userFeature)
 }
 
-func (h *Handler) GetUserFeatures(c echo.Context) error {
-	uidCtx := c.Get("user_id")
-	if uidCtx == nil {
-		h.log.Errorf("user ID is nil")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
+func (h *Handler) SetAutoRenew(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	featureID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Errorf("invalid feature ID: %+v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid feature ID")
+	}
+
+	var req struct {
+		AutoRenew *bool `json:"auto_renew" validate:"required"`
+	}
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind auto renew request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate auto renew request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.featureSvc.SetAutoRenew(c.Request().Context(), userID, featureID, *req.AutoRenew)
+	if err != nil {
+		h.log.Errorf("failed to set auto renew: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrSubscriptionNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "subscription not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set auto renew")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) CancelSubscription(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	featureID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Errorf("invalid feature ID: %+v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid feature ID")
+	}
+
+	var req struct {
+		Mode   string `json:"mode" validate:"required,oneof=immediate period_end"`
+		Refund bool   `json:"refund"`
+	}
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind cancel request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate cancel request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	uid, ok := uidCtx.(string)
-	if !ok || uid == "" {
-		h.log.Errorf("user ID is empty")
-		return echo.NewHTTPError(http.StatusBadRequest, "user ID is required")
+	atPeriodEnd := req.Mode == "period_end"
+	if atPeriodEnd && req.Refund {
+		return echo.NewHTTPError(http.StatusBadRequest, "refunds are only available for immediate cancellation")
 	}
 
-	userID, err := uuid.Parse(uid)
+	result, err := h.featureSvc.CancelSubscription(c.Request().Context(), &internal.CancelRequest{
+		UserID:      userID,
+		FeatureID:   featureID,
+		AtPeriodEnd: atPeriodEnd,
+		Refund:      req.Refund,
+	})
+	if err != nil {
+		h.log.Errorf("failed to cancel subscription: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrSubscriptionNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "subscription not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to cancel subscription")
+		}
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) PreviewPlanChange(c echo.Context) error {
+	req, err := h.bindPlanChange(c)
+	if err != nil {
+		return err
+	}
+
+	preview, err := h.featureSvc.PreviewPlanChange(c.Request().Context(), req)
+	if err != nil {
+		h.log.Errorf("failed to preview plan change: %v", err)
+		return planChangeError(err, "failed to preview plan change")
+	}
+
+	return c.JSON(http.StatusOK, preview)
+}
+
+func (h *Handler) ChangePlan(c echo.Context) error {
+	req, err := h.bindPlanChange(c)
+	if err != nil {
+		return err
+	}
+
+	userFeature, err := h.featureSvc.ChangePlan(c.Request().Context(), req)
+	if err != nil {
+		h.log.Errorf("failed to change plan: %v", err)
+		return planChangeError(err, "failed to change plan")
+	}
+
+	// The new plan is granted once a pending payment settles.
+	if userFeature.Status == internal.FeatureStatusPending {
+		return c.JSON(http.StatusAccepted, userFeature)
+	}
+
+	return c.JSON(http.StatusOK, userFeature)
+}
+
+// bindPlanChange reads the plan change request shared by the preview and
+// change endpoints.
+func (h *Handler) bindPlanChange(c echo.Context) (*internal.PlanChangeRequest, error) {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	featureID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Errorf("invalid feature ID: %+v", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid feature ID")
+	}
+
+	var req struct {
+		Period        string     `json:"period" validate:"required"`
+		PlanID        *uuid.UUID `json:"plan_id"`
+		Currency      string     `json:"currency" validate:"omitempty,len=3,uppercase"`
+		PaymentMethod string     `json:"payment_method"`
+	}
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind plan change request: %v", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate plan change request: %v", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return &internal.PlanChangeRequest{
+		UserID:        userID,
+		FeatureID:     featureID,
+		PlanID:        req.PlanID,
+		Period:        req.Period,
+		Currency:      req.Currency,
+		PaymentMethod: req.PaymentMethod,
+	}, nil
+}
+
+func planChangeError(err error, message string) error {
+	switch {
+	case errors.Is(err, internal.ErrSubscriptionNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "subscription not found")
+	case errors.Is(err, internal.ErrPlanChangeNotAllowed):
+		return echo.NewHTTPError(http.StatusConflict, "subscription is awaiting payment")
+	case errors.Is(err, internal.ErrPlanUnchanged):
+		return echo.NewHTTPError(http.StatusBadRequest, "already subscribed to this plan and period")
+	case errors.Is(err, internal.ErrPlanPriceNotFound):
+		return echo.NewHTTPError(http.StatusBadRequest, "no active price for the requested plan, period and currency")
+	case errors.Is(err, internal.ErrPaymentDeclined):
+		return echo.NewHTTPError(http.StatusPaymentRequired, "payment declined")
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
+
+func (h *Handler) GrantFeature(c echo.Context) error {
+	adminID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		h.log.Errorf("invalid user ID: %+v", err)
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
 	}
 
+	var req struct {
+		FeatureID uuid.UUID  `json:"feature_id" validate:"required"`
+		Reason    string     `json:"reason" validate:"required,max=500"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		Value     *int       `json:"value" validate:"omitempty,min=-1"`
+	}
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind grant request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate grant request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "expires_at must be in the future")
+	}
+
+	granted, err := h.featureSvc.GrantFeature(c.Request().Context(), &internal.GrantRequest{
+		UserID:    userID,
+		FeatureID: req.FeatureID,
+		Value:     req.Value,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+		GrantedBy: &adminID,
+	})
+	if err != nil {
+		h.log.Errorf("failed to grant feature: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrFeatureNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "feature not found")
+		case errors.Is(err, internal.ErrUserNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "user not found")
+		case errors.Is(err, internal.ErrFeatureAlreadySubscribed):
+			return echo.NewHTTPError(http.StatusConflict, "user already has this feature")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to grant feature")
+		}
+	}
+
+	return c.JSON(http.StatusCreated, granted)
+}
+
+func (h *Handler) RevokeGrant(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Errorf("invalid user ID: %+v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user ID")
+	}
+
+	featureID, err := uuid.Parse(c.Param("feature_id"))
+	if err != nil {
+		h.log.Errorf("invalid feature ID: %+v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid feature ID")
+	}
+
+	revoked, err := h.featureSvc.RevokeGrant(c.Request().Context(), userID, featureID)
+	if err != nil {
+		h.log.Errorf("failed to revoke grant: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrGrantNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "grant not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke grant")
+		}
+	}
+
+	return c.JSON(http.StatusOK, revoked)
+}
+
+func (h *Handler) ActivateBoost(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Source        string `json:"source" validate:"required,oneof=purchase redeem"`
+		PaymentMethod string `json:"payment_method" validate:"required_if=Source purchase"`
+	}
+	if err := c.Bind(&req); err != nil {
+		h.log.Errorf("failed to bind boost request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(&req); err != nil {
+		h.log.Errorf("failed to validate boost request: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	boost, err := h.featureSvc.ActivateBoost(c.Request().Context(), &internal.BoostRequest{
+		UserID:        userID,
+		Source:        req.Source,
+		PaymentMethod: req.PaymentMethod,
+	})
+	if err != nil {
+		h.log.Errorf("failed to activate boost for user %s: %v", userID, err)
+		switch {
+		case errors.Is(err, internal.ErrBoostActive):
+			return echo.NewHTTPError(http.StatusConflict, "a boost is already running")
+		case errors.Is(err, internal.ErrNoBoostsLeft):
+			return echo.NewHTTPError(http.StatusPaymentRequired, "no boosts left to redeem this week")
+		case errors.Is(err, internal.ErrPaymentDeclined):
+			return echo.NewHTTPError(http.StatusPaymentRequired, "payment declined")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to activate boost")
+		}
+	}
+
+	// A bought boost starts once a pending payment settles.
+	if boost.Status == internal.FeatureStatusPending {
+		return c.JSON(http.StatusAccepted, boost)
+	}
+
+	return c.JSON(http.StatusCreated, boost)
+}
+
+func (h *Handler) GetBoosts(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	boosts, err := h.featureSvc.GetBoosts(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to get boosts for user %s: %v", userID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get boosts")
+	}
+
+	return c.JSON(http.StatusOK, boosts)
+}
+
+func (h *Handler) GetBillingHistory(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	invoices, err := h.featureSvc.GetBillingHistory(c.Request().Context(), userID)
+	if err != nil {
+		h.log.Errorf("failed to get billing history: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get billing history")
+	}
+
+	return c.JSON(http.StatusOK, invoices)
+}
+
+func (h *Handler) GetReceipt(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Errorf("invalid invoice ID: %+v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid invoice ID")
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "pdf" {
+		return echo.NewHTTPError(http.StatusBadRequest, "format must be html or pdf")
+	}
+
+	r, err := h.featureSvc.GetReceipt(c.Request().Context(), userID, invoiceID)
+	if err != nil {
+		h.log.Errorf("failed to get receipt: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrInvoiceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "invoice not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get receipt")
+		}
+	}
+
+	var buf bytes.Buffer
+	contentType := echo.MIMETextHTMLCharsetUTF8
+	render := receipt.HTML
+	if format == "pdf" {
+		contentType = "application/pdf"
+		render = receipt.PDF
+		c.Response().Header().Set(echo.HeaderContentDisposition,
+			fmt.Sprintf("attachment; filename=receipt-%06d.pdf", r.Invoice.Number))
+	}
+	if err := render(&buf, r); err != nil {
+		h.log.Errorf("failed to render receipt: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get receipt")
+	}
+
+	return c.Blob(http.StatusOK, contentType, buf.Bytes())
+}
+
+// paymentSignatureHeader carries the HMAC signature of a payment webhook body.
+const paymentSignatureHeader = "X-Payment-Signature"
+
+func (h *Handler) PaymentWebhook(c echo.Context) error {
+	payload, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		h.log.Errorf("failed to read payment webhook: %v", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body")
+	}
+
+	err = h.featureSvc.HandlePaymentWebhook(c.Request().Context(), payload, c.Request().Header.Get(paymentSignatureHeader))
+	if err != nil {
+		h.log.Errorf("failed to handle payment webhook: %v", err)
+		switch {
+		case errors.Is(err, internal.ErrInvalidWebhookSignature):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid signature")
+		case errors.Is(err, internal.ErrInvalidWebhookPayload):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid payload")
+		case errors.Is(err, internal.ErrPaymentNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "payment not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to handle payment webhook")
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) GetUserFeatures(c echo.Context) error {
+	userID, err := h.principalID(c)
+	if err != nil {
+		return err
+	}
+
 	features, err := h.featureSvc.GetUserFeatures(c.Request().Context(), userID)
 	if err != nil {
 		h.log.Errorf("failed to get user features: %v", err)
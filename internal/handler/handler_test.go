@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"datingapp/internal"
+	"datingapp/internal/middleware"
 	mock_service "datingapp/internal/service/mock"
 
 	"github.com/go-playground/validator/v10"
@@ -30,6 +32,11 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return cv.validator.Struct(i)
 }
 
+func setPrincipal(c echo.Context, userID uuid.UUID) {
+	ctx := internal.WithPrincipal(c.Request().Context(), &internal.Principal{UserID: userID})
+	c.SetRequest(c.Request().WithContext(ctx))
+}
+
 func TestHandler_SignUp(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -207,12 +214,13 @@ func TestHandler_Login(t *testing.T) {
 	e.Validator = &CustomValidator{validator: validator.New()}
 
 	tests := []struct {
-		name           string
-		requestBody    map[string]interface{}
-		setupMock      func()
-		expectedStatus int
-		expectedError  string
-		expectedToken  string
+		name                   string
+		requestBody            map[string]interface{}
+		setupMock              func()
+		expectedStatus         int
+		expectedError          string
+		expectedToken          string
+		expectedChallengeToken string
 	}{
 		{
 			name: "successful login",
@@ -222,12 +230,26 @@ func TestHandler_Login(t *testing.T) {
 			},
 			setupMock: func() {
 				mockSvc.EXPECT().
-					Login(gomock.Any(), "test@example.com", "Password123!").
-					Return("valid.jwt.token", nil)
+					Login(gomock.Any(), "test@example.com", "Password123!", gomock.Any()).
+					Return(&internal.LoginResult{Token: "valid.jwt.token"}, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedToken:  "valid.jwt.token",
 		},
+		{
+			name: "two-factor required",
+			requestBody: map[string]interface{}{
+				"email":    "test@example.com",
+				"password": "Password123!",
+			},
+			setupMock: func() {
+				mockSvc.EXPECT().
+					Login(gomock.Any(), "test@example.com", "Password123!", gomock.Any()).
+					Return(&internal.LoginResult{ChallengeToken: "challenge.jwt.token"}, nil)
+			},
+			expectedStatus:         http.StatusOK,
+			expectedChallengeToken: "challenge.jwt.token",
+		},
 		{
 			name: "invalid credentials",
 			requestBody: map[string]interface{}{
@@ -236,12 +258,26 @@ func TestHandler_Login(t *testing.T) {
 			},
 			setupMock: func() {
 				mockSvc.EXPECT().
-					Login(gomock.Any(), "test@example.com", "WrongPassword123!").
-					Return("", internal.ErrInvalidCredentials)
+					Login(gomock.Any(), "test@example.com", "WrongPassword123!", gomock.Any()).
+					Return(nil, internal.ErrInvalidCredentials)
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedError:  "invalid credentials",
 		},
+		{
+			name: "locked out",
+			requestBody: map[string]interface{}{
+				"email":    "test@example.com",
+				"password": "Password123!",
+			},
+			setupMock: func() {
+				mockSvc.EXPECT().
+					Login(gomock.Any(), "test@example.com", "Password123!", gomock.Any()).
+					Return(nil, &internal.LockoutError{Until: time.Now().Add(time.Minute)})
+			},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedError:  "too many login attempts, try again later",
+		},
 		{
 			name: "missing email",
 			requestBody: map[string]interface{}{
@@ -275,8 +311,8 @@ func TestHandler_Login(t *testing.T) {
 			},
 			setupMock: func() {
 				mockSvc.EXPECT().
-					Login(gomock.Any(), "test@example.com", "Password123!").
-					Return("", errors.New("unexpected error"))
+					Login(gomock.Any(), "test@example.com", "Password123!", gomock.Any()).
+					Return(nil, errors.New("unexpected error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedError:  "failed to login",
@@ -302,6 +338,7 @@ func TestHandler_Login(t *testing.T) {
 					err := json.Unmarshal(rec.Body.Bytes(), &response)
 					assert.NoError(t, err)
 					assert.Equal(t, tt.expectedToken, response.Token)
+					assert.Equal(t, tt.expectedChallengeToken, response.ChallengeToken)
 				}
 			} else {
 				if tt.expectedError != "" {
@@ -317,6 +354,107 @@ func TestHandler_Login(t *testing.T) {
 	}
 }
 
+func TestHandler_VerifyTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := mock_service.NewMockUserService(ctrl)
+	featureSvc := mock_service.NewMockFeatureService(ctrl)
+	profileSvc := mock_service.NewMockProfileService(ctrl)
+	h := NewHandler(mockSvc, featureSvc, profileSvc)
+
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	tests := []struct {
+		name           string
+		requestBody    map[string]interface{}
+		setupMock      func()
+		expectedStatus int
+		expectedError  string
+		expectedToken  string
+	}{
+		{
+			name: "valid code",
+			requestBody: map[string]interface{}{
+				"challenge_token": "challenge.jwt.token",
+				"code":            "123456",
+			},
+			setupMock: func() {
+				mockSvc.EXPECT().
+					VerifyTwoFactor(gomock.Any(), "challenge.jwt.token", "123456").
+					Return("valid.jwt.token", nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedToken:  "valid.jwt.token",
+		},
+		{
+			name: "invalid code",
+			requestBody: map[string]interface{}{
+				"challenge_token": "challenge.jwt.token",
+				"code":            "000000",
+			},
+			setupMock: func() {
+				mockSvc.EXPECT().
+					VerifyTwoFactor(gomock.Any(), "challenge.jwt.token", "000000").
+					Return("", internal.ErrInvalidTwoFactorCode)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid two-factor code",
+		},
+		{
+			name: "expired challenge token",
+			requestBody: map[string]interface{}{
+				"challenge_token": "expired.jwt.token",
+				"code":            "123456",
+			},
+			setupMock: func() {
+				mockSvc.EXPECT().
+					VerifyTwoFactor(gomock.Any(), "expired.jwt.token", "123456").
+					Return("", internal.ErrInvalidChallengeToken)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid or expired challenge token",
+		},
+		{
+			name: "missing code",
+			requestBody: map[string]interface{}{
+				"challenge_token": "challenge.jwt.token",
+			},
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPost, "/login/2fa", bytes.NewBuffer(jsonBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.VerifyTwoFactor(c)
+
+			if err == nil {
+				assert.Equal(t, tt.expectedStatus, rec.Code)
+				var response LoginResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedToken, response.Token)
+			} else {
+				var httpError *echo.HTTPError
+				assert.ErrorAs(t, err, &httpError)
+				assert.Equal(t, tt.expectedStatus, httpError.Code)
+				if tt.expectedError != "" {
+					assert.Equal(t, tt.expectedError, httpError.Message)
+				}
+			}
+		})
+	}
+}
+
 func TestHandler_GetProfiles(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -329,26 +467,35 @@ func TestHandler_GetProfiles(t *testing.T) {
 	e := echo.New()
 
 	validUserID := uuid.New()
-	mockProfiles := []*internal.User{
+	distanceKm := 10
+	visitingCity := "Denpasar"
+	mockProfiles := []*internal.Candidate{
 		{
-			ID:        uuid.New(),
-			Email:     "candidate1@example.com",
-			Name:      "Candidate 1",
-			Bio:       "Bio 1",
-			BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
-			Gender:    "female",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			User: internal.User{
+				ID:        uuid.New(),
+				Email:     "candidate1@example.com",
+				Name:      "Candidate 1",
+				Bio:       "Bio 1",
+				BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+				Gender:    "female",
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			},
+			DistanceKm: &distanceKm,
 		},
 		{
-			ID:        uuid.New(),
-			Email:     "candidate2@example.com",
-			Name:      "Candidate 2",
-			Bio:       "Bio 2",
-			BirthDate: time.Date(1992, 1, 1, 0, 0, 0, 0, time.UTC),
-			Gender:    "male",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			User: internal.User{
+				ID:        uuid.New(),
+				Email:     "candidate2@example.com",
+				Name:      "Candidate 2",
+				Bio:       "Bio 2",
+				BirthDate: time.Date(1992, 1, 1, 0, 0, 0, 0, time.UTC),
+				Gender:    "male",
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			},
+			Visiting:     true,
+			VisitingCity: &visitingCity,
 		},
 	}
 
@@ -363,12 +510,12 @@ func TestHandler_GetProfiles(t *testing.T) {
 		{
 			name: "successful get candidates",
 			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
+				setPrincipal(c, validUserID)
 			},
 			setupMock: func() {
 				profileSvc.EXPECT().
 					GetProfiles(gomock.Any(), validUserID).
-					Return(mockProfiles, nil)
+					Return(mockProfiles, testQuota(10, 3), nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedLen:    2,
@@ -376,50 +523,32 @@ func TestHandler_GetProfiles(t *testing.T) {
 		{
 			name: "no candidates available",
 			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
+				setPrincipal(c, validUserID)
 			},
 			setupMock: func() {
 				profileSvc.EXPECT().
 					GetProfiles(gomock.Any(), validUserID).
-					Return([]*internal.User{}, nil)
+					Return([]*internal.Candidate{}, testQuota(10, 3), nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedLen:    0,
 		},
 		{
-			name: "invalid user ID in context",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", "invalid-uuid")
-			},
-			setupMock:      func() {},
-			expectedStatus: http.StatusBadRequest,
-			expectedError:  "invalid user ID",
-		},
-		{
-			name:           "missing user ID in context",
+			name:           "missing principal in context",
 			setupContext:   func(c echo.Context) {},
 			setupMock:      func() {},
-			expectedStatus: http.StatusBadRequest,
-			expectedError:  "user ID is required",
-		},
-		{
-			name: "invalid user ID in context",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", "invalid-uuid")
-			},
-			setupMock:      func() {},
-			expectedStatus: http.StatusBadRequest,
-			expectedError:  "invalid user ID",
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "unauthorized",
 		},
 		{
 			name: "service error",
 			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
+				setPrincipal(c, validUserID)
 			},
 			setupMock: func() {
 				profileSvc.EXPECT().
 					GetProfiles(gomock.Any(), validUserID).
-					Return(nil, errors.New("service error"))
+					Return(nil, nil, errors.New("service error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedError:  "service error",
@@ -449,11 +578,18 @@ func TestHandler_GetProfiles(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedStatus, rec.Code)
+				assert.Equal(t, "10", rec.Header().Get("X-Quota-Limit"))
+				assert.Equal(t, "7", rec.Header().Get("X-Quota-Remaining"))
 
-				var response []*internal.User
+				var response []*internal.Candidate
 				err := json.Unmarshal(rec.Body.Bytes(), &response)
 				assert.NoError(t, err)
 				assert.Len(t, response, tt.expectedLen)
+				if tt.expectedLen > 0 {
+					assert.Equal(t, mockProfiles[0].DistanceKm, response[0].DistanceKm)
+					assert.Equal(t, mockProfiles[1].VisitingCity, response[1].VisitingCity)
+					assert.True(t, response[1].Visiting)
+				}
 
 				if tt.expectedLen > 0 {
 					for _, profile := range response {
@@ -470,188 +606,1427 @@ func TestHandler_GetProfiles(t *testing.T) {
 	}
 }
 
-func TestHandler_CreateProfileResponse(t *testing.T) {
+func TestHandler_GetProfiles_DailyLimit(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockSvc := mock_service.NewMockUserService(ctrl)
-	featureSvc := mock_service.NewMockFeatureService(ctrl)
+	userID := uuid.New()
 	profileSvc := mock_service.NewMockProfileService(ctrl)
-	h := NewHandler(mockSvc, featureSvc, profileSvc)
+	profileSvc.EXPECT().
+		GetProfiles(gomock.Any(), userID).
+		Return(nil, testQuota(10, 10), &internal.DailyLimitError{ResetAt: time.Now().Add(90 * time.Minute)})
 
+	h := NewHandler(mock_service.NewMockUserService(ctrl), mock_service.NewMockFeatureService(ctrl), profileSvc)
 	e := echo.New()
-	e.Validator = &CustomValidator{validator: validator.New()}
 
-	validUserID := uuid.New()
-	targetUserID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/profiles", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setPrincipal(c, userID)
+
+	err := h.GetProfiles(c)
+	var httpError *echo.HTTPError
+	if assert.ErrorAs(t, err, &httpError) {
+		assert.Equal(t, http.StatusTooManyRequests, httpError.Code)
+	}
+
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.InDelta(t, 90*60, retryAfter, 5)
+	assert.Equal(t, "0", rec.Header().Get("X-Quota-Remaining"))
+	assert.NotEmpty(t, rec.Header().Get("X-Quota-Reset"))
+}
+
+func TestHandler_RewindProfileResponse(t *testing.T) {
+	userID := uuid.New()
+	profileID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
 
 	tests := []struct {
 		name           string
-		setupContext   func(echo.Context)
-		targetID       string
-		requestBody    map[string]interface{}
-		setupMock      func()
+		setupMock      func(svc *mock_service.MockProfileService)
 		expectedStatus int
-		expectedError  string
+		expectedBody   string
 	}{
 		{
-			name: "successful response",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
-			},
-			targetID: targetUserID.String(),
-			requestBody: map[string]interface{}{
-				"response_type": "like",
-			},
-			setupMock: func() {
-				profileSvc.EXPECT().
-					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
-					Return(nil)
-			},
-			expectedStatus: http.StatusCreated,
-		},
-		{
-			name: "daily limit exceeded",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
-			},
-			targetID: targetUserID.String(),
-			requestBody: map[string]interface{}{
-				"response_type": "like",
-			},
-			setupMock: func() {
-				profileSvc.EXPECT().
-					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
-					Return(internal.ErrDailyInteractionLimitExceeded)
-			},
-			expectedStatus: http.StatusTooManyRequests,
-			expectedError:  "daily interaction limit exceeded",
-		},
-		{
-			name: "invalid response type",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
-			},
-			targetID: targetUserID.String(),
-			requestBody: map[string]interface{}{
-				"response_type": "invalid",
-			},
-			setupMock:      func() {},
-			expectedStatus: http.StatusBadRequest,
-		},
-		{
-			name: "missing response type",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
+			name: "success",
+			setupMock: func(svc *mock_service.MockProfileService) {
+				svc.EXPECT().RewindProfileResponse(gomock.Any(), userID).Return(&internal.User{ID: profileID, Name: "Jane"}, nil)
 			},
-			targetID:       targetUserID.String(),
-			requestBody:    map[string]interface{}{},
-			setupMock:      func() {},
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusOK,
 		},
 		{
-			name: "already responded",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
-			},
-			targetID: targetUserID.String(),
-			requestBody: map[string]interface{}{
-				"response_type": "like",
-			},
-			setupMock: func() {
-				profileSvc.EXPECT().
-					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
-					Return(internal.ErrConflictingResponse)
+			name: "nothing to rewind",
+			setupMock: func(svc *mock_service.MockProfileService) {
+				svc.EXPECT().RewindProfileResponse(gomock.Any(), userID).Return(nil, internal.ErrNothingToRewind)
 			},
-			expectedStatus: http.StatusConflict,
-			expectedError:  "you already responded to this profile",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message":"nothing to rewind"}`,
 		},
 		{
 			name: "service error",
-			setupContext: func(c echo.Context) {
-				c.Set("user_id", validUserID.String())
-			},
-			targetID: targetUserID.String(),
-			requestBody: map[string]interface{}{
-				"response_type": "like",
-			},
-			setupMock: func() {
-				profileSvc.EXPECT().
-					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
-					Return(errors.New("service error"))
+			setupMock: func(svc *mock_service.MockProfileService) {
+				svc.EXPECT().RewindProfileResponse(gomock.Any(), userID).Return(nil, errors.New("service error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "service error",
+			expectedBody:   `{"message":"failed to rewind response"}`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			jsonBody, _ := json.Marshal(tt.requestBody)
-			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBody))
-			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-			rec := httptest.NewRecorder()
-			c := e.NewContext(req, rec)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
 
-			c.SetParamNames("id")
-			c.SetParamValues(tt.targetID)
+			profileSvc := mock_service.NewMockProfileService(ctrl)
+			profileSvc.EXPECT().GetQuota(gomock.Any(), userID).Return(testQuota(10, 2), nil).AnyTimes()
+			tt.setupMock(profileSvc)
 
-			if tt.setupContext != nil {
-				tt.setupContext(c)
-			}
-			tt.setupMock()
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mock_service.NewMockFeatureService(ctrl), profileSvc)
+			e := echo.New()
 
-			err := h.CreateProfileResponse(c)
+			req := httptest.NewRequest(http.MethodPost, "/profiles/rewind", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
 
+			err := h.RewindProfileResponse(c)
 			if err != nil {
-				assert.Error(t, err)
-				var httpError *echo.HTTPError
-				assert.ErrorAs(t, err, &httpError)
-				assert.Equal(t, tt.expectedStatus, httpError.Code)
-				if tt.expectedError != "" {
-					assert.Equal(t, tt.expectedError, httpError.Message)
-				}
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedStatus, rec.Code)
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
 			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			var profile internal.User
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &profile))
+			assert.Equal(t, profileID, profile.ID)
+			assert.Equal(t, "8", rec.Header().Get("X-Quota-Remaining"))
 		})
 	}
 }
 
-func TestHandler_GetFeatures(t *testing.T) {
-	tests := []struct {
-		name           string
-		setupMock      func(svc *mock_service.MockFeatureService)
-		expectedStatus int
+func TestHandler_GetQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	profileSvc := mock_service.NewMockProfileService(ctrl)
+	profileSvc.EXPECT().GetQuota(gomock.Any(), userID).Return(testQuota(10, 4), nil)
+
+	h := NewHandler(mock_service.NewMockUserService(ctrl), mock_service.NewMockFeatureService(ctrl), profileSvc)
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/me/quota", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setPrincipal(c, userID)
+
+	assert.NoError(t, h.GetQuota(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var quota internal.Quota
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &quota))
+	if assert.NotNil(t, quota.Remaining) {
+		assert.Equal(t, 6, *quota.Remaining)
+	}
+}
+
+func TestHandler_GetNotifications(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	actorID := uuid.New()
+	actorName := "Jane"
+	profileSvc := mock_service.NewMockProfileService(ctrl)
+	profileSvc.EXPECT().GetNotifications(gomock.Any(), userID).Return([]*internal.Notification{
+		{
+			ID:        uuid.New(),
+			UserID:    userID,
+			Type:      internal.NotificationTypeSuperLike,
+			ActorID:   &actorID,
+			ActorName: &actorName,
+		},
+	}, nil)
+
+	h := NewHandler(mock_service.NewMockUserService(ctrl), mock_service.NewMockFeatureService(ctrl), profileSvc)
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/me/notifications", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setPrincipal(c, userID)
+
+	assert.NoError(t, h.GetNotifications(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var notifications []*internal.Notification
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &notifications))
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, internal.NotificationTypeSuperLike, notifications[0].Type)
+		assert.Equal(t, &actorID, notifications[0].ActorID)
+	}
+}
+
+func TestHandler_GetLikesReceived(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	profileSvc := mock_service.NewMockProfileService(ctrl)
+	profileSvc.EXPECT().GetLikesReceived(gomock.Any(), userID).Return(&internal.LikesReceived{
+		Count: 3,
+		Likes: []*internal.ReceivedLike{
+			{Blurred: true},
+		},
+	}, nil)
+
+	h := NewHandler(mock_service.NewMockUserService(ctrl), mock_service.NewMockFeatureService(ctrl), profileSvc)
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/likes/received", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setPrincipal(c, userID)
+
+	assert.NoError(t, h.GetLikesReceived(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var likes internal.LikesReceived
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &likes))
+	assert.Equal(t, 3, likes.Count)
+	if assert.Len(t, likes.Likes, 1) {
+		assert.True(t, likes.Likes[0].Blurred)
+		assert.Nil(t, likes.Likes[0].Profile)
+	}
+}
+
+func TestHandler_ActivateBoost(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "redeem",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().ActivateBoost(gomock.Any(), &internal.BoostRequest{
+					UserID: userID,
+					Source: internal.BoostSourceRedeem,
+				}).Return(&internal.Boost{Status: internal.FeatureStatusActive}, nil)
+			},
+			requestBody:    `{"source":"redeem"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "purchase pending",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().ActivateBoost(gomock.Any(), &internal.BoostRequest{
+					UserID:        userID,
+					Source:        internal.BoostSourcePurchase,
+					PaymentMethod: "pm_pending",
+				}).Return(&internal.Boost{Status: internal.FeatureStatusPending}, nil)
+			},
+			requestBody:    `{"source":"purchase","payment_method":"pm_pending"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "purchase without payment method",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"source":"purchase"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'PaymentMethod' Error:Field validation for 'PaymentMethod' failed on the 'required_if' tag"}`,
+		},
+		{
+			name: "already running",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().ActivateBoost(gomock.Any(), gomock.Any()).Return(nil, internal.ErrBoostActive)
+			},
+			requestBody:    `{"source":"redeem"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message":"a boost is already running"}`,
+		},
+		{
+			name: "no boosts left",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().ActivateBoost(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("consume weekly boost: %w", internal.ErrNoBoostsLeft))
+			},
+			requestBody:    `{"source":"redeem"}`,
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   `{"message":"no boosts left to redeem this week"}`,
+		},
+		{
+			name: "payment declined",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().ActivateBoost(gomock.Any(), gomock.Any()).Return(nil, internal.ErrPaymentDeclined)
+			},
+			requestBody:    `{"source":"purchase","payment_method":"pm_declined"}`,
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   `{"message":"payment declined"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			featureSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(featureSvc)
+
+			h := NewHandler(mock_service.NewMockUserService(ctrl), featureSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPost, "/boosts", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+
+			err := h.ActivateBoost(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func testQuota(limit, used int) *internal.Quota {
+	remaining := max(limit-used, 0)
+	return &internal.Quota{
+		Limit:     &limit,
+		Used:      used,
+		Remaining: &remaining,
+		ResetAt:   time.Now().Add(time.Hour),
+	}
+}
+
+func TestHandler_SetTimezone(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockUserService)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "success",
+			setupMock: func(svc *mock_service.MockUserService) {
+				svc.EXPECT().SetTimezone(gomock.Any(), userID, "Asia/Jakarta").Return(nil)
+			},
+			requestBody:    `{"timezone":"Asia/Jakarta"}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "unknown timezone",
+			setupMock:      func(svc *mock_service.MockUserService) {},
+			requestBody:    `{"timezone":"Mars/Olympus_Mons"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'Timezone' Error:Field validation for 'Timezone' failed on the 'timezone' tag"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc := mock_service.NewMockUserService(ctrl)
+			tt.setupMock(userSvc)
+
+			h := NewHandler(userSvc, mock_service.NewMockFeatureService(ctrl), mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPut, "/me/timezone", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+
+			err := h.SetTimezone(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_SetIncognito(t *testing.T) {
+	userID := uuid.New()
+	premium := &internal.Plan{
+		Name:     "premium",
+		Features: []*internal.PlanFeature{{FeatureName: internal.FeatureIncognito}},
+	}
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+		expectedUpsell bool
+	}{
+		{
+			name: "turn on",
+			setupMock: func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {
+				svc.EXPECT().SetIncognito(gomock.Any(), userID, true).Return(nil)
+			},
+			requestBody:    `{"incognito":true}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "turn off",
+			setupMock: func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {
+				svc.EXPECT().SetIncognito(gomock.Any(), userID, false).Return(nil)
+			},
+			requestBody:    `{"incognito":false}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "without the feature",
+			setupMock: func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {
+				svc.EXPECT().SetIncognito(gomock.Any(), userID, true).Return(&internal.FeatureRequiredError{Feature: internal.FeatureIncognito})
+				featureSvc.EXPECT().GetPlans(gomock.Any()).Return([]*internal.Plan{premium}, nil)
+			},
+			requestBody:    `{"incognito":true}`,
+			expectedStatus: http.StatusPaymentRequired,
+			expectedUpsell: true,
+		},
+		{
+			name:           "missing setting",
+			setupMock:      func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {},
+			requestBody:    `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'Incognito' Error:Field validation for 'Incognito' failed on the 'required' tag"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc := mock_service.NewMockUserService(ctrl)
+			featureSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(userSvc, featureSvc)
+
+			h := NewHandler(userSvc, featureSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPut, "/me/incognito", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+
+			err := h.SetIncognito(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				if tt.expectedUpsell {
+					upsell, ok := he.Message.(*middleware.Upsell)
+					if assert.True(t, ok) {
+						assert.Equal(t, internal.FeatureIncognito, upsell.Feature)
+						assert.Equal(t, []*internal.Plan{premium}, upsell.Plans)
+					}
+					return
+				}
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_SetPassport(t *testing.T) {
+	userID := uuid.New()
+	premium := &internal.Plan{
+		Name:     "premium",
+		Features: []*internal.PlanFeature{{FeatureName: internal.FeaturePassport}},
+	}
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService)
+		method         string
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+		expectedUpsell bool
+	}{
+		{
+			name: "set",
+			setupMock: func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {
+				svc.EXPECT().SetPassport(gomock.Any(), userID, &internal.Location{
+					Latitude:  -8.65,
+					Longitude: 115.2167,
+					City:      "Denpasar",
+				}).Return(nil)
+			},
+			method:         http.MethodPut,
+			requestBody:    `{"latitude":-8.65,"longitude":115.2167,"city":"Denpasar"}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "without the feature",
+			setupMock: func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {
+				svc.EXPECT().SetPassport(gomock.Any(), userID, gomock.Any()).Return(&internal.FeatureRequiredError{Feature: internal.FeaturePassport})
+				featureSvc.EXPECT().GetPlans(gomock.Any()).Return([]*internal.Plan{premium}, nil)
+			},
+			method:         http.MethodPut,
+			requestBody:    `{"latitude":-8.65,"longitude":115.2167}`,
+			expectedStatus: http.StatusPaymentRequired,
+			expectedUpsell: true,
+		},
+		{
+			name:           "invalid latitude",
+			setupMock:      func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {},
+			method:         http.MethodPut,
+			requestBody:    `{"latitude":91,"longitude":115.2167}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'passportRequest.Latitude' Error:Field validation for 'Latitude' failed on the 'max' tag"}`,
+		},
+		{
+			name: "clear",
+			setupMock: func(svc *mock_service.MockUserService, featureSvc *mock_service.MockFeatureService) {
+				svc.EXPECT().SetPassport(gomock.Any(), userID, nil).Return(nil)
+			},
+			method:         http.MethodDelete,
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc := mock_service.NewMockUserService(ctrl)
+			featureSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(userSvc, featureSvc)
+
+			h := NewHandler(userSvc, featureSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(tt.method, "/me/passport", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+
+			var err error
+			if tt.method == http.MethodDelete {
+				err = h.ClearPassport(c)
+			} else {
+				err = h.SetPassport(c)
+			}
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				if tt.expectedUpsell {
+					upsell, ok := he.Message.(*middleware.Upsell)
+					if assert.True(t, ok) {
+						assert.Equal(t, internal.FeaturePassport, upsell.Feature)
+						assert.Equal(t, []*internal.Plan{premium}, upsell.Plans)
+					}
+					return
+				}
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_CreateProfileResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := mock_service.NewMockUserService(ctrl)
+	featureSvc := mock_service.NewMockFeatureService(ctrl)
+	profileSvc := mock_service.NewMockProfileService(ctrl)
+	h := NewHandler(mockSvc, featureSvc, profileSvc)
+
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	validUserID := uuid.New()
+	targetUserID := uuid.New()
+
+	tests := []struct {
+		name           string
+		setupContext   func(echo.Context)
+		targetID       string
+		requestBody    map[string]interface{}
+		setupMock      func()
+		expectedStatus int
+		expectedError  string
+		// expectedRemaining is the X-Quota-Remaining header, empty when the
+		// response carries no quota.
+		expectedRemaining string
+	}{
+		{
+			name: "successful response",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID: targetUserID.String(),
+			requestBody: map[string]interface{}{
+				"response_type": "like",
+			},
+			setupMock: func() {
+				profileSvc.EXPECT().
+					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
+					Return(testQuota(10, 3), nil)
+			},
+			expectedStatus:    http.StatusCreated,
+			expectedRemaining: "7",
+		},
+		{
+			name: "daily limit exceeded",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID: targetUserID.String(),
+			requestBody: map[string]interface{}{
+				"response_type": "like",
+			},
+			setupMock: func() {
+				profileSvc.EXPECT().
+					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
+					Return(testQuota(10, 10), &internal.DailyLimitError{ResetAt: time.Now().Add(time.Hour)})
+			},
+			expectedStatus:    http.StatusTooManyRequests,
+			expectedError:     "daily interaction limit exceeded",
+			expectedRemaining: "0",
+		},
+		{
+			name: "successful super like",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID: targetUserID.String(),
+			requestBody: map[string]interface{}{
+				"response_type": "super_like",
+			},
+			setupMock: func() {
+				profileSvc.EXPECT().
+					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "super_like").
+					Return(testQuota(10, 3), nil)
+			},
+			expectedStatus:    http.StatusCreated,
+			expectedRemaining: "7",
+		},
+		{
+			name: "weekly super like limit exceeded",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID: targetUserID.String(),
+			requestBody: map[string]interface{}{
+				"response_type": "super_like",
+			},
+			setupMock: func() {
+				profileSvc.EXPECT().
+					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "super_like").
+					Return(testQuota(10, 3), &internal.SuperLikeLimitError{ResetAt: time.Now().Add(time.Hour)})
+			},
+			expectedStatus:    http.StatusTooManyRequests,
+			expectedError:     "weekly super like limit exceeded",
+			expectedRemaining: "7",
+		},
+		{
+			name: "invalid response type",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID: targetUserID.String(),
+			requestBody: map[string]interface{}{
+				"response_type": "invalid",
+			},
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "missing response type",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID:       targetUserID.String(),
+			requestBody:    map[string]interface{}{},
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "already responded",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID: targetUserID.String(),
+			requestBody: map[string]interface{}{
+				"response_type": "like",
+			},
+			setupMock: func() {
+				profileSvc.EXPECT().
+					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
+					Return(nil, internal.ErrConflictingResponse)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedError:  "you already responded to this profile",
+		},
+		{
+			name: "service error",
+			setupContext: func(c echo.Context) {
+				setPrincipal(c, validUserID)
+			},
+			targetID: targetUserID.String(),
+			requestBody: map[string]interface{}{
+				"response_type": "like",
+			},
+			setupMock: func() {
+				profileSvc.EXPECT().
+					CreateProfileResponse(gomock.Any(), validUserID, targetUserID, "like").
+					Return(nil, errors.New("service error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "failed to create response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			c.SetParamNames("id")
+			c.SetParamValues(tt.targetID)
+
+			if tt.setupContext != nil {
+				tt.setupContext(c)
+			}
+			tt.setupMock()
+
+			err := h.CreateProfileResponse(c)
+
+			if err != nil {
+				assert.Error(t, err)
+				var httpError *echo.HTTPError
+				assert.ErrorAs(t, err, &httpError)
+				assert.Equal(t, tt.expectedStatus, httpError.Code)
+				if tt.expectedError != "" {
+					assert.Equal(t, tt.expectedError, httpError.Message)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedStatus, rec.Code)
+			}
+			assert.Equal(t, tt.expectedRemaining, rec.Header().Get("X-Quota-Remaining"))
+		})
+	}
+}
+
+func TestHandler_GetFeatures(t *testing.T) {
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "success",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				features := []*internal.SubscriptionFeature{
+					{
+						ID:          uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+						Name:        "Test Feature",
+						Description: "Test Description",
+					},
+				}
+				svc.EXPECT().
+					GetFeatures(gomock.Any()).
+					Return(features, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id":"550e8400-e29b-41d4-a716-446655440000","name":"Test Feature","description":"Test Description","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}]`,
+		},
+		{
+			name: "internal error",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					GetFeatures(gomock.Any()).
+					Return(nil, errors.New("unexpected error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"message":"failed to get features"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(mockSvc)
+
+			userSvc := mock_service.NewMockUserService(ctrl)
+			profileSvc := mock_service.NewMockProfileService(ctrl)
+			h := NewHandler(userSvc, mockSvc, profileSvc)
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/features", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.GetFeatures(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestHandler_SubscribeToFeature(t *testing.T) {
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	featureID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440002")
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "success",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, req *internal.SubscribeRequest) (*internal.UserFeature, error) {
+						assert.Equal(t, userID, req.UserID)
+						assert.Equal(t, featureID, req.FeatureID)
+						assert.Equal(t, "1_month", req.Period)
+						assert.Equal(t, "fake_success", req.PaymentMethod)
+						return &internal.UserFeature{
+							ID:        uuid.New(),
+							UserID:    req.UserID,
+							FeatureID: req.FeatureID,
+							Value:     5,
+							Status:    "active",
+						}, nil
+					})
+			},
+			requestBody:    `{"period":"1_month","payment_method":"fake_success"}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":"*","user_id":"550e8400-e29b-41d4-a716-446655440001","feature_id":"550e8400-e29b-41d4-a716-446655440002","value":5,"status":"active"}`,
+		},
+		{
+			name: "feature not found",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					Return(nil, internal.ErrFeatureNotFound)
+			},
+			requestBody:    `{"period":"1_month","payment_method":"fake_success"}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message":"feature not found"}`,
+		},
+		{
+			name: "already subscribed",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					Return(nil, internal.ErrFeatureAlreadySubscribed)
+			},
+			requestBody:    `{"period":"1_month","payment_method":"fake_success"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message":"already subscribed to this feature"}`,
+		},
+		{
+			name: "payment pending",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					Return(&internal.UserFeature{
+						ID:        uuid.New(),
+						UserID:    userID,
+						FeatureID: featureID,
+						Value:     5,
+						Status:    internal.FeatureStatusPending,
+					}, nil)
+			},
+			requestBody:    `{"period":"1_month","payment_method":"fake_pending"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "payment declined",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					Return(nil, internal.ErrPaymentDeclined)
+			},
+			requestBody:    `{"period":"1_month","payment_method":"fake_declined"}`,
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   `{"message":"payment declined"}`,
+		},
+		{
+			name:           "missing payment method",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"period":"1_month"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'PaymentMethod' Error:Field validation for 'PaymentMethod' failed on the 'required_without' tag"}`,
+		},
+		{
+			name: "trial",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, req *internal.SubscribeRequest) (*internal.UserFeature, error) {
+						assert.True(t, req.Trial)
+						assert.Empty(t, req.Period)
+						return &internal.UserFeature{
+							ID:        uuid.New(),
+							UserID:    req.UserID,
+							FeatureID: req.FeatureID,
+							Value:     5,
+							Status:    internal.FeatureStatusActive,
+							Source:    internal.FeatureSourceTrial,
+						}, nil
+					})
+			},
+			requestBody:    `{"trial":true}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "trial already used",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("create user feature: %w", internal.ErrTrialAlreadyUsed))
+			},
+			requestBody:    `{"trial":true}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message":"trial already used for this feature"}`,
+		},
+		{
+			name:           "trial with promo code",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"trial":true,"promo_code":"SPRING20"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"promo codes cannot be used with a trial"}`,
+		},
+		{
+			name: "expired promo code",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, req *internal.SubscribeRequest) (*internal.UserFeature, error) {
+						assert.Equal(t, "SPRING20", req.PromoCode)
+						return nil, internal.ErrPromoCodeExpired
+					})
+			},
+			requestBody:    `{"period":"1_month","payment_method":"fake_success","promo_code":"SPRING20"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"promo code has expired"}`,
+		},
+		{
+			name: "promo code already redeemed",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("create promo redemption: %w", internal.ErrPromoCodeAlreadyRedeemed))
+			},
+			requestBody:    `{"period":"1_month","payment_method":"fake_success","promo_code":"SPRING20"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message":"promo code already redeemed"}`,
+		},
+		{
+			name: "no price for period",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SubscribeToFeature(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("get plan price: %w", internal.ErrPlanPriceNotFound))
+			},
+			requestBody:    `{"period":"2_months","payment_method":"fake_success"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"no active price for the requested plan, period and currency"}`,
+		},
+		{
+			name:           "missing period",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"value":5,"payment_method":"fake_success"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'Period' Error:Field validation for 'Period' failed on the 'required_without' tag"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(mockSvc)
+
+			userSvc := mock_service.NewMockUserService(ctrl)
+			profileSvc := mock_service.NewMockProfileService(ctrl)
+			h := NewHandler(userSvc, mockSvc, profileSvc)
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPost, "/features/"+featureID.String(), strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+			c.SetParamNames("id")
+			c.SetParamValues(featureID.String())
+
+			err := h.SubscribeToFeature(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus == http.StatusCreated || tt.expectedStatus == http.StatusAccepted {
+				var response map[string]interface{}
+				err := json.Unmarshal(rec.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, userID.String(), response["user_id"])
+				assert.Equal(t, featureID.String(), response["feature_id"])
+				assert.Equal(t, float64(5), response["value"])
+				if tt.expectedStatus == http.StatusAccepted {
+					assert.Equal(t, "pending", response["status"])
+				} else {
+					assert.Equal(t, "active", response["status"])
+				}
+			} else {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_SetAutoRenew(t *testing.T) {
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	featureID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440002")
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "success",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SetAutoRenew(gomock.Any(), userID, featureID, false).
+					Return(nil)
+			},
+			requestBody:    `{"auto_renew":false}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "no live subscription",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					SetAutoRenew(gomock.Any(), userID, featureID, true).
+					Return(internal.ErrSubscriptionNotFound)
+			},
+			requestBody:    `{"auto_renew":true}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message":"subscription not found"}`,
+		},
+		{
+			name:           "missing auto_renew",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'AutoRenew' Error:Field validation for 'AutoRenew' failed on the 'required' tag"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(mockSvc)
+
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPatch, "/features/"+featureID.String()+"/auto-renew", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+			c.SetParamNames("id")
+			c.SetParamValues(featureID.String())
+
+			err := h.SetAutoRenew(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_CancelSubscription(t *testing.T) {
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	featureID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440002")
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "immediate with refund",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					CancelSubscription(gomock.Any(), &internal.CancelRequest{
+						UserID:    userID,
+						FeatureID: featureID,
+						Refund:    true,
+					}).
+					Return(&internal.CancelResult{
+						Subscription:   &internal.UserFeature{ID: uuid.New(), Status: internal.FeatureStatusCancelled},
+						RefundedAmount: 500,
+						RefundStatus:   internal.RefundStatusSucceeded,
+					}, nil)
+			},
+			requestBody:    `{"mode":"immediate","refund":true}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "at period end",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					CancelSubscription(gomock.Any(), &internal.CancelRequest{
+						UserID:      userID,
+						FeatureID:   featureID,
+						AtPeriodEnd: true,
+					}).
+					Return(&internal.CancelResult{
+						Subscription: &internal.UserFeature{ID: uuid.New(), Status: internal.FeatureStatusPendingCancellation},
+					}, nil)
+			},
+			requestBody:    `{"mode":"period_end"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "refund at period end",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"mode":"period_end","refund":true}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"refunds are only available for immediate cancellation"}`,
+		},
+		{
+			name:           "invalid mode",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"mode":"later"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'Mode' Error:Field validation for 'Mode' failed on the 'oneof' tag"}`,
+		},
+		{
+			name: "no live subscription",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					CancelSubscription(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("get live subscription: %w", internal.ErrSubscriptionNotFound))
+			},
+			requestBody:    `{"mode":"immediate"}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message":"subscription not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(mockSvc)
+
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPost, "/features/"+featureID.String()+"/cancel", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+			c.SetParamNames("id")
+			c.SetParamValues(featureID.String())
+
+			err := h.CancelSubscription(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_ChangePlan(t *testing.T) {
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	featureID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440002")
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "upgrade charged",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					ChangePlan(gomock.Any(), &internal.PlanChangeRequest{
+						UserID:        userID,
+						FeatureID:     featureID,
+						Period:        "12_months",
+						PaymentMethod: "fake_success",
+					}).
+					Return(&internal.UserFeature{ID: uuid.New(), Status: internal.FeatureStatusActive}, nil)
+			},
+			requestBody:    `{"period":"12_months","payment_method":"fake_success"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "payment pending",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					ChangePlan(gomock.Any(), gomock.Any()).
+					Return(&internal.UserFeature{ID: uuid.New(), Status: internal.FeatureStatusPending}, nil)
+			},
+			requestBody:    `{"period":"12_months","payment_method":"fake_pending"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "missing period",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"payment_method":"fake_success"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'Period' Error:Field validation for 'Period' failed on the 'required' tag"}`,
+		},
+		{
+			name: "same plan and period",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					ChangePlan(gomock.Any(), gomock.Any()).
+					Return(nil, internal.ErrPlanUnchanged)
+			},
+			requestBody:    `{"period":"1_month"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"already subscribed to this plan and period"}`,
+		},
+		{
+			name: "payment declined",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					ChangePlan(gomock.Any(), gomock.Any()).
+					Return(nil, internal.ErrPaymentDeclined)
+			},
+			requestBody:    `{"period":"12_months","payment_method":"fake_declined"}`,
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   `{"message":"payment declined"}`,
+		},
+		{
+			name: "subscription awaiting payment",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					ChangePlan(gomock.Any(), gomock.Any()).
+					Return(nil, internal.ErrPlanChangeNotAllowed)
+			},
+			requestBody:    `{"period":"12_months"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message":"subscription is awaiting payment"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(mockSvc)
+
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPost, "/features/"+featureID.String()+"/change", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+			c.SetParamNames("id")
+			c.SetParamValues(featureID.String())
+
+			err := h.ChangePlan(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_PreviewPlanChange(t *testing.T) {
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	featureID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440002")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSvc := mock_service.NewMockFeatureService(ctrl)
+	mockSvc.EXPECT().
+		PreviewPlanChange(gomock.Any(), &internal.PlanChangeRequest{
+			UserID:    userID,
+			FeatureID: featureID,
+			Period:    "12_months",
+		}).
+		Return(&internal.PlanChangePreview{Period: "12_months", Price: 9999, Credit: 500, AmountDue: 9499}, nil)
+
+	h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	req := httptest.NewRequest(http.MethodPost, "/features/"+featureID.String()+"/change/preview", strings.NewReader(`{"period":"12_months"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setPrincipal(c, userID)
+	c.SetParamNames("id")
+	c.SetParamValues(featureID.String())
+
+	assert.NoError(t, h.PreviewPlanChange(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"amount_due":9499`)
+}
+
+func TestHandler_GrantFeature(t *testing.T) {
+	adminID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	featureID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440002")
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		requestBody    string
+		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			name: "success",
 			setupMock: func(svc *mock_service.MockFeatureService) {
-				features := []*internal.SubscriptionFeature{
-					{
-						ID:          uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-						Name:        "Test Feature",
-						Description: "Test Description",
-					},
-				}
 				svc.EXPECT().
-					GetFeatures(gomock.Any()).
-					Return(features, nil)
+					GrantFeature(gomock.Any(), &internal.GrantRequest{
+						UserID:    userID,
+						FeatureID: featureID,
+						Reason:    "QA account",
+						GrantedBy: &adminID,
+					}).
+					Return(&internal.UserFeature{ID: uuid.New(), Status: internal.FeatureStatusActive, Source: internal.FeatureSourceGrant}, nil)
 			},
-			expectedStatus: http.StatusOK,
-			expectedBody:   `[{"id":"550e8400-e29b-41d4-a716-446655440000","name":"Test Feature","description":"Test Description","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}]`,
+			requestBody:    `{"feature_id":"` + featureID.String() + `","reason":"QA account"}`,
+			expectedStatus: http.StatusCreated,
 		},
 		{
-			name: "internal error",
+			name:           "missing reason",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"feature_id":"` + featureID.String() + `"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'Reason' Error:Field validation for 'Reason' failed on the 'required' tag"}`,
+		},
+		{
+			name:           "expiry in the past",
+			setupMock:      func(svc *mock_service.MockFeatureService) {},
+			requestBody:    `{"feature_id":"` + featureID.String() + `","reason":"compensation","expires_at":"2020-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"expires_at must be in the future"}`,
+		},
+		{
+			name: "already has the feature",
 			setupMock: func(svc *mock_service.MockFeatureService) {
 				svc.EXPECT().
-					GetFeatures(gomock.Any()).
-					Return(nil, errors.New("unexpected error"))
+					GrantFeature(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("create user feature: %w", internal.ErrFeatureAlreadySubscribed))
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"message":"failed to get features"}`,
+			requestBody:    `{"feature_id":"` + featureID.String() + `","reason":"influencer"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message":"user already has this feature"}`,
 		},
 	}
 
@@ -663,15 +2038,19 @@ func TestHandler_GetFeatures(t *testing.T) {
 			mockSvc := mock_service.NewMockFeatureService(ctrl)
 			tt.setupMock(mockSvc)
 
-			userSvc := mock_service.NewMockUserService(ctrl)
-			profileSvc := mock_service.NewMockProfileService(ctrl)
-			h := NewHandler(userSvc, mockSvc, profileSvc)
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
 			e := echo.New()
-			req := httptest.NewRequest(http.MethodGet, "/features", nil)
+			e.Validator = &CustomValidator{validator: validator.New()}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/users/"+userID.String()+"/grants", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
+			setPrincipal(c, adminID)
+			c.SetParamNames("id")
+			c.SetParamValues(userID.String())
 
-			err := h.GetFeatures(c)
+			err := h.GrantFeature(c)
 			if err != nil {
 				he, ok := err.(*echo.HTTPError)
 				assert.True(t, ok)
@@ -681,19 +2060,17 @@ func TestHandler_GetFeatures(t *testing.T) {
 			}
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
-			assert.JSONEq(t, tt.expectedBody, rec.Body.String())
 		})
 	}
 }
 
-func TestHandler_SubscribeToFeature(t *testing.T) {
+func TestHandler_RevokeGrant(t *testing.T) {
 	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
 	featureID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440002")
 
 	tests := []struct {
 		name           string
 		setupMock      func(svc *mock_service.MockFeatureService)
-		requestBody    string
 		expectedStatus int
 		expectedBody   string
 	}{
@@ -701,46 +2078,196 @@ func TestHandler_SubscribeToFeature(t *testing.T) {
 			name: "success",
 			setupMock: func(svc *mock_service.MockFeatureService) {
 				svc.EXPECT().
-					SubscribeToFeature(gomock.Any(), gomock.Any(), "1_month").
-					DoAndReturn(func(_ context.Context, uf *internal.UserFeature, _ string) error {
-						assert.Equal(t, userID, uf.UserID)
-						assert.Equal(t, featureID, uf.FeatureID)
-						assert.Equal(t, 5, uf.Value)
-						return nil
-					})
+					RevokeGrant(gomock.Any(), userID, featureID).
+					Return(&internal.UserFeature{ID: uuid.New(), Status: internal.FeatureStatusRevoked}, nil)
 			},
-			requestBody:    `{"period":"1_month","value":5}`,
-			expectedStatus: http.StatusCreated,
-			expectedBody:   `{"id":"*","user_id":"550e8400-e29b-41d4-a716-446655440001","feature_id":"550e8400-e29b-41d4-a716-446655440002","value":5,"status":"active"}`,
+			expectedStatus: http.StatusOK,
 		},
 		{
-			name: "feature not found",
+			name: "no grant",
 			setupMock: func(svc *mock_service.MockFeatureService) {
 				svc.EXPECT().
-					SubscribeToFeature(gomock.Any(), gomock.Any(), "1_month").
-					Return(internal.ErrFeatureNotFound)
+					RevokeGrant(gomock.Any(), userID, featureID).
+					Return(nil, fmt.Errorf("revoke grant: %w", internal.ErrGrantNotFound))
 			},
-			requestBody:    `{"period":"1_month","value":5}`,
 			expectedStatus: http.StatusNotFound,
-			expectedBody:   `{"message":"feature not found"}`,
+			expectedBody:   `{"message":"grant not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(mockSvc)
+
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/users/"+userID.String()+"/grants/"+featureID.String(), nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id", "feature_id")
+			c.SetParamValues(userID.String(), featureID.String())
+
+			err := h.RevokeGrant(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_GetReceipt(t *testing.T) {
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	invoiceID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440003")
+	r := &internal.Receipt{
+		Invoice: &internal.Invoice{
+			ID:          invoiceID,
+			Number:      7,
+			UserID:      userID,
+			Kind:        internal.InvoiceKindCharge,
+			Description: "daily_responses subscription, 1_month",
+			Amount:      999,
+			Currency:    "USD",
 		},
+		Name:  "Jane",
+		Email: "jane@example.com",
+	}
+
+	tests := []struct {
+		name                string
+		query               string
+		setupMock           func(svc *mock_service.MockFeatureService)
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
 		{
-			name: "already subscribed",
+			name: "html by default",
 			setupMock: func(svc *mock_service.MockFeatureService) {
-				svc.EXPECT().
-					SubscribeToFeature(gomock.Any(), gomock.Any(), "1_month").
-					Return(internal.ErrFeatureAlreadySubscribed)
+				svc.EXPECT().GetReceipt(gomock.Any(), userID, invoiceID).Return(r, nil)
 			},
-			requestBody:    `{"period":"1_month","value":5}`,
-			expectedStatus: http.StatusConflict,
-			expectedBody:   `{"message":"already subscribed to this feature"}`,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: echo.MIMETextHTMLCharsetUTF8,
+		},
+		{
+			name:  "pdf",
+			query: "?format=pdf",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().GetReceipt(gomock.Any(), userID, invoiceID).Return(r, nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/pdf",
 		},
 		{
-			name:           "invalid period",
+			name:           "unknown format",
+			query:          "?format=docx",
 			setupMock:      func(svc *mock_service.MockFeatureService) {},
-			requestBody:    `{"period":"invalid"}`,
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"message":"Key: 'Period' Error:Field validation for 'Period' failed on the 'oneof' tag"}`,
+			expectedBody:   `{"message":"format must be html or pdf"}`,
+		},
+		{
+			name: "other user's invoice",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					GetReceipt(gomock.Any(), userID, invoiceID).
+					Return(nil, fmt.Errorf("get invoice: %w", internal.ErrInvoiceNotFound))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message":"invoice not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockSvc := mock_service.NewMockFeatureService(ctrl)
+			tt.setupMock(mockSvc)
+
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/me/billing/"+invoiceID.String()+"/receipt"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setPrincipal(c, userID)
+			c.SetParamNames("id")
+			c.SetParamValues(invoiceID.String())
+
+			err := h.GetReceipt(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get(echo.HeaderContentType))
+			assert.NotEmpty(t, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestHandler_PaymentWebhook(t *testing.T) {
+	payload := `{"id":"evt_1","type":"payment.paid","payment_id":"fake_pay_1"}`
+
+	tests := []struct {
+		name           string
+		setupMock      func(svc *mock_service.MockFeatureService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "success",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					HandlePaymentWebhook(gomock.Any(), []byte(payload), "sha256=abc").
+					Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "invalid signature",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					HandlePaymentWebhook(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(internal.ErrInvalidWebhookSignature)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"message":"invalid signature"}`,
+		},
+		{
+			name: "unknown payment",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					HandlePaymentWebhook(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("get payment: %w", internal.ErrPaymentNotFound))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message":"payment not found"}`,
+		},
+		{
+			name: "invalid payload",
+			setupMock: func(svc *mock_service.MockFeatureService) {
+				svc.EXPECT().
+					HandlePaymentWebhook(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(internal.ErrInvalidWebhookPayload)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"invalid payload"}`,
 		},
 	}
 
@@ -752,21 +2279,112 @@ func TestHandler_SubscribeToFeature(t *testing.T) {
 			mockSvc := mock_service.NewMockFeatureService(ctrl)
 			tt.setupMock(mockSvc)
 
+			h := NewHandler(mock_service.NewMockUserService(ctrl), mockSvc, mock_service.NewMockProfileService(ctrl))
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/payments", strings.NewReader(payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("X-Payment-Signature", "sha256=abc")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.PaymentWebhook(c)
+			if err != nil {
+				he, ok := err.(*echo.HTTPError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				assert.Equal(t, tt.expectedBody, fmt.Sprintf(`{"message":"%v"}`, he.Message))
+				return
+			}
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestHandler_CompleteOAuthLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		setupMock      func(svc *mock_service.MockUserService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "existing account",
+			requestBody: `{"state":"state-1","code":"code-1"}`,
+			setupMock: func(svc *mock_service.MockUserService) {
+				svc.EXPECT().
+					CompleteOAuthLogin(gomock.Any(), "fake", &internal.OAuthCallback{State: "state-1", Code: "code-1"}).
+					Return(&internal.LoginResult{Token: "valid.jwt.token"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"token":"valid.jwt.token"}`,
+		},
+		{
+			name:        "new account without profile",
+			requestBody: `{"state":"state-1","code":"code-1"}`,
+			setupMock: func(svc *mock_service.MockUserService) {
+				svc.EXPECT().
+					CompleteOAuthLogin(gomock.Any(), "fake", gomock.Any()).
+					Return(nil, internal.ErrProfileIncomplete)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"birth_date and gender are required to create an account"}`,
+		},
+		{
+			name:        "unverified email",
+			requestBody: `{"state":"state-1","code":"code-1"}`,
+			setupMock: func(svc *mock_service.MockUserService) {
+				svc.EXPECT().
+					CompleteOAuthLogin(gomock.Any(), "fake", gomock.Any()).
+					Return(nil, internal.ErrOAuthEmailNotVerified)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"message":"email address is not verified by the provider"}`,
+		},
+		{
+			name:        "replayed state",
+			requestBody: `{"state":"state-1","code":"code-1"}`,
+			setupMock: func(svc *mock_service.MockUserService) {
+				svc.EXPECT().
+					CompleteOAuthLogin(gomock.Any(), "fake", gomock.Any()).
+					Return(nil, internal.ErrInvalidOAuthState)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"invalid or expired oauth state"}`,
+		},
+		{
+			name:           "missing code",
+			requestBody:    `{"state":"state-1"}`,
+			setupMock:      func(svc *mock_service.MockUserService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"Key: 'OAuthCallbackRequest.Code' Error:Field validation for 'Code' failed on the 'required' tag"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
 			userSvc := mock_service.NewMockUserService(ctrl)
+			tt.setupMock(userSvc)
+
+			featureSvc := mock_service.NewMockFeatureService(ctrl)
 			profileSvc := mock_service.NewMockProfileService(ctrl)
-			h := NewHandler(userSvc, mockSvc, profileSvc)
+			h := NewHandler(userSvc, featureSvc, profileSvc)
 			e := echo.New()
 			e.Validator = &CustomValidator{validator: validator.New()}
 
-			req := httptest.NewRequest(http.MethodPost, "/features/"+featureID.String(), strings.NewReader(tt.requestBody))
+			req := httptest.NewRequest(http.MethodPost, "/oauth/fake/callback", strings.NewReader(tt.requestBody))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
-			c.Set("user_id", userID.String())
-			c.SetParamNames("id")
-			c.SetParamValues(featureID.String())
+			c.SetParamNames("provider")
+			c.SetParamValues("fake")
 
-			err := h.SubscribeToFeature(c)
+			err := h.CompleteOAuthLogin(c)
 			if err != nil {
 				he, ok := err.(*echo.HTTPError)
 				assert.True(t, ok)
@@ -776,17 +2394,7 @@ func TestHandler_SubscribeToFeature(t *testing.T) {
 			}
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
-			if tt.expectedStatus == http.StatusCreated {
-				var response map[string]interface{}
-				err := json.Unmarshal(rec.Body.Bytes(), &response)
-				assert.NoError(t, err)
-				assert.Equal(t, userID.String(), response["user_id"])
-				assert.Equal(t, featureID.String(), response["feature_id"])
-				assert.Equal(t, float64(5), response["value"])
-				assert.Equal(t, "active", response["status"])
-			} else {
-				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
-			}
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String())
 		})
 	}
 }
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"datingapp/internal"
+	"datingapp/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireAdmin only lets admins through. The flag is read from the database
+// on every request so that removing an admin takes effect immediately.
+func RequireAdmin(repo repository.Repository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := internal.PrincipalFromContext(c.Request().Context())
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+			}
+
+			user, err := repo.GetUserByID(c.Request().Context(), principal.UserID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user")
+			}
+			if !user.IsAdmin {
+				return echo.NewHTTPError(http.StatusForbidden, "admin access required")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"datingapp/internal"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	userID := uuid.MustParse("123e4567-e89b-12d3-a456-426614174000")
+
+	tests := []struct {
+		name           string
+		principal      bool
+		setupMock      func(repo *mock_repository.MockRepository)
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:      "admin",
+			principal: true,
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&internal.User{ID: userID, IsAdmin: true}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:      "not an admin",
+			principal: true,
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&internal.User{ID: userID}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "admin access required",
+		},
+		{
+			name:           "unauthenticated",
+			setupMock:      func(repo *mock_repository.MockRepository) {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			tt.setupMock(repo)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.principal {
+				req = req.WithContext(internal.WithPrincipal(req.Context(), &internal.Principal{UserID: userID}))
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			h := RequireAdmin(repo)(func(c echo.Context) error {
+				return c.String(http.StatusOK, "test")
+			})
+
+			err := h(c)
+			if tt.expectedError != "" {
+				he, ok := err.(*echo.HTTPError)
+				if assert.True(t, ok) {
+					assert.Equal(t, tt.expectedStatus, he.Code)
+					assert.Equal(t, tt.expectedError, he.Message)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+}
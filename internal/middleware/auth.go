@@ -1,15 +1,20 @@
 package middleware
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"datingapp/internal"
+	"datingapp/internal/auth"
+
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-func JWTMiddleware(jwtSecret string) echo.MiddlewareFunc {
+// JWTMiddleware authenticates requests with a bearer access token and stores
+// the resulting internal.Principal in the request context.
+func JWTMiddleware(tokens *auth.Tokens) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
@@ -22,24 +27,21 @@ func JWTMiddleware(jwtSecret string) echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid authorization header format")
 			}
 
-			token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(jwtSecret), nil
-			})
-
+			claims, err := tokens.ParseAccessToken(parts[1])
 			if err != nil {
+				if errors.Is(err, auth.ErrInvalidClaims) {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid token claims")
+				}
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
 			}
 
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok || !token.Valid {
-				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token claims")
+			principal := &internal.Principal{
+				UserID: uuid.MustParse(claims.UserID),
+				Email:  claims.Email,
 			}
 
-			c.Set("user_id", claims["user_id"])
-			c.Set("email", claims["email"])
+			ctx := internal.WithPrincipal(c.Request().Context(), principal)
+			c.SetRequest(c.Request().WithContext(ctx))
 
 			return next(c)
 		}
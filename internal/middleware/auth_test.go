@@ -6,11 +6,39 @@ import (
 	"testing"
 	"time"
 
+	"datingapp/internal"
+	"datingapp/internal/auth"
+
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
+const (
+	testIssuer   = "datingapp"
+	testAudience = "datingapp-api"
+)
+
+func signClaims(t *testing.T, method jwt.SigningMethod, key interface{}, claims auth.Claims) string {
+	t.Helper()
+	tokenString, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.NoError(t, err)
+	return tokenString
+}
+
+func validClaims() auth.Claims {
+	return auth.Claims{
+		UserID: "123e4567-e89b-12d3-a456-426614174000",
+		Email:  "test@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
 func TestJWTMiddleware(t *testing.T) {
 	e := echo.New()
 	handler := func(c echo.Context) error {
@@ -18,6 +46,7 @@ func TestJWTMiddleware(t *testing.T) {
 	}
 
 	jwtSecret := "test-secret"
+	tokens := auth.NewTokens(auth.NewHMACKeySet(jwtSecret), testIssuer, testAudience)
 
 	tests := []struct {
 		name           string
@@ -28,26 +57,16 @@ func TestJWTMiddleware(t *testing.T) {
 		{
 			name: "valid token",
 			setupAuth: func() string {
-				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-					"user_id": "123e4567-e89b-12d3-a456-426614174000",
-					"email":   "test@example.com",
-					"exp":     time.Now().Add(time.Hour).Unix(),
-				})
-				tokenString, _ := token.SignedString([]byte(jwtSecret))
-				return "Bearer " + tokenString
+				return "Bearer " + signClaims(t, jwt.SigningMethodHS256, []byte(jwtSecret), validClaims())
 			},
 			expectedStatus: http.StatusOK,
 		},
 		{
 			name: "expired token",
 			setupAuth: func() string {
-				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-					"user_id": "123e4567-e89b-12d3-a456-426614174000",
-					"email":   "test@example.com",
-					"exp":     time.Now().Add(-time.Hour).Unix(),
-				})
-				tokenString, _ := token.SignedString([]byte(jwtSecret))
-				return "Bearer " + tokenString
+				claims := validClaims()
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				return "Bearer " + signClaims(t, jwt.SigningMethodHS256, []byte(jwtSecret), claims)
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedError:  "invalid token",
@@ -76,16 +95,60 @@ func TestJWTMiddleware(t *testing.T) {
 			expectedStatus: http.StatusUnauthorized,
 			expectedError:  "invalid token",
 		},
+		{
+			name: "wrong issuer",
+			setupAuth: func() string {
+				claims := validClaims()
+				claims.Issuer = "someone-else"
+				return "Bearer " + signClaims(t, jwt.SigningMethodHS256, []byte(jwtSecret), claims)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid token",
+		},
+		{
+			name: "wrong audience",
+			setupAuth: func() string {
+				claims := validClaims()
+				claims.Audience = jwt.ClaimStrings{"another-api"}
+				return "Bearer " + signClaims(t, jwt.SigningMethodHS256, []byte(jwtSecret), claims)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid token",
+		},
+		{
+			name: "missing expiry",
+			setupAuth: func() string {
+				claims := validClaims()
+				claims.ExpiresAt = nil
+				return "Bearer " + signClaims(t, jwt.SigningMethodHS256, []byte(jwtSecret), claims)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid token",
+		},
+		{
+			name: "missing user ID",
+			setupAuth: func() string {
+				claims := validClaims()
+				claims.UserID = ""
+				return "Bearer " + signClaims(t, jwt.SigningMethodHS256, []byte(jwtSecret), claims)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid token claims",
+		},
+		{
+			name: "two-factor challenge token",
+			setupAuth: func() string {
+				claims := validClaims()
+				claims.Purpose = "2fa"
+				return "Bearer " + signClaims(t, jwt.SigningMethodHS256, []byte(jwtSecret), claims)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid token claims",
+		},
 		{
 			name: "wrong signing method",
 			setupAuth: func() string {
-				token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
-					"user_id": "123e4567-e89b-12d3-a456-426614174000",
-					"email":   "test@example.com",
-					"exp":     time.Now().Add(time.Hour).Unix(),
-				})
-				tokenString, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
-				return "Bearer " + tokenString
+				return "Bearer " + signClaims(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims())
 			},
 			expectedStatus: http.StatusUnauthorized,
 			expectedError:  "invalid token",
@@ -102,7 +165,7 @@ func TestJWTMiddleware(t *testing.T) {
 				req.Header.Set(echo.HeaderAuthorization, auth)
 			}
 
-			middleware := JWTMiddleware(jwtSecret)
+			middleware := JWTMiddleware(tokens)
 			h := middleware(handler)
 
 			err := h(c)
@@ -120,42 +183,42 @@ func TestJWTMiddleware(t *testing.T) {
 				assert.Equal(t, tt.expectedStatus, rec.Code)
 
 				if rec.Code == http.StatusOK {
-					assert.NotNil(t, c.Get("user_id"))
-					assert.NotNil(t, c.Get("email"))
+					_, ok := internal.PrincipalFromContext(c.Request().Context())
+					assert.True(t, ok)
 				}
 			}
 		})
 	}
 }
 
-func TestJWTMiddleware_ContextValues(t *testing.T) {
+func TestJWTMiddleware_Principal(t *testing.T) {
 	e := echo.New()
 	jwtSecret := "test-secret"
-	expectedUserID := "123e4567-e89b-12d3-a456-426614174000"
+	tokens := auth.NewTokens(auth.NewHMACKeySet(jwtSecret), testIssuer, testAudience)
+	expectedUserID := uuid.MustParse("123e4567-e89b-12d3-a456-426614174000")
 	expectedEmail := "test@example.com"
 
 	handler := func(c echo.Context) error {
-		assert.Equal(t, expectedUserID, c.Get("user_id"))
-		assert.Equal(t, expectedEmail, c.Get("email"))
+		principal, ok := internal.PrincipalFromContext(c.Request().Context())
+		if assert.True(t, ok) {
+			assert.Equal(t, expectedUserID, principal.UserID)
+			assert.Equal(t, expectedEmail, principal.Email)
+		}
 		return c.String(http.StatusOK, "test")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": expectedUserID,
-		"email":   expectedEmail,
-		"exp":     time.Now().Add(time.Hour).Unix(),
-	})
-	tokenString, _ := token.SignedString([]byte(jwtSecret))
+	tokenString, err := tokens.IssueAccessToken(expectedUserID, expectedEmail)
+	assert.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	middleware := JWTMiddleware(jwtSecret)
+	middleware := JWTMiddleware(tokens)
 	h := middleware(handler)
 
-	err := h(c)
+	err = h(c)
 
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
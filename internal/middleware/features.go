@@ -3,38 +3,91 @@ package middleware
 import (
 	"datingapp/internal"
 	"datingapp/internal/repository"
+	"fmt"
+	"log"
 	"net/http"
 
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 func ActiveFeatures(repo repository.Repository) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			userID := c.Get("user_id")
-			if userID == nil {
+			principal, ok := internal.PrincipalFromContext(c.Request().Context())
+			if !ok {
 				return next(c)
 			}
 
-			uidStr, ok := userID.(string)
-			if !ok || uidStr == "" {
-				return next(c)
+			features, err := repo.GetUserFeatures(c.Request().Context(), principal.UserID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user features")
 			}
 
-			uid, err := uuid.Parse(uidStr)
-			if err != nil {
+			ctx := internal.SetActiveFeatures(c.Request().Context(), features)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// Upsell is the body of responses to requests missing a required feature.
+// Plans lists the active plans that sell the feature.
+type Upsell struct {
+	Message     string           `json:"message"`
+	Feature     string           `json:"feature"`
+	Description string           `json:"description"`
+	Plans       []*internal.Plan `json:"plans,omitempty"`
+}
+
+// RequireFeature only lets through users with the named feature active. It
+// must run after ActiveFeatures. Users without it get FeatureRequired. It
+// panics if the feature is not in the registry, so that a typo fails at
+// startup.
+func RequireFeature(repo repository.Repository, name string) echo.MiddlewareFunc {
+	if _, ok := internal.LookupFeature(name); !ok {
+		panic(fmt.Sprintf("unknown feature %q", name))
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if internal.HasFeature(c.Request().Context(), name) {
 				return next(c)
 			}
 
-			features, err := repo.GetUserFeatures(c.Request().Context(), uid)
+			plans, err := repo.GetPlans(c.Request().Context())
 			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user features")
+				log.Printf("failed to get plans for %s upsell: %v", name, err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get plans")
 			}
 
-			ctx := internal.SetActiveFeatures(c.Request().Context(), features)
-			c.SetRequest(c.Request().WithContext(ctx))
-			return next(c)
+			return FeatureRequired(name, plans)
 		}
 	}
 }
+
+// FeatureRequired is the error for a request missing the named feature: 402
+// Payment Required with an Upsell listing the plans that sell it, or 403
+// Forbidden when none of plans does.
+func FeatureRequired(name string, plans []*internal.Plan) *echo.HTTPError {
+	definition, _ := internal.LookupFeature(name)
+	upsell := &Upsell{
+		Message:     "feature not available",
+		Feature:     name,
+		Description: definition.Description,
+	}
+	for _, plan := range plans {
+		for _, pf := range plan.Features {
+			if pf.FeatureName == name {
+				upsell.Plans = append(upsell.Plans, plan)
+				break
+			}
+		}
+	}
+
+	if len(upsell.Plans) == 0 {
+		return echo.NewHTTPError(http.StatusForbidden, upsell)
+	}
+
+	upsell.Message = "subscription required"
+	return echo.NewHTTPError(http.StatusPaymentRequired, upsell)
+}
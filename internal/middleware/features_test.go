@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"datingapp/internal"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequireFeature(t *testing.T) {
+	premium := &internal.Plan{
+		Name:     "premium",
+		Features: []*internal.PlanFeature{{FeatureName: internal.FeatureDailyResponses}},
+	}
+	other := &internal.Plan{
+		Name:     "other",
+		Features: []*internal.PlanFeature{{FeatureName: "something_else"}},
+	}
+
+	tests := []struct {
+		name           string
+		features       []*internal.UserFeature
+		setupMock      func(repo *mock_repository.MockRepository)
+		expectedStatus int
+		expectedPlans  []*internal.Plan
+	}{
+		{
+			name:           "feature active",
+			features:       []*internal.UserFeature{{FeatureName: internal.FeatureDailyResponses}},
+			setupMock:      func(repo *mock_repository.MockRepository) {},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "sold by a plan",
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().GetPlans(gomock.Any()).Return([]*internal.Plan{premium, other}, nil)
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedPlans:  []*internal.Plan{premium},
+		},
+		{
+			name: "not for sale",
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().GetPlans(gomock.Any()).Return([]*internal.Plan{other}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			tt.setupMock(repo)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(internal.SetActiveFeatures(req.Context(), tt.features))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			h := RequireFeature(repo, internal.FeatureDailyResponses)(func(c echo.Context) error {
+				return c.String(http.StatusOK, "test")
+			})
+
+			err := h(c)
+			if tt.expectedStatus == http.StatusOK {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, rec.Code)
+				return
+			}
+
+			he, ok := err.(*echo.HTTPError)
+			if assert.True(t, ok) {
+				assert.Equal(t, tt.expectedStatus, he.Code)
+				upsell, ok := he.Message.(*Upsell)
+				if assert.True(t, ok) {
+					assert.Equal(t, internal.FeatureDailyResponses, upsell.Feature)
+					assert.Equal(t, tt.expectedPlans, upsell.Plans)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireFeature_UnknownFeature(t *testing.T) {
+	assert.Panics(t, func() {
+		RequireFeature(nil, "no_such_feature")
+	})
+}
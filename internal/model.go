@@ -14,8 +14,97 @@ type User struct {
 	Bio          string    `json:"bio" db:"bio"`
 	BirthDate    time.Time `json:"birth_date" db:"birth_date"`
 	Gender       string    `json:"gender" db:"gender"`
+	Timezone     string    `json:"-" db:"timezone"`
+	TOTPSecret   *string   `json:"-" db:"totp_secret"`
+	TOTPEnabled  bool      `json:"-" db:"totp_enabled"`
+	IsAdmin      bool      `json:"-" db:"is_admin"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+
+	// PreviousTimezone is the time zone daily limits reset in before the
+	// change of time zone at TimezoneChangedAt.
+	PreviousTimezone  *string    `json:"-" db:"previous_timezone"`
+	TimezoneChangedAt *time.Time `json:"-" db:"timezone_changed_at"`
+}
+
+// Candidate is a user offered to the viewer in their deck. Visiting tells
+// whether the candidate is browsing from a passport location, in
+// VisitingCity. DistanceKm is how far they are from the viewer when both have
+// a location, rounded up to a coarse bucket so that it can't be used to
+// locate them.
+type Candidate struct {
+	User
+	Visiting     bool    `json:"visiting" db:"visiting"`
+	VisitingCity *string `json:"visiting_city,omitempty" db:"visiting_city"`
+	DistanceKm   *int    `json:"distance_km,omitempty" db:"distance_km"`
+}
+
+// Location is a point on Earth. City names the city of a passport location
+// when known; real locations are stored without one.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	City      string  `json:"city,omitempty"`
+}
+
+// Quota is the state of a user's daily response limit, which resets at
+// ResetAt. Limit and Remaining are nil when responses are unlimited.
+// SuperLikes is the state of the weekly super like limit.
+type Quota struct {
+	Limit      *int      `json:"limit"`
+	Used       int       `json:"used"`
+	Remaining  *int      `json:"remaining"`
+	ResetAt    time.Time `json:"reset_at"`
+	SuperLikes *Quota    `json:"super_likes,omitempty"`
+}
+
+// LoginResult holds either a full access token or, when the user has two-factor
+// authentication enabled, a short-lived challenge token to be exchanged for one.
+type LoginResult struct {
+	Token          string `json:"token,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+type LoginAttempt struct {
+	Key          string     `json:"key" db:"key"`
+	FailedCount  int        `json:"failed_count" db:"failed_count"`
+	LastFailedAt *time.Time `json:"last_failed_at" db:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until" db:"locked_until"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type OAuthState struct {
+	State        string    `json:"state" db:"state"`
+	Provider     string    `json:"provider" db:"provider"`
+	CodeVerifier string    `json:"-" db:"code_verifier"`
+	Nonce        string    `json:"-" db:"nonce"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// OAuthCallback carries the provider redirect parameters. BirthDate and Gender
+// are only used when the sign-in creates a new account.
+type OAuthCallback struct {
+	State     string
+	Code      string
+	BirthDate *time.Time
+	Gender    string
+}
+
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
 }
 
 type SubscriptionFeature struct {
@@ -26,6 +115,35 @@ type SubscriptionFeature struct {
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// User feature sources.
+const (
+	FeatureSourcePurchase = "purchase"
+	FeatureSourceTrial    = "trial"
+	FeatureSourceGrant    = "grant"
+)
+
+// User feature statuses. A purchased subscription only becomes active once
+// its payment has succeeded. An auto-renewing subscription whose renewal has
+// not been paid by its end date is past due, and stays usable until its grace
+// period ends; it then expires like one that does not auto-renew. One
+// cancelled at period end stays usable until its end date.
+const (
+	FeatureStatusActive              = "active"
+	FeatureStatusPending             = "pending"
+	FeatureStatusPastDue             = "past_due"
+	FeatureStatusExpired             = "expired"
+	FeatureStatusPendingCancellation = "pending_cancellation"
+	FeatureStatusCancelled           = "cancelled"
+	FeatureStatusPaymentFailed       = "payment_failed"
+	FeatureStatusRefunded            = "refunded"
+	FeatureStatusChargedBack         = "charged_back"
+	FeatureStatusReplaced            = "replaced"
+	FeatureStatusPendingChange       = "pending_change"
+	FeatureStatusRevoked             = "revoked"
+)
+
+// UserFeature is a feature a user subscribed to, trialled or was granted.
+// Value is the quota it grants, Unlimited for no limit.
 type UserFeature struct {
 	ID                 uuid.UUID  `json:"id" db:"id"`
 	UserID             uuid.UUID  `json:"user_id" db:"user_id"`
@@ -34,12 +152,292 @@ type UserFeature struct {
 	StartDate          time.Time  `json:"start_date" db:"start_date"`
 	EndDate            *time.Time `json:"end_date" db:"end_date"`
 	Status             string     `json:"status" db:"status"`
+	AutoRenew          bool       `json:"auto_renew" db:"auto_renew"`
+	GraceUntil         *time.Time `json:"grace_until" db:"grace_until"`
+	CancelledAt        *time.Time `json:"cancelled_at" db:"cancelled_at"`
+	Source             string     `json:"source" db:"source"`
+	GrantReason        *string    `json:"grant_reason,omitempty" db:"grant_reason"`
+	GrantedBy          *uuid.UUID `json:"granted_by,omitempty" db:"granted_by"`
+	PlanID             *uuid.UUID `json:"plan_id" db:"plan_id"`
+	PlanPriceID        *uuid.UUID `json:"plan_price_id" db:"plan_price_id"`
+	BundleID           *uuid.UUID `json:"bundle_id" db:"bundle_id"`
+	ReplacesID         *uuid.UUID `json:"replaces_id,omitempty" db:"replaces_id"`
+	PriceAmount        int64      `json:"price_amount" db:"price_amount"`
+	Currency           string     `json:"currency" db:"currency"`
 	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
 	FeatureName        string     `json:"feature_name" db:"feature_name"`
 	FeatureDescription string     `json:"feature_description" db:"feature_description"`
 }
 
+type Plan struct {
+	ID          uuid.UUID      `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Description string         `json:"description" db:"description"`
+	Active      bool           `json:"active" db:"active"`
+	TrialDays   int            `json:"trial_days" db:"trial_days"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
+	Features    []*PlanFeature `json:"features" db:"-"`
+	Prices      []*PlanPrice   `json:"prices" db:"-"`
+}
+
+type PlanFeature struct {
+	PlanID      uuid.UUID `json:"plan_id" db:"plan_id"`
+	FeatureID   uuid.UUID `json:"feature_id" db:"feature_id"`
+	FeatureName string    `json:"feature_name" db:"feature_name"`
+	Value       int       `json:"value" db:"value"`
+}
+
+// PlanPrice is the price of a plan for one billing period. Amount is in the
+// currency's minor unit.
+type PlanPrice struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	PlanID       uuid.UUID `json:"plan_id" db:"plan_id"`
+	Period       string    `json:"period" db:"period"`
+	PeriodMonths int       `json:"period_months" db:"period_months"`
+	Amount       int64     `json:"amount" db:"amount"`
+	Currency     string    `json:"currency" db:"currency"`
+	Active       bool      `json:"active" db:"active"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SubscribeRequest asks for a feature to be subscribed to for a period. When
+// PlanID is nil the cheapest active plan bundling the feature is used. A
+// Trial request instead starts the free trial of a plan, and needs neither a
+// period nor a payment.
+type SubscribeRequest struct {
+	UserID    uuid.UUID
+	FeatureID uuid.UUID
+	PlanID    *uuid.UUID
+	Period    string
+	Currency  string
+	// PaymentMethod is the provider token used to pay for the subscription.
+	PaymentMethod string
+	PromoCode     string
+	Trial         bool
+}
+
+// GrantRequest gives a user a feature without payment, for example as
+// compensation. A nil Value grants the feature's premium quota and a nil
+// ExpiresAt grants it until revoked. GrantedBy is the admin making the grant,
+// nil when made from the command line.
+type GrantRequest struct {
+	UserID    uuid.UUID
+	FeatureID uuid.UUID
+	Value     *int
+	Reason    string
+	ExpiresAt *time.Time
+	GrantedBy *uuid.UUID
+}
+
+// PlanChangeRequest asks for a live subscription to be moved to another plan
+// or period. When PlanID is nil the cheapest active plan bundling the feature
+// is used.
+type PlanChangeRequest struct {
+	UserID        uuid.UUID
+	FeatureID     uuid.UUID
+	PlanID        *uuid.UUID
+	Period        string
+	Currency      string
+	PaymentMethod string
+}
+
+// PlanChangePreview prices a plan change. Credit is the unused share of the
+// current subscription's payment: it is taken off Price, and whatever is left
+// over is refunded. Amounts are in minor units.
+type PlanChangePreview struct {
+	Current     *UserFeature `json:"current"`
+	PlanID      uuid.UUID    `json:"plan_id"`
+	PlanPriceID uuid.UUID    `json:"plan_price_id"`
+	Period      string       `json:"period"`
+	Currency    string       `json:"currency"`
+	Price       int64        `json:"price"`
+	Credit      int64        `json:"credit"`
+	AmountDue   int64        `json:"amount_due"`
+	Refund      int64        `json:"refund"`
+	StartDate   time.Time    `json:"start_date"`
+	EndDate     time.Time    `json:"end_date"`
+}
+
+// Promo code discount types.
+const (
+	DiscountTypePercent = "percent"
+	DiscountTypeFixed   = "fixed"
+)
+
+// PromoCode discounts plan purchases. DiscountValue is a percentage for
+// percent codes and an amount in Currency's minor unit for fixed ones. A code
+// without PlanIDs applies to every plan.
+type PromoCode struct {
+	ID              uuid.UUID   `json:"id" db:"id"`
+	Code            string      `json:"code" db:"code"`
+	DiscountType    string      `json:"discount_type" db:"discount_type"`
+	DiscountValue   int64       `json:"discount_value" db:"discount_value"`
+	Currency        string      `json:"currency" db:"currency"`
+	MaxRedemptions  *int        `json:"max_redemptions" db:"max_redemptions"`
+	RedemptionCount int         `json:"redemption_count" db:"redemption_count"`
+	ExpiresAt       *time.Time  `json:"expires_at" db:"expires_at"`
+	Active          bool        `json:"active" db:"active"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+	PlanIDs         []uuid.UUID `json:"plan_ids" db:"-"`
+}
+
+type PromoRedemption struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	PromoCodeID    uuid.UUID `json:"promo_code_id" db:"promo_code_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	BundleID       uuid.UUID `json:"bundle_id" db:"bundle_id"`
+	DiscountAmount int64     `json:"discount_amount" db:"discount_amount"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// Payment statuses reported by a PaymentProvider.
+const (
+	PaymentStatusPending     = "pending"
+	PaymentStatusAuthorized  = "authorized"
+	PaymentStatusSucceeded   = "succeeded"
+	PaymentStatusFailed      = "failed"
+	PaymentStatusRefunded    = "refunded"
+	PaymentStatusChargedBack = "charged_back"
+)
+
+// Payment records one charge for a plan purchase, identified by the bundle of
+// user features it pays for.
+type Payment struct {
+	ID                uuid.UUID `json:"id" db:"id"`
+	UserID            uuid.UUID `json:"user_id" db:"user_id"`
+	BundleID          uuid.UUID `json:"bundle_id" db:"bundle_id"`
+	Provider          string    `json:"provider" db:"provider"`
+	ProviderPaymentID *string   `json:"provider_payment_id" db:"provider_payment_id"`
+	Amount            int64     `json:"amount" db:"amount"`
+	Currency          string    `json:"currency" db:"currency"`
+	Status            string    `json:"status" db:"status"`
+	Description       string    `json:"description" db:"description"`
+	RefundedAmount    int64     `json:"refunded_amount" db:"refunded_amount"`
+	PaymentMethod     *string   `json:"-" db:"payment_method"`
+	Renewal           bool      `json:"renewal" db:"renewal"`
+	Boost             bool      `json:"boost" db:"boost"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Refund statuses.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund is part of a payment to be returned through its provider.
+type Refund struct {
+	ID                uuid.UUID `json:"id" db:"id"`
+	PaymentID         uuid.UUID `json:"payment_id" db:"payment_id"`
+	ProviderPaymentID string    `json:"-" db:"provider_payment_id"`
+	Amount            int64     `json:"amount" db:"amount"`
+	Status            string    `json:"status" db:"status"`
+	Attempts          int       `json:"attempts" db:"attempts"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Invoice kinds.
+const (
+	InvoiceKindCharge     = "charge"
+	InvoiceKindRefund     = "refund"
+	InvoiceKindChargeback = "chargeback"
+	InvoiceKindChange     = "change"
+)
+
+// Invoice is an entry in a user's billing history. Amount is in the
+// currency's minor unit: positive for charges, negative for money returned
+// and zero for subscription changes.
+type Invoice struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Number      int64      `json:"number" db:"number"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	BundleID    *uuid.UUID `json:"bundle_id" db:"bundle_id"`
+	PaymentID   *uuid.UUID `json:"payment_id" db:"payment_id"`
+	Kind        string     `json:"kind" db:"kind"`
+	Description string     `json:"description" db:"description"`
+	Amount      int64      `json:"amount" db:"amount"`
+	Currency    string     `json:"currency" db:"currency"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Receipt is an invoice with the details of the user it was issued to.
+type Receipt struct {
+	Invoice *Invoice
+	Name    string
+	Email   string
+}
+
+// CancelRequest cancels the user's subscription to a feature, together with
+// the features bought with it. Without AtPeriodEnd it ends now, and Refund
+// returns the unused part of the payment.
+type CancelRequest struct {
+	UserID      uuid.UUID
+	FeatureID   uuid.UUID
+	AtPeriodEnd bool
+	Refund      bool
+}
+
+// CancelResult is a cancelled subscription. RefundStatus is set when a refund
+// was requested: a pending refund is retried in the background and
+// RefundedAmount only counts a refund that succeeded.
+type CancelResult struct {
+	Subscription   *UserFeature `json:"subscription"`
+	RefundedAmount int64        `json:"refunded_amount"`
+	RefundStatus   string       `json:"refund_status,omitempty"`
+}
+
+// Checkout asks a PaymentProvider to charge Amount, in the currency's minor
+// unit, using PaymentMethod. Reference is our payment ID.
+type Checkout struct {
+	Reference     string
+	Amount        int64
+	Currency      string
+	PaymentMethod string
+}
+
+// Payment webhook event types.
+const (
+	PaymentEventPaid        = "payment.paid"
+	PaymentEventFailed      = "payment.failed"
+	PaymentEventRenewed     = "payment.renewed"
+	PaymentEventRefunded    = "payment.refunded"
+	PaymentEventChargedBack = "payment.charged_back"
+)
+
+// PaymentEvent is a webhook notification from the payment provider. Events
+// are stored by provider event ID so each one is applied once. Amount is set
+// by renewals charging a different amount than the original payment.
+type PaymentEvent struct {
+	ID                uuid.UUID `json:"-" db:"id"`
+	Provider          string    `json:"-" db:"provider"`
+	ProviderEventID   string    `json:"id" db:"provider_event_id"`
+	Type              string    `json:"type" db:"event_type"`
+	ProviderPaymentID string    `json:"payment_id" db:"provider_payment_id"`
+	Amount            int64     `json:"amount" db:"-"`
+	CreatedAt         time.Time `json:"-" db:"created_at"`
+}
+
+// PaymentResult is the provider's view of a payment.
+type PaymentResult struct {
+	ProviderPaymentID string
+	Status            string
+	RefundedAmount    int64
+}
+
+// Profile response types.
+const (
+	ResponseTypeLike      = "like"
+	ResponseTypePass      = "pass"
+	ResponseTypeSuperLike = "super_like"
+)
+
 type ProfileResponse struct {
 	ID           uuid.UUID `json:"id" db:"id"`
 	FromUserID   uuid.UUID `json:"from_user_id" db:"from_user_id"`
@@ -49,6 +447,72 @@ type ProfileResponse struct {
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// ReceivedLike is a like or super like the user has not responded to yet.
+// Users without the likes received feature only get Blurred placeholders,
+// which tell nothing about who liked them, how or when.
+type ReceivedLike struct {
+	Profile      *User      `json:"profile,omitempty"`
+	ResponseType string     `json:"response_type,omitempty"`
+	LikedAt      *time.Time `json:"liked_at,omitempty"`
+	Blurred      bool       `json:"blurred"`
+}
+
+// LikesReceived counts the likes the user has not responded to and lists
+// the latest of them.
+type LikesReceived struct {
+	Count int             `json:"count"`
+	Likes []*ReceivedLike `json:"likes"`
+}
+
+// Boost sources.
+const (
+	BoostSourcePurchase = "purchase"
+	BoostSourceRedeem   = "redeem"
+)
+
+// Boost ranks the user's profile higher in other users' decks from StartsAt
+// to EndsAt. A purchased boost takes the feature statuses of its payment and
+// starts once the payment succeeds. Views counts how often the profile was
+// shown during the boost and ExtraViews how many of those it would not have
+// had without it, judged by its views over the week before.
+type Boost struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	Source          string     `json:"source" db:"source"`
+	Status          string     `json:"status" db:"status"`
+	DurationSeconds int        `json:"duration_seconds" db:"duration_seconds"`
+	StartsAt        *time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at" db:"ends_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	Views           int        `json:"views" db:"views"`
+	BaselineViews   int        `json:"-" db:"baseline_views"`
+	ExtraViews      int        `json:"extra_views" db:"-"`
+}
+
+// BoostRequest asks for a boost, either bought with PaymentMethod or redeemed
+// from the user's weekly allowance.
+type BoostRequest struct {
+	UserID        uuid.UUID
+	Source        string
+	PaymentMethod string
+}
+
+// Notification types.
+const (
+	NotificationTypeSuperLike = "super_like"
+)
+
+// Notification tells a user that ActorID did something concerning them.
+type Notification struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Type      string     `json:"type" db:"type"`
+	ActorID   *uuid.UUID `json:"actor_id" db:"actor_id"`
+	ActorName *string    `json:"actor_name" db:"actor_name"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
 type DailyUsage struct {
 	ID            uuid.UUID `json:"id" db:"id"`
 	UserID        uuid.UUID `json:"user_id" db:"user_id"`
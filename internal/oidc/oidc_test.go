@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"datingapp/internal/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "https://app.example.com/oauth/callback"
+
+// authorize follows the authorization URL and returns the code and state the
+// provider redirects back with.
+func authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func newTestProvider(srv *oidctest.Server) *Provider {
+	return NewProvider(Config{
+		Name:        "fake",
+		IssuerURL:   srv.Issuer(),
+		ClientID:    "client-id",
+		RedirectURL: redirectURL,
+	}, srv.Client())
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	srv := oidctest.NewServer(oidctest.User{
+		Subject:       "subject-1",
+		Email:         "test@example.com",
+		EmailVerified: true,
+		Name:          "Test User",
+	})
+	defer srv.Close()
+
+	provider := newTestProvider(srv)
+	ctx := context.Background()
+
+	verifier, challenge, err := NewPKCE()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	require.NoError(t, err)
+
+	code, state := authorize(t, authURL)
+	assert.Equal(t, "state-1", state)
+
+	identity, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "subject-1", identity.Subject)
+	assert.Equal(t, "test@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, "Test User", identity.Name)
+}
+
+func TestProvider_RejectsWrongVerifier(t *testing.T) {
+	srv := oidctest.NewServer(oidctest.User{Subject: "subject-1"})
+	defer srv.Close()
+
+	provider := newTestProvider(srv)
+	ctx := context.Background()
+
+	_, challenge, err := NewPKCE()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	require.NoError(t, err)
+
+	code, _ := authorize(t, authURL)
+
+	_, err = provider.Exchange(ctx, code, "not-the-verifier", "nonce-1")
+	assert.ErrorIs(t, err, ErrExchangeFailed)
+}
+
+func TestProvider_RejectsNonceMismatch(t *testing.T) {
+	srv := oidctest.NewServer(oidctest.User{Subject: "subject-1"})
+	defer srv.Close()
+
+	provider := newTestProvider(srv)
+	ctx := context.Background()
+
+	verifier, challenge, err := NewPKCE()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	require.NoError(t, err)
+
+	code, _ := authorize(t, authURL)
+
+	_, err = provider.Exchange(ctx, code, verifier, "another-nonce")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestProvider_CodesAreSingleUseAndClientBound(t *testing.T) {
+	srv := oidctest.NewServer(oidctest.User{Subject: "subject-1"})
+	defer srv.Close()
+
+	ctx := context.Background()
+	issuing := newTestProvider(srv)
+
+	verifier, challenge, err := NewPKCE()
+	require.NoError(t, err)
+
+	authURL, err := issuing.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	require.NoError(t, err)
+	code, _ := authorize(t, authURL)
+
+	identity, err := issuing.Exchange(ctx, code, verifier, "nonce-1")
+	require.NoError(t, err)
+	require.NotNil(t, identity)
+
+	_, err = issuing.Exchange(ctx, code, verifier, "nonce-1")
+	assert.ErrorIs(t, err, ErrExchangeFailed)
+
+	other := NewProvider(Config{
+		Name:        "other",
+		IssuerURL:   srv.Issuer(),
+		ClientID:    "other-client",
+		RedirectURL: redirectURL,
+	}, srv.Client())
+
+	authURL, err = issuing.AuthCodeURL(ctx, "state-2", "nonce-2", challenge)
+	require.NoError(t, err)
+	code, _ = authorize(t, authURL)
+
+	_, err = other.Exchange(ctx, code, verifier, "nonce-2")
+	assert.Error(t, err)
+}
+
+func TestProvider_UnknownKeyIDsRefetchAtMostOncePerInterval(t *testing.T) {
+	srv := oidctest.NewServer(oidctest.User{Subject: "subject-1"})
+	defer srv.Close()
+
+	provider := newTestProvider(srv)
+	ctx := context.Background()
+	_, err := provider.getDiscovery(ctx)
+	require.NoError(t, err)
+
+	_, err = provider.getKey(ctx, "made-up-1")
+	assert.EqualError(t, err, `unknown key id "made-up-1"`)
+	_, err = provider.getKey(ctx, "made-up-2")
+	assert.EqualError(t, err, `unknown key id "made-up-2"`)
+	assert.Equal(t, 1, srv.JWKSRequests())
+
+	// Keys from the last fetch still verify tokens.
+	_, err = provider.getKey(ctx, "oidctest")
+	require.NoError(t, err)
+	assert.Equal(t, 1, srv.JWKSRequests())
+}
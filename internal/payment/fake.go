@@ -0,0 +1,150 @@
+// Package payment holds internal.PaymentProvider implementations.
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"datingapp/internal"
+)
+
+// Payment method tokens understood by FakeProvider. Any other token is
+// declined.
+const (
+	FakeMethodSuccess  = "fake_success"
+	FakeMethodDeclined = "fake_declined"
+	FakeMethodPending  = "fake_pending"
+)
+
+var (
+	ErrPaymentNotFound   = errors.New("payment not found")
+	ErrInvalidTransition = errors.New("invalid payment status transition")
+)
+
+// FakeProvider is a deterministic in-process PaymentProvider for development
+// and tests. The outcome of a checkout is chosen by its payment method token.
+type FakeProvider struct {
+	mu       sync.Mutex
+	seq      int
+	payments map[string]*fakePayment
+}
+
+type fakePayment struct {
+	amount   int64
+	status   string
+	refunded int64
+	// refunds holds the references of the refunds made.
+	refunds map[string]struct{}
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		payments: make(map[string]*fakePayment),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateCheckout(_ context.Context, checkout *internal.Checkout) (*internal.PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := internal.PaymentStatusFailed
+	switch checkout.PaymentMethod {
+	case FakeMethodSuccess:
+		status = internal.PaymentStatusAuthorized
+	case FakeMethodPending:
+		status = internal.PaymentStatusPending
+	}
+
+	p.seq++
+	id := fmt.Sprintf("fake_pay_%d", p.seq)
+	p.payments[id] = &fakePayment{amount: checkout.Amount, status: status, refunds: make(map[string]struct{})}
+
+	return p.result(id), nil
+}
+
+func (p *FakeProvider) Capture(_ context.Context, providerPaymentID string) (*internal.PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[providerPaymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	if payment.status == internal.PaymentStatusAuthorized {
+		payment.status = internal.PaymentStatusSucceeded
+	}
+
+	return p.result(providerPaymentID), nil
+}
+
+// Refund refunds amount of a succeeded payment. The payment is marked refunded
+// once the whole amount has been returned. Repeating a refund reference
+// returns the payment without refunding it again.
+func (p *FakeProvider) Refund(_ context.Context, providerPaymentID string, amount int64, reference string) (*internal.PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[providerPaymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	if _, ok := payment.refunds[reference]; ok {
+		return p.result(providerPaymentID), nil
+	}
+	if payment.status != internal.PaymentStatusSucceeded || amount <= 0 || payment.refunded+amount > payment.amount {
+		return nil, ErrInvalidTransition
+	}
+
+	payment.refunded += amount
+	payment.refunds[reference] = struct{}{}
+	if payment.refunded == payment.amount {
+		payment.status = internal.PaymentStatusRefunded
+	}
+
+	return p.result(providerPaymentID), nil
+}
+
+func (p *FakeProvider) Status(_ context.Context, providerPaymentID string) (*internal.PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.payments[providerPaymentID]; !ok {
+		return nil, ErrPaymentNotFound
+	}
+
+	return p.result(providerPaymentID), nil
+}
+
+// Settle completes a pending payment with status, which must be succeeded or
+// failed, as the real provider would asynchronously.
+func (p *FakeProvider) Settle(providerPaymentID, status string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[providerPaymentID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if payment.status != internal.PaymentStatusPending ||
+		(status != internal.PaymentStatusSucceeded && status != internal.PaymentStatusFailed) {
+		return ErrInvalidTransition
+	}
+
+	payment.status = status
+	return nil
+}
+
+func (p *FakeProvider) result(providerPaymentID string) *internal.PaymentResult {
+	payment := p.payments[providerPaymentID]
+	return &internal.PaymentResult{
+		ProviderPaymentID: providerPaymentID,
+		Status:            payment.status,
+		RefundedAmount:    payment.refunded,
+	}
+}
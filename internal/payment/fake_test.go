@@ -0,0 +1,92 @@
+package payment
+
+import (
+	"context"
+	"testing"
+
+	"datingapp/internal"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeProvider_Checkout(t *testing.T) {
+	tests := []struct {
+		method   string
+		checkout string
+		captured string
+	}{
+		{method: FakeMethodSuccess, checkout: internal.PaymentStatusAuthorized, captured: internal.PaymentStatusSucceeded},
+		{method: FakeMethodPending, checkout: internal.PaymentStatusPending, captured: internal.PaymentStatusPending},
+		{method: FakeMethodDeclined, checkout: internal.PaymentStatusFailed, captured: internal.PaymentStatusFailed},
+		{method: "tok_unknown", checkout: internal.PaymentStatusFailed, captured: internal.PaymentStatusFailed},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			p := NewFakeProvider()
+
+			result, err := p.CreateCheckout(ctx, &internal.Checkout{Amount: 999, Currency: "USD", PaymentMethod: tt.method})
+			assert.NoError(t, err)
+			assert.Equal(t, "fake_pay_1", result.ProviderPaymentID)
+			assert.Equal(t, tt.checkout, result.Status)
+
+			result, err = p.Capture(ctx, result.ProviderPaymentID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.captured, result.Status)
+
+			status, err := p.Status(ctx, result.ProviderPaymentID)
+			assert.NoError(t, err)
+			assert.Equal(t, result, status)
+		})
+	}
+}
+
+func TestFakeProvider_Refund(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider()
+
+	result, err := p.CreateCheckout(ctx, &internal.Checkout{Amount: 1000, Currency: "USD", PaymentMethod: FakeMethodSuccess})
+	assert.NoError(t, err)
+
+	_, err = p.Refund(ctx, result.ProviderPaymentID, 100, "refund_1")
+	assert.ErrorIs(t, err, ErrInvalidTransition, "authorized payments cannot be refunded")
+
+	_, err = p.Capture(ctx, result.ProviderPaymentID)
+	assert.NoError(t, err)
+
+	result, err = p.Refund(ctx, result.ProviderPaymentID, 400, "refund_1")
+	assert.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusSucceeded, result.Status)
+	assert.Equal(t, int64(400), result.RefundedAmount)
+
+	result, err = p.Refund(ctx, result.ProviderPaymentID, 400, "refund_1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), result.RefundedAmount, "a retried refund is not made twice")
+
+	_, err = p.Refund(ctx, result.ProviderPaymentID, 700, "refund_2")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+
+	result, err = p.Refund(ctx, result.ProviderPaymentID, 600, "refund_3")
+	assert.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusRefunded, result.Status)
+
+	_, err = p.Refund(ctx, "fake_pay_missing", 1, "refund_4")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+}
+
+func TestFakeProvider_Settle(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider()
+
+	result, err := p.CreateCheckout(ctx, &internal.Checkout{Amount: 999, Currency: "USD", PaymentMethod: FakeMethodPending})
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, p.Settle(result.ProviderPaymentID, internal.PaymentStatusRefunded), ErrInvalidTransition)
+	assert.NoError(t, p.Settle(result.ProviderPaymentID, internal.PaymentStatusSucceeded))
+	assert.ErrorIs(t, p.Settle(result.ProviderPaymentID, internal.PaymentStatusFailed), ErrInvalidTransition)
+
+	result, err = p.Status(ctx, result.ProviderPaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusSucceeded, result.Status)
+}
@@ -0,0 +1,32 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"payment.paid","payment_id":"fake_pay_1"}`)
+	signature := Sign("whsec", body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		expected  bool
+	}{
+		{name: "valid", secret: "whsec", body: body, signature: signature, expected: true},
+		{name: "wrong secret", secret: "other", body: body, signature: signature, expected: false},
+		{name: "tampered body", secret: "whsec", body: []byte(`{"id":"evt_2"}`), signature: signature, expected: false},
+		{name: "missing prefix", secret: "whsec", body: body, signature: signature[len("sha256="):], expected: false},
+		{name: "no secret configured", secret: "", body: body, signature: Sign("", body), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, VerifySignature(tt.secret, tt.body, tt.signature))
+		})
+	}
+}
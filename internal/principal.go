@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type principalKey struct{}
+
+// Principal is the authenticated user a request is made on behalf of.
+type Principal struct {
+	UserID uuid.UUID
+	Email  string
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,123 @@
+// Package receipt renders billing history entries as HTML or PDF receipts.
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"datingapp/internal"
+)
+
+// zeroDecimalCurrencies have no minor unit, so amounts are whole units.
+var zeroDecimalCurrencies = map[string]bool{
+	"CLP": true,
+	"JPY": true,
+	"KRW": true,
+	"VND": true,
+}
+
+// FormatAmount formats an amount in the currency's minor unit, e.g. 999 USD
+// as "9.99 USD".
+func FormatAmount(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	if zeroDecimalCurrencies[currency] {
+		return strings.TrimSpace(fmt.Sprintf("%s%d %s", sign, amount, currency))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, currency))
+}
+
+// lines is the receipt content shared by both formats, as label/value pairs.
+func lines(r *internal.Receipt) [][2]string {
+	return [][2]string{
+		{"Receipt", fmt.Sprintf("#%06d", r.Invoice.Number)},
+		{"Date", r.Invoice.CreatedAt.UTC().Format("2006-01-02 15:04 UTC")},
+		{"Billed to", fmt.Sprintf("%s <%s>", r.Name, r.Email)},
+		{"Type", r.Invoice.Kind},
+		{"Description", r.Invoice.Description},
+		{"Amount", FormatAmount(r.Invoice.Amount, r.Invoice.Currency)},
+	}
+}
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt {{index (index . 0) 1}}</title>
+</head>
+<body>
+<h1>DatingApp receipt</h1>
+<table>
+{{- range .}}
+<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// HTML writes r as an HTML page.
+func HTML(w io.Writer, r *internal.Receipt) error {
+	return htmlTemplate.Execute(w, lines(r))
+}
+
+// PDF writes r as a single page PDF using the standard Helvetica font.
+func PDF(w io.Writer, r *internal.Receipt) error {
+	var content bytes.Buffer
+	content.WriteString("BT\n/F1 18 Tf\n72 770 Td\n(DatingApp receipt) Tj\n/F1 11 Tf\n0 -36 Td\n")
+	for _, l := range lines(r) {
+		fmt.Fprintf(&content, "(%s) Tj\n0 -18 Td\n", pdfString(l[0]+": "+l[1]))
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+// pdfString escapes s for a PDF literal string. Characters outside printable
+// ASCII are replaced, since the standard fonts cannot show them reliably.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
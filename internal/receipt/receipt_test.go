@@ -0,0 +1,73 @@
+package receipt
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testReceipt() *internal.Receipt {
+	return &internal.Receipt{
+		Invoice: &internal.Invoice{
+			Number:      42,
+			Kind:        internal.InvoiceKindCharge,
+			Description: "daily_responses subscription (1_month) <script>",
+			Amount:      999,
+			Currency:    "USD",
+			CreatedAt:   time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC),
+		},
+		Name:  "Jane (J) Doe",
+		Email: "jane@example.com",
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "9.99 USD", FormatAmount(999, "USD"))
+	assert.Equal(t, "-0.05 EUR", FormatAmount(-5, "EUR"))
+	assert.Equal(t, "1200 JPY", FormatAmount(1200, "JPY"))
+	assert.Equal(t, "0.00", FormatAmount(0, ""))
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, HTML(&buf, testReceipt()))
+
+	out := buf.String()
+	assert.Contains(t, out, "<title>Receipt #000042</title>")
+	assert.Contains(t, out, "<td>9.99 USD</td>")
+	assert.Contains(t, out, "&lt;script&gt;")
+	assert.NotContains(t, out, "<script>")
+}
+
+func TestPDF(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, PDF(&buf, testReceipt()))
+
+	out := buf.Bytes()
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), `(Billed to: Jane \(J\) Doe <jane@example.com>) Tj`)
+
+	// startxref must point at the xref table and every entry at its object.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	assert.NotNil(t, m)
+	xref, err := strconv.Atoi(string(m[1]))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out[xref:], []byte("xref\n")))
+
+	for i, entry := range regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out, -1) {
+		off, err := strconv.Atoi(string(entry[1]))
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(out[off:], []byte(strconv.Itoa(i+1)+" 0 obj\n")))
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	assert.Equal(t, `a\(b\)\\c?`, pdfString(`a(b)\c€`))
+}
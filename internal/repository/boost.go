@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// runningBoost matches the boosts of users.id that are running now.
+const runningBoost = `
+	SELECT 1
+	FROM boosts b
+	WHERE b.user_id = users.id
+		AND b.status = 'active'
+		AND b.starts_at <= NOW()
+		AND b.ends_at > NOW()`
+
+// CreateBoost inserts boost. An active boost starts now.
+func (r *repository) CreateBoost(ctx context.Context, tx *sqlx.Tx, boost *internal.Boost) error {
+	query := `
+		INSERT INTO boosts (id, user_id, source, status, duration_seconds, starts_at, ends_at, created_at, updated_at)
+		VALUES (
+			$1, $2, $3, $4, $5::integer,
+			CASE WHEN $4 = 'active' THEN NOW() END,
+			CASE WHEN $4 = 'active' THEN NOW() + make_interval(secs => $5::integer) END,
+			NOW(), NOW()
+		)
+		RETURNING starts_at, ends_at, created_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		boost.ID,
+		boost.UserID,
+		boost.Source,
+		boost.Status,
+		boost.DurationSeconds,
+	).Scan(&boost.StartsAt, &boost.EndsAt, &boost.CreatedAt, &boost.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("insert boost: %w", err)
+	}
+
+	return nil
+}
+
+// HasRunningBoost reports whether the user has a boost running now or a
+// bought one awaiting its payment. It locks the user's row until tx ends so
+// that concurrent activations are serialized.
+func (r *repository) HasRunningBoost(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (` + runningBoost + `)
+			OR EXISTS (
+				SELECT 1
+				FROM boosts b
+				WHERE b.user_id = users.id
+					AND b.status = 'pending'
+			)
+		FROM users
+		WHERE id = $1
+		FOR UPDATE`
+
+	var running bool
+	if err := tx.GetContext(ctx, &running, query, userID); err != nil {
+		return false, fmt.Errorf("check running boost: %w", err)
+	}
+
+	return running, nil
+}
+
+// UpdateBoostStatus sets the status of a bought boost from its payment. A
+// boost starts the first time it becomes active.
+func (r *repository) UpdateBoostStatus(ctx context.Context, tx *sqlx.Tx, boostID uuid.UUID, status string) error {
+	query := `
+		UPDATE boosts
+		SET status = $2,
+			starts_at = CASE WHEN $2 = 'active' THEN COALESCE(starts_at, NOW()) ELSE starts_at END,
+			ends_at = CASE WHEN $2 = 'active' THEN COALESCE(ends_at, NOW() + make_interval(secs => duration_seconds)) ELSE ends_at END,
+			updated_at = NOW()
+		WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, boostID, status); err != nil {
+		return fmt.Errorf("update boost status: %w", err)
+	}
+
+	return nil
+}
+
+// boostColumns selects a boost with its views and the views of its user over
+// the week before it started.
+const boostColumns = `
+		b.id, b.user_id, b.source, b.status, b.duration_seconds, b.starts_at, b.ends_at, b.created_at, b.updated_at,
+		(SELECT COUNT(*) FROM profile_views v WHERE v.boost_id = b.id) AS views,
+		(
+			SELECT COUNT(*)
+			FROM profile_views v
+			WHERE v.user_id = b.user_id
+				AND v.boost_id IS NULL
+				AND v.created_at >= b.starts_at - INTERVAL '7 days'
+				AND v.created_at < b.starts_at
+		) AS baseline_views`
+
+func (r *repository) GetBoost(ctx context.Context, boostID uuid.UUID) (*internal.Boost, error) {
+	query := `SELECT` + boostColumns + `
+		FROM boosts b
+		WHERE b.id = $1`
+
+	var boost internal.Boost
+	if err := r.db.GetContext(ctx, &boost, query, boostID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrBoostNotFound
+		}
+		return nil, fmt.Errorf("get boost: %w", err)
+	}
+
+	return &boost, nil
+}
+
+// GetBoosts returns the user's boosts, newest first.
+func (r *repository) GetBoosts(ctx context.Context, userID uuid.UUID) ([]*internal.Boost, error) {
+	query := `SELECT` + boostColumns + `
+		FROM boosts b
+		WHERE b.user_id = $1
+		ORDER BY b.created_at DESC`
+
+	boosts := []*internal.Boost{}
+	if err := r.db.SelectContext(ctx, &boosts, query, userID); err != nil {
+		return nil, fmt.Errorf("select boosts: %w", err)
+	}
+
+	return boosts, nil
+}
+
+// RecordProfileViews records that viewerID was shown the users, each with the
+// boost they have running.
+func (r *repository) RecordProfileViews(ctx context.Context, viewerID uuid.UUID, userIDs []uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = id.String()
+	}
+
+	query := `
+		INSERT INTO profile_views (user_id, viewer_id, boost_id, created_at)
+		SELECT users.id, $1, (` + runningBoost + `
+			ORDER BY b.ends_at DESC
+			LIMIT 1
+		), NOW()
+		FROM users
+		WHERE users.id = ANY($2::uuid[])`
+
+	if _, err := r.db.ExecContext(ctx, query, viewerID, pq.StringArray(ids)); err != nil {
+		return fmt.Errorf("insert profile views: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// RevokeGrant ends the user's live grant of featureID now and returns it.
+func (r *repository) RevokeGrant(ctx context.Context, tx *sqlx.Tx, userID, featureID uuid.UUID) (*internal.UserFeature, error) {
+	feature := &internal.UserFeature{}
+	query := `
+		UPDATE user_features uf
+		SET status = 'revoked',
+			end_date = NOW(),
+			grace_until = NULL,
+			updated_at = NOW()
+		FROM subscription_features sf
+		WHERE sf.id = uf.feature_id
+			AND uf.user_id = $1
+			AND uf.feature_id = $2
+			AND uf.source = 'grant'
+			AND uf.status IN ('pending', 'active', 'past_due', 'pending_cancellation')
+		RETURNING
+			uf.id,
+			uf.user_id,
+			uf.feature_id,
+			uf.value,
+			uf.start_date,
+			uf.end_date,
+			uf.status,
+			uf.auto_renew,
+			uf.grace_until,
+			uf.cancelled_at,
+			uf.source,
+			uf.grant_reason,
+			uf.granted_by,
+			uf.plan_id,
+			uf.plan_price_id,
+			uf.bundle_id,
+			uf.price_amount,
+			uf.currency,
+			uf.created_at,
+			uf.updated_at,
+			sf.name as feature_name,
+			sf.description as feature_description`
+
+	err := tx.GetContext(ctx, feature, query, userID, featureID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrGrantNotFound
+		}
+		return nil, fmt.Errorf("revoke grant: %w", err)
+	}
+
+	return feature, nil
+}
+
+func (r *repository) SetUserAdmin(ctx context.Context, userID uuid.UUID, isAdmin bool) error {
+	query := `
+		UPDATE users
+		SET is_admin = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID, isAdmin)
+	if err != nil {
+		return fmt.Errorf("set user admin: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set user admin: %w", err)
+	}
+	if rows == 0 {
+		return internal.ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repository) CreateInvoice(ctx context.Context, tx *sqlx.Tx, invoice *internal.Invoice) error {
+	query := `
+		INSERT INTO invoices (user_id, bundle_id, payment_id, kind, description, amount, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, number, created_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		invoice.UserID,
+		invoice.BundleID,
+		invoice.PaymentID,
+		invoice.Kind,
+		invoice.Description,
+		invoice.Amount,
+		invoice.Currency,
+	).Scan(&invoice.ID, &invoice.Number, &invoice.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert invoice: %w", err)
+	}
+
+	return nil
+}
+
+// GetInvoices returns the user's billing history, newest first.
+func (r *repository) GetInvoices(ctx context.Context, userID uuid.UUID) ([]*internal.Invoice, error) {
+	invoices := []*internal.Invoice{}
+	query := `
+		SELECT id, number, user_id, bundle_id, payment_id, kind, description, amount, currency, created_at
+		FROM invoices
+		WHERE user_id = $1
+		ORDER BY created_at DESC, number DESC`
+
+	if err := r.db.SelectContext(ctx, &invoices, query, userID); err != nil {
+		return nil, fmt.Errorf("select invoices: %w", err)
+	}
+
+	return invoices, nil
+}
+
+func (r *repository) GetInvoice(ctx context.Context, userID, invoiceID uuid.UUID) (*internal.Invoice, error) {
+	invoice := &internal.Invoice{}
+	query := `
+		SELECT id, number, user_id, bundle_id, payment_id, kind, description, amount, currency, created_at
+		FROM invoices
+		WHERE id = $1
+			AND user_id = $2`
+
+	err := r.db.GetContext(ctx, invoice, query, invoiceID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrInvoiceNotFound
+		}
+		return nil, fmt.Errorf("select invoice: %w", err)
+	}
+
+	return invoice, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+)
+
+// likesReceivedFilter selects the likes and super likes sent to $1 that $1
+// has not responded to.
+const likesReceivedFilter = `
+		WHERE pr.to_user_id = $1
+			AND pr.response_type IN ('like', 'super_like')
+			AND NOT EXISTS (
+				SELECT 1
+				FROM profile_responses mine
+				WHERE mine.from_user_id = $1
+					AND mine.to_user_id = pr.from_user_id
+			)`
+
+func (r *repository) CountLikesReceived(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM profile_responses pr` + likesReceivedFilter
+
+	var count int
+	if err := r.db.GetContext(ctx, &count, query, userID); err != nil {
+		return 0, fmt.Errorf("count likes received: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetLikesReceived returns the latest likes sent to the user that they have
+// not responded to, newest first, with the profiles that sent them.
+func (r *repository) GetLikesReceived(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.ReceivedLike, error) {
+	query := `
+		SELECT u.id, u.email, u.name, u.bio, u.birth_date, u.gender, u.created_at, u.updated_at,
+			pr.response_type, pr.created_at AS liked_at
+		FROM profile_responses pr
+		JOIN users u ON u.id = pr.from_user_id` + likesReceivedFilter + `
+		ORDER BY pr.created_at DESC
+		LIMIT $2`
+
+	var rows []struct {
+		internal.User
+		ResponseType string    `db:"response_type"`
+		LikedAt      time.Time `db:"liked_at"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, userID, limit); err != nil {
+		return nil, fmt.Errorf("select likes received: %w", err)
+	}
+
+	likes := make([]*internal.ReceivedLike, len(rows))
+	for i := range rows {
+		likes[i] = &internal.ReceivedLike{
+			Profile:      &rows[i].User,
+			ResponseType: rows[i].ResponseType,
+			LikedAt:      &rows[i].LikedAt,
+		}
+	}
+
+	return likes, nil
+}
@@ -59,6 +59,195 @@ func (mr *MockRepositoryMockRecorder) BeginTx(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTx", reflect.TypeOf((*MockRepository)(nil).BeginTx), ctx)
 }
 
+// CancelSubscription mocks base method.
+func (m *MockRepository) CancelSubscription(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature, atPeriodEnd bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelSubscription", ctx, tx, subscription, atPeriodEnd)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelSubscription indicates an expected call of CancelSubscription.
+func (mr *MockRepositoryMockRecorder) CancelSubscription(ctx, tx, subscription, atPeriodEnd any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSubscription", reflect.TypeOf((*MockRepository)(nil).CancelSubscription), ctx, tx, subscription, atPeriodEnd)
+}
+
+// ClaimRefund mocks base method.
+func (m *MockRepository) ClaimRefund(ctx context.Context, tx *sqlx.Tx, paymentID uuid.UUID, amount int64) (*internal.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimRefund", ctx, tx, paymentID, amount)
+	ret0, _ := ret[0].(*internal.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimRefund indicates an expected call of ClaimRefund.
+func (mr *MockRepositoryMockRecorder) ClaimRefund(ctx, tx, paymentID, amount any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimRefund", reflect.TypeOf((*MockRepository)(nil).ClaimRefund), ctx, tx, paymentID, amount)
+}
+
+// ConsumeDailyResponse mocks base method.
+func (m *MockRepository) ConsumeDailyResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, day time.Time, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeDailyResponse", ctx, tx, userID, day, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeDailyResponse indicates an expected call of ConsumeDailyResponse.
+func (mr *MockRepositoryMockRecorder) ConsumeDailyResponse(ctx, tx, userID, day, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeDailyResponse", reflect.TypeOf((*MockRepository)(nil).ConsumeDailyResponse), ctx, tx, userID, day, limit)
+}
+
+// ConsumeOAuthState mocks base method.
+func (m *MockRepository) ConsumeOAuthState(ctx context.Context, state, provider string) (*internal.OAuthState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeOAuthState", ctx, state, provider)
+	ret0, _ := ret[0].(*internal.OAuthState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeOAuthState indicates an expected call of ConsumeOAuthState.
+func (mr *MockRepositoryMockRecorder) ConsumeOAuthState(ctx, state, provider any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeOAuthState", reflect.TypeOf((*MockRepository)(nil).ConsumeOAuthState), ctx, state, provider)
+}
+
+// ConsumeWeeklyBoost mocks base method.
+func (m *MockRepository) ConsumeWeeklyBoost(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, weekStart time.Time, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeWeeklyBoost", ctx, tx, userID, weekStart, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeWeeklyBoost indicates an expected call of ConsumeWeeklyBoost.
+func (mr *MockRepositoryMockRecorder) ConsumeWeeklyBoost(ctx, tx, userID, weekStart, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeWeeklyBoost", reflect.TypeOf((*MockRepository)(nil).ConsumeWeeklyBoost), ctx, tx, userID, weekStart, limit)
+}
+
+// ConsumeWeeklySuperLike mocks base method.
+func (m *MockRepository) ConsumeWeeklySuperLike(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, weekStart time.Time, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeWeeklySuperLike", ctx, tx, userID, weekStart, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeWeeklySuperLike indicates an expected call of ConsumeWeeklySuperLike.
+func (mr *MockRepositoryMockRecorder) ConsumeWeeklySuperLike(ctx, tx, userID, weekStart, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeWeeklySuperLike", reflect.TypeOf((*MockRepository)(nil).ConsumeWeeklySuperLike), ctx, tx, userID, weekStart, limit)
+}
+
+// CountLikesReceived mocks base method.
+func (m *MockRepository) CountLikesReceived(ctx context.Context, userID uuid.UUID) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountLikesReceived", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountLikesReceived indicates an expected call of CountLikesReceived.
+func (mr *MockRepositoryMockRecorder) CountLikesReceived(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountLikesReceived", reflect.TypeOf((*MockRepository)(nil).CountLikesReceived), ctx, userID)
+}
+
+// CreateBoost mocks base method.
+func (m *MockRepository) CreateBoost(ctx context.Context, tx *sqlx.Tx, boost *internal.Boost) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBoost", ctx, tx, boost)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateBoost indicates an expected call of CreateBoost.
+func (mr *MockRepositoryMockRecorder) CreateBoost(ctx, tx, boost any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBoost", reflect.TypeOf((*MockRepository)(nil).CreateBoost), ctx, tx, boost)
+}
+
+// CreateInvoice mocks base method.
+func (m *MockRepository) CreateInvoice(ctx context.Context, tx *sqlx.Tx, invoice *internal.Invoice) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateInvoice", ctx, tx, invoice)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateInvoice indicates an expected call of CreateInvoice.
+func (mr *MockRepositoryMockRecorder) CreateInvoice(ctx, tx, invoice any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInvoice", reflect.TypeOf((*MockRepository)(nil).CreateInvoice), ctx, tx, invoice)
+}
+
+// CreateNotification mocks base method.
+func (m *MockRepository) CreateNotification(ctx context.Context, tx *sqlx.Tx, notification *internal.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateNotification", ctx, tx, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateNotification indicates an expected call of CreateNotification.
+func (mr *MockRepositoryMockRecorder) CreateNotification(ctx, tx, notification any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNotification", reflect.TypeOf((*MockRepository)(nil).CreateNotification), ctx, tx, notification)
+}
+
+// CreateOAuthState mocks base method.
+func (m *MockRepository) CreateOAuthState(ctx context.Context, state *internal.OAuthState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthState", ctx, state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthState indicates an expected call of CreateOAuthState.
+func (mr *MockRepositoryMockRecorder) CreateOAuthState(ctx, state any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthState", reflect.TypeOf((*MockRepository)(nil).CreateOAuthState), ctx, state)
+}
+
+// CreatePayment mocks base method.
+func (m *MockRepository) CreatePayment(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePayment", ctx, tx, payment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePayment indicates an expected call of CreatePayment.
+func (mr *MockRepositoryMockRecorder) CreatePayment(ctx, tx, payment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayment", reflect.TypeOf((*MockRepository)(nil).CreatePayment), ctx, tx, payment)
+}
+
+// CreatePaymentEvent mocks base method.
+func (m *MockRepository) CreatePaymentEvent(ctx context.Context, tx *sqlx.Tx, event *internal.PaymentEvent, payload []byte) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePaymentEvent", ctx, tx, event, payload)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePaymentEvent indicates an expected call of CreatePaymentEvent.
+func (mr *MockRepositoryMockRecorder) CreatePaymentEvent(ctx, tx, event, payload any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentEvent", reflect.TypeOf((*MockRepository)(nil).CreatePaymentEvent), ctx, tx, event, payload)
+}
+
 // CreateProfileResponse mocks base method.
 func (m *MockRepository) CreateProfileResponse(ctx context.Context, tx *sqlx.Tx, response *internal.ProfileResponse) error {
 	m.ctrl.T.Helper()
@@ -73,6 +262,49 @@ func (mr *MockRepositoryMockRecorder) CreateProfileResponse(ctx, tx, response an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProfileResponse", reflect.TypeOf((*MockRepository)(nil).CreateProfileResponse), ctx, tx, response)
 }
 
+// CreatePromoRedemption mocks base method.
+func (m *MockRepository) CreatePromoRedemption(ctx context.Context, tx *sqlx.Tx, redemption *internal.PromoRedemption) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePromoRedemption", ctx, tx, redemption)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePromoRedemption indicates an expected call of CreatePromoRedemption.
+func (mr *MockRepositoryMockRecorder) CreatePromoRedemption(ctx, tx, redemption any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePromoRedemption", reflect.TypeOf((*MockRepository)(nil).CreatePromoRedemption), ctx, tx, redemption)
+}
+
+// CreateRefund mocks base method.
+func (m *MockRepository) CreateRefund(ctx context.Context, tx *sqlx.Tx, refund *internal.Refund) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefund", ctx, tx, refund)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefund indicates an expected call of CreateRefund.
+func (mr *MockRepositoryMockRecorder) CreateRefund(ctx, tx, refund any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefund", reflect.TypeOf((*MockRepository)(nil).CreateRefund), ctx, tx, refund)
+}
+
+// CreateRenewalPayments mocks base method.
+func (m *MockRepository) CreateRenewalPayments(ctx context.Context, tx *sqlx.Tx, provider string) ([]*internal.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRenewalPayments", ctx, tx, provider)
+	ret0, _ := ret[0].([]*internal.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRenewalPayments indicates an expected call of CreateRenewalPayments.
+func (mr *MockRepositoryMockRecorder) CreateRenewalPayments(ctx, tx, provider any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRenewalPayments", reflect.TypeOf((*MockRepository)(nil).CreateRenewalPayments), ctx, tx, provider)
+}
+
 // CreateUser mocks base method.
 func (m *MockRepository) CreateUser(ctx context.Context, tx *sqlx.Tx, user *internal.User) (uuid.UUID, error) {
 	m.ctrl.T.Helper()
@@ -102,19 +334,137 @@ func (mr *MockRepositoryMockRecorder) CreateUserFeature(ctx, tx, feature any) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserFeature", reflect.TypeOf((*MockRepository)(nil).CreateUserFeature), ctx, tx, feature)
 }
 
-// GetDailyInteractionCount mocks base method.
-func (m *MockRepository) GetDailyInteractionCount(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+// CreateUserIdentity mocks base method.
+func (m *MockRepository) CreateUserIdentity(ctx context.Context, tx *sqlx.Tx, identity *internal.UserIdentity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserIdentity", ctx, tx, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUserIdentity indicates an expected call of CreateUserIdentity.
+func (mr *MockRepositoryMockRecorder) CreateUserIdentity(ctx, tx, identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserIdentity", reflect.TypeOf((*MockRepository)(nil).CreateUserIdentity), ctx, tx, identity)
+}
+
+// DeleteLatestPass mocks base method.
+func (m *MockRepository) DeleteLatestPass(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, window time.Duration) (*internal.ProfileResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLatestPass", ctx, tx, userID, window)
+	ret0, _ := ret[0].(*internal.ProfileResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteLatestPass indicates an expected call of DeleteLatestPass.
+func (mr *MockRepositoryMockRecorder) DeleteLatestPass(ctx, tx, userID, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLatestPass", reflect.TypeOf((*MockRepository)(nil).DeleteLatestPass), ctx, tx, userID, window)
+}
+
+// EnableUserTOTP mocks base method.
+func (m *MockRepository) EnableUserTOTP(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableUserTOTP", ctx, tx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableUserTOTP indicates an expected call of EnableUserTOTP.
+func (mr *MockRepositoryMockRecorder) EnableUserTOTP(ctx, tx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableUserTOTP", reflect.TypeOf((*MockRepository)(nil).EnableUserTOTP), ctx, tx, userID)
+}
+
+// ExpireSubscriptions mocks base method.
+func (m *MockRepository) ExpireSubscriptions(ctx context.Context, tx *sqlx.Tx, gracePeriod time.Duration, userID *uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireSubscriptions", ctx, tx, gracePeriod, userID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireSubscriptions indicates an expected call of ExpireSubscriptions.
+func (mr *MockRepositoryMockRecorder) ExpireSubscriptions(ctx, tx, gracePeriod, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireSubscriptions", reflect.TypeOf((*MockRepository)(nil).ExpireSubscriptions), ctx, tx, gracePeriod, userID)
+}
+
+// ExtendBundle mocks base method.
+func (m *MockRepository) ExtendBundle(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtendBundle", ctx, tx, bundleID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExtendBundle indicates an expected call of ExtendBundle.
+func (mr *MockRepositoryMockRecorder) ExtendBundle(ctx, tx, bundleID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendBundle", reflect.TypeOf((*MockRepository)(nil).ExtendBundle), ctx, tx, bundleID)
+}
+
+// GetBoost mocks base method.
+func (m *MockRepository) GetBoost(ctx context.Context, boostID uuid.UUID) (*internal.Boost, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBoost", ctx, boostID)
+	ret0, _ := ret[0].(*internal.Boost)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoost indicates an expected call of GetBoost.
+func (mr *MockRepositoryMockRecorder) GetBoost(ctx, boostID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoost", reflect.TypeOf((*MockRepository)(nil).GetBoost), ctx, boostID)
+}
+
+// GetBoosts mocks base method.
+func (m *MockRepository) GetBoosts(ctx context.Context, userID uuid.UUID) ([]*internal.Boost, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBoosts", ctx, userID)
+	ret0, _ := ret[0].([]*internal.Boost)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoosts indicates an expected call of GetBoosts.
+func (mr *MockRepositoryMockRecorder) GetBoosts(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoosts", reflect.TypeOf((*MockRepository)(nil).GetBoosts), ctx, userID)
+}
+
+// GetBundlePayment mocks base method.
+func (m *MockRepository) GetBundlePayment(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) (*internal.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBundlePayment", ctx, tx, bundleID)
+	ret0, _ := ret[0].(*internal.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBundlePayment indicates an expected call of GetBundlePayment.
+func (mr *MockRepositoryMockRecorder) GetBundlePayment(ctx, tx, bundleID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBundlePayment", reflect.TypeOf((*MockRepository)(nil).GetBundlePayment), ctx, tx, bundleID)
+}
+
+// GetDailyUsage mocks base method.
+func (m *MockRepository) GetDailyUsage(ctx context.Context, userID uuid.UUID, day time.Time) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDailyInteractionCount", ctx, userID, since)
+	ret := m.ctrl.Call(m, "GetDailyUsage", ctx, userID, day)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetDailyInteractionCount indicates an expected call of GetDailyInteractionCount.
-func (mr *MockRepositoryMockRecorder) GetDailyInteractionCount(ctx, userID, since any) *gomock.Call {
+// GetDailyUsage indicates an expected call of GetDailyUsage.
+func (mr *MockRepositoryMockRecorder) GetDailyUsage(ctx, userID, day any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyInteractionCount", reflect.TypeOf((*MockRepository)(nil).GetDailyInteractionCount), ctx, userID, since)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyUsage", reflect.TypeOf((*MockRepository)(nil).GetDailyUsage), ctx, userID, day)
 }
 
 // GetFeatureByID mocks base method.
@@ -132,6 +482,21 @@ func (mr *MockRepositoryMockRecorder) GetFeatureByID(ctx, featureID any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeatureByID", reflect.TypeOf((*MockRepository)(nil).GetFeatureByID), ctx, featureID)
 }
 
+// GetFeatureByName mocks base method.
+func (m *MockRepository) GetFeatureByName(ctx context.Context, name string) (*internal.SubscriptionFeature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeatureByName", ctx, name)
+	ret0, _ := ret[0].(*internal.SubscriptionFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeatureByName indicates an expected call of GetFeatureByName.
+func (mr *MockRepositoryMockRecorder) GetFeatureByName(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeatureByName", reflect.TypeOf((*MockRepository)(nil).GetFeatureByName), ctx, name)
+}
+
 // GetFeatures mocks base method.
 func (m *MockRepository) GetFeatures(ctx context.Context) ([]*internal.SubscriptionFeature, error) {
 	m.ctrl.T.Helper()
@@ -147,62 +512,704 @@ func (mr *MockRepositoryMockRecorder) GetFeatures(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeatures", reflect.TypeOf((*MockRepository)(nil).GetFeatures), ctx)
 }
 
-// GetProfiles mocks base method.
-func (m *MockRepository) GetProfiles(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.User, error) {
+// GetInvoice mocks base method.
+func (m *MockRepository) GetInvoice(ctx context.Context, userID, invoiceID uuid.UUID) (*internal.Invoice, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetProfiles", ctx, userID, limit)
-	ret0, _ := ret[0].([]*internal.User)
+	ret := m.ctrl.Call(m, "GetInvoice", ctx, userID, invoiceID)
+	ret0, _ := ret[0].(*internal.Invoice)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetProfiles indicates an expected call of GetProfiles.
-func (mr *MockRepositoryMockRecorder) GetProfiles(ctx, userID, limit any) *gomock.Call {
+// GetInvoice indicates an expected call of GetInvoice.
+func (mr *MockRepositoryMockRecorder) GetInvoice(ctx, userID, invoiceID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfiles", reflect.TypeOf((*MockRepository)(nil).GetProfiles), ctx, userID, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvoice", reflect.TypeOf((*MockRepository)(nil).GetInvoice), ctx, userID, invoiceID)
 }
 
-// GetUserByEmail mocks base method.
-func (m *MockRepository) GetUserByEmail(ctx context.Context, email string) (*internal.User, error) {
+// GetInvoices mocks base method.
+func (m *MockRepository) GetInvoices(ctx context.Context, userID uuid.UUID) ([]*internal.Invoice, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
-	ret0, _ := ret[0].(*internal.User)
+	ret := m.ctrl.Call(m, "GetInvoices", ctx, userID)
+	ret0, _ := ret[0].([]*internal.Invoice)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByEmail indicates an expected call of GetUserByEmail.
-func (mr *MockRepositoryMockRecorder) GetUserByEmail(ctx, email any) *gomock.Call {
+// GetInvoices indicates an expected call of GetInvoices.
+func (mr *MockRepositoryMockRecorder) GetInvoices(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockRepository)(nil).GetUserByEmail), ctx, email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvoices", reflect.TypeOf((*MockRepository)(nil).GetInvoices), ctx, userID)
 }
 
-// GetUserFeatures mocks base method.
-func (m *MockRepository) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*internal.UserFeature, error) {
+// GetLikesReceived mocks base method.
+func (m *MockRepository) GetLikesReceived(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.ReceivedLike, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserFeatures", ctx, userID)
-	ret0, _ := ret[0].([]*internal.UserFeature)
+	ret := m.ctrl.Call(m, "GetLikesReceived", ctx, userID, limit)
+	ret0, _ := ret[0].([]*internal.ReceivedLike)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserFeatures indicates an expected call of GetUserFeatures.
-func (mr *MockRepositoryMockRecorder) GetUserFeatures(ctx, userID any) *gomock.Call {
+// GetLikesReceived indicates an expected call of GetLikesReceived.
+func (mr *MockRepositoryMockRecorder) GetLikesReceived(ctx, userID, limit any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserFeatures", reflect.TypeOf((*MockRepository)(nil).GetUserFeatures), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLikesReceived", reflect.TypeOf((*MockRepository)(nil).GetLikesReceived), ctx, userID, limit)
 }
 
-// HasActiveFeature mocks base method.
-func (m *MockRepository) HasActiveFeature(ctx context.Context, userID uuid.UUID, featureName string) (bool, error) {
+// GetLiveSubscription mocks base method.
+func (m *MockRepository) GetLiveSubscription(ctx context.Context, tx *sqlx.Tx, userID, featureID uuid.UUID) (*internal.UserFeature, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "HasActiveFeature", ctx, userID, featureName)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "GetLiveSubscription", ctx, tx, userID, featureID)
+	ret0, _ := ret[0].(*internal.UserFeature)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// HasActiveFeature indicates an expected call of HasActiveFeature.
-func (mr *MockRepositoryMockRecorder) HasActiveFeature(ctx, userID, featureName any) *gomock.Call {
+// GetLiveSubscription indicates an expected call of GetLiveSubscription.
+func (mr *MockRepositoryMockRecorder) GetLiveSubscription(ctx, tx, userID, featureID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasActiveFeature", reflect.TypeOf((*MockRepository)(nil).HasActiveFeature), ctx, userID, featureName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLiveSubscription", reflect.TypeOf((*MockRepository)(nil).GetLiveSubscription), ctx, tx, userID, featureID)
+}
+
+// GetLoginAttempt mocks base method.
+func (m *MockRepository) GetLoginAttempt(ctx context.Context, key string) (*internal.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginAttempt", ctx, key)
+	ret0, _ := ret[0].(*internal.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginAttempt indicates an expected call of GetLoginAttempt.
+func (mr *MockRepositoryMockRecorder) GetLoginAttempt(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginAttempt", reflect.TypeOf((*MockRepository)(nil).GetLoginAttempt), ctx, key)
+}
+
+// GetNotifications mocks base method.
+func (m *MockRepository) GetNotifications(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotifications", ctx, userID, limit)
+	ret0, _ := ret[0].([]*internal.Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotifications indicates an expected call of GetNotifications.
+func (mr *MockRepositoryMockRecorder) GetNotifications(ctx, userID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotifications", reflect.TypeOf((*MockRepository)(nil).GetNotifications), ctx, userID, limit)
+}
+
+// GetPayment mocks base method.
+func (m *MockRepository) GetPayment(ctx context.Context, tx *sqlx.Tx, paymentID uuid.UUID) (*internal.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPayment", ctx, tx, paymentID)
+	ret0, _ := ret[0].(*internal.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPayment indicates an expected call of GetPayment.
+func (mr *MockRepositoryMockRecorder) GetPayment(ctx, tx, paymentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayment", reflect.TypeOf((*MockRepository)(nil).GetPayment), ctx, tx, paymentID)
+}
+
+// GetPaymentByProviderID mocks base method.
+func (m *MockRepository) GetPaymentByProviderID(ctx context.Context, tx *sqlx.Tx, provider, providerPaymentID string) (*internal.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentByProviderID", ctx, tx, provider, providerPaymentID)
+	ret0, _ := ret[0].(*internal.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentByProviderID indicates an expected call of GetPaymentByProviderID.
+func (mr *MockRepositoryMockRecorder) GetPaymentByProviderID(ctx, tx, provider, providerPaymentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentByProviderID", reflect.TypeOf((*MockRepository)(nil).GetPaymentByProviderID), ctx, tx, provider, providerPaymentID)
+}
+
+// GetPendingRefunds mocks base method.
+func (m *MockRepository) GetPendingRefunds(ctx context.Context, retryAfter time.Duration) ([]*internal.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingRefunds", ctx, retryAfter)
+	ret0, _ := ret[0].([]*internal.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingRefunds indicates an expected call of GetPendingRefunds.
+func (mr *MockRepositoryMockRecorder) GetPendingRefunds(ctx, retryAfter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingRefunds", reflect.TypeOf((*MockRepository)(nil).GetPendingRefunds), ctx, retryAfter)
+}
+
+// GetPlanFeatures mocks base method.
+func (m *MockRepository) GetPlanFeatures(ctx context.Context, planID uuid.UUID) ([]*internal.PlanFeature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlanFeatures", ctx, planID)
+	ret0, _ := ret[0].([]*internal.PlanFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlanFeatures indicates an expected call of GetPlanFeatures.
+func (mr *MockRepositoryMockRecorder) GetPlanFeatures(ctx, planID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlanFeatures", reflect.TypeOf((*MockRepository)(nil).GetPlanFeatures), ctx, planID)
+}
+
+// GetPlanPrice mocks base method.
+func (m *MockRepository) GetPlanPrice(ctx context.Context, featureID uuid.UUID, planID *uuid.UUID, period, currency string) (*internal.PlanPrice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlanPrice", ctx, featureID, planID, period, currency)
+	ret0, _ := ret[0].(*internal.PlanPrice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlanPrice indicates an expected call of GetPlanPrice.
+func (mr *MockRepositoryMockRecorder) GetPlanPrice(ctx, featureID, planID, period, currency any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlanPrice", reflect.TypeOf((*MockRepository)(nil).GetPlanPrice), ctx, featureID, planID, period, currency)
+}
+
+// GetPlanPriceByID mocks base method.
+func (m *MockRepository) GetPlanPriceByID(ctx context.Context, planPriceID uuid.UUID) (*internal.PlanPrice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlanPriceByID", ctx, planPriceID)
+	ret0, _ := ret[0].(*internal.PlanPrice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlanPriceByID indicates an expected call of GetPlanPriceByID.
+func (mr *MockRepositoryMockRecorder) GetPlanPriceByID(ctx, planPriceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlanPriceByID", reflect.TypeOf((*MockRepository)(nil).GetPlanPriceByID), ctx, planPriceID)
+}
+
+// GetPlans mocks base method.
+func (m *MockRepository) GetPlans(ctx context.Context) ([]*internal.Plan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlans", ctx)
+	ret0, _ := ret[0].([]*internal.Plan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlans indicates an expected call of GetPlans.
+func (mr *MockRepositoryMockRecorder) GetPlans(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlans", reflect.TypeOf((*MockRepository)(nil).GetPlans), ctx)
+}
+
+// GetProfiles mocks base method.
+func (m *MockRepository) GetProfiles(ctx context.Context, userID uuid.UUID, radiusKm, limit int) ([]*internal.Candidate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfiles", ctx, userID, radiusKm, limit)
+	ret0, _ := ret[0].([]*internal.Candidate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfiles indicates an expected call of GetProfiles.
+func (mr *MockRepositoryMockRecorder) GetProfiles(ctx, userID, radiusKm, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfiles", reflect.TypeOf((*MockRepository)(nil).GetProfiles), ctx, userID, radiusKm, limit)
+}
+
+// GetPromoCodeForUpdate mocks base method.
+func (m *MockRepository) GetPromoCodeForUpdate(ctx context.Context, tx *sqlx.Tx, code string) (*internal.PromoCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPromoCodeForUpdate", ctx, tx, code)
+	ret0, _ := ret[0].(*internal.PromoCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPromoCodeForUpdate indicates an expected call of GetPromoCodeForUpdate.
+func (mr *MockRepositoryMockRecorder) GetPromoCodeForUpdate(ctx, tx, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPromoCodeForUpdate", reflect.TypeOf((*MockRepository)(nil).GetPromoCodeForUpdate), ctx, tx, code)
+}
+
+// GetTrialPlan mocks base method.
+func (m *MockRepository) GetTrialPlan(ctx context.Context, featureID uuid.UUID, planID *uuid.UUID) (*internal.Plan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrialPlan", ctx, featureID, planID)
+	ret0, _ := ret[0].(*internal.Plan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrialPlan indicates an expected call of GetTrialPlan.
+func (mr *MockRepositoryMockRecorder) GetTrialPlan(ctx, featureID, planID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrialPlan", reflect.TypeOf((*MockRepository)(nil).GetTrialPlan), ctx, featureID, planID)
+}
+
+// GetUnsettledPayments mocks base method.
+func (m *MockRepository) GetUnsettledPayments(ctx context.Context, provider string, olderThan time.Duration) ([]*internal.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnsettledPayments", ctx, provider, olderThan)
+	ret0, _ := ret[0].([]*internal.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnsettledPayments indicates an expected call of GetUnsettledPayments.
+func (mr *MockRepositoryMockRecorder) GetUnsettledPayments(ctx, provider, olderThan any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnsettledPayments", reflect.TypeOf((*MockRepository)(nil).GetUnsettledPayments), ctx, provider, olderThan)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockRepository) GetUserByEmail(ctx context.Context, email string) (*internal.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(*internal.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockRepositoryMockRecorder) GetUserByEmail(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockRepository)(nil).GetUserByEmail), ctx, email)
+}
+
+// GetUserByID mocks base method.
+func (m *MockRepository) GetUserByID(ctx context.Context, userID uuid.UUID) (*internal.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, userID)
+	ret0, _ := ret[0].(*internal.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockRepositoryMockRecorder) GetUserByID(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepository)(nil).GetUserByID), ctx, userID)
+}
+
+// GetUserByIdentity mocks base method.
+func (m *MockRepository) GetUserByIdentity(ctx context.Context, provider, subject string) (*internal.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByIdentity", ctx, provider, subject)
+	ret0, _ := ret[0].(*internal.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByIdentity indicates an expected call of GetUserByIdentity.
+func (mr *MockRepositoryMockRecorder) GetUserByIdentity(ctx, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByIdentity", reflect.TypeOf((*MockRepository)(nil).GetUserByIdentity), ctx, provider, subject)
+}
+
+// GetUserFeatures mocks base method.
+func (m *MockRepository) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*internal.UserFeature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserFeatures", ctx, userID)
+	ret0, _ := ret[0].([]*internal.UserFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserFeatures indicates an expected call of GetUserFeatures.
+func (mr *MockRepositoryMockRecorder) GetUserFeatures(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserFeatures", reflect.TypeOf((*MockRepository)(nil).GetUserFeatures), ctx, userID)
+}
+
+// GetWeeklySuperLikes mocks base method.
+func (m *MockRepository) GetWeeklySuperLikes(ctx context.Context, userID uuid.UUID, weekStart time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWeeklySuperLikes", ctx, userID, weekStart)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWeeklySuperLikes indicates an expected call of GetWeeklySuperLikes.
+func (mr *MockRepositoryMockRecorder) GetWeeklySuperLikes(ctx, userID, weekStart any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWeeklySuperLikes", reflect.TypeOf((*MockRepository)(nil).GetWeeklySuperLikes), ctx, userID, weekStart)
+}
+
+// HasActiveFeature mocks base method.
+func (m *MockRepository) HasActiveFeature(ctx context.Context, userID uuid.UUID, featureName string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasActiveFeature", ctx, userID, featureName)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasActiveFeature indicates an expected call of HasActiveFeature.
+func (mr *MockRepositoryMockRecorder) HasActiveFeature(ctx, userID, featureName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasActiveFeature", reflect.TypeOf((*MockRepository)(nil).HasActiveFeature), ctx, userID, featureName)
+}
+
+// HasRunningBoost mocks base method.
+func (m *MockRepository) HasRunningBoost(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasRunningBoost", ctx, tx, userID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasRunningBoost indicates an expected call of HasRunningBoost.
+func (mr *MockRepositoryMockRecorder) HasRunningBoost(ctx, tx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasRunningBoost", reflect.TypeOf((*MockRepository)(nil).HasRunningBoost), ctx, tx, userID)
+}
+
+// LockLogin mocks base method.
+func (m *MockRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLogin", ctx, key, until)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLogin indicates an expected call of LockLogin.
+func (mr *MockRepositoryMockRecorder) LockLogin(ctx, key, until any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLogin", reflect.TypeOf((*MockRepository)(nil).LockLogin), ctx, key, until)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*internal.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, key, window)
+	ret0, _ := ret[0].(*internal.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockRepositoryMockRecorder) RecordLoginFailure(ctx, key, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockRepository)(nil).RecordLoginFailure), ctx, key, window)
+}
+
+// RecordProfileViews mocks base method.
+func (m *MockRepository) RecordProfileViews(ctx context.Context, viewerID uuid.UUID, userIDs []uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordProfileViews", ctx, viewerID, userIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordProfileViews indicates an expected call of RecordProfileViews.
+func (mr *MockRepositoryMockRecorder) RecordProfileViews(ctx, viewerID, userIDs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordProfileViews", reflect.TypeOf((*MockRepository)(nil).RecordProfileViews), ctx, viewerID, userIDs)
+}
+
+// RecordRefund mocks base method.
+func (m *MockRepository) RecordRefund(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordRefund", ctx, tx, payment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordRefund indicates an expected call of RecordRefund.
+func (mr *MockRepositoryMockRecorder) RecordRefund(ctx, tx, payment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordRefund", reflect.TypeOf((*MockRepository)(nil).RecordRefund), ctx, tx, payment)
+}
+
+// RefundDailyResponse mocks base method.
+func (m *MockRepository) RefundDailyResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, day time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundDailyResponse", ctx, tx, userID, day)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RefundDailyResponse indicates an expected call of RefundDailyResponse.
+func (mr *MockRepositoryMockRecorder) RefundDailyResponse(ctx, tx, userID, day any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundDailyResponse", reflect.TypeOf((*MockRepository)(nil).RefundDailyResponse), ctx, tx, userID, day)
+}
+
+// ReleasePromoRedemption mocks base method.
+func (m *MockRepository) ReleasePromoRedemption(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleasePromoRedemption", ctx, tx, bundleID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleasePromoRedemption indicates an expected call of ReleasePromoRedemption.
+func (mr *MockRepositoryMockRecorder) ReleasePromoRedemption(ctx, tx, bundleID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleasePromoRedemption", reflect.TypeOf((*MockRepository)(nil).ReleasePromoRedemption), ctx, tx, bundleID)
+}
+
+// ReplaceChangedSubscription mocks base method.
+func (m *MockRepository) ReplaceChangedSubscription(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceChangedSubscription", ctx, tx, bundleID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceChangedSubscription indicates an expected call of ReplaceChangedSubscription.
+func (mr *MockRepositoryMockRecorder) ReplaceChangedSubscription(ctx, tx, bundleID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceChangedSubscription", reflect.TypeOf((*MockRepository)(nil).ReplaceChangedSubscription), ctx, tx, bundleID)
+}
+
+// ReplaceRecoveryCodes mocks base method.
+func (m *MockRepository) ReplaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceRecoveryCodes", ctx, tx, userID, codeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceRecoveryCodes indicates an expected call of ReplaceRecoveryCodes.
+func (mr *MockRepositoryMockRecorder) ReplaceRecoveryCodes(ctx, tx, userID, codeHashes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceRecoveryCodes", reflect.TypeOf((*MockRepository)(nil).ReplaceRecoveryCodes), ctx, tx, userID, codeHashes)
+}
+
+// ReplaceSubscription mocks base method.
+func (m *MockRepository) ReplaceSubscription(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceSubscription", ctx, tx, subscription)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceSubscription indicates an expected call of ReplaceSubscription.
+func (mr *MockRepositoryMockRecorder) ReplaceSubscription(ctx, tx, subscription any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceSubscription", reflect.TypeOf((*MockRepository)(nil).ReplaceSubscription), ctx, tx, subscription)
+}
+
+// ResetLoginAttempts mocks base method.
+func (m *MockRepository) ResetLoginAttempts(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetLoginAttempts", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetLoginAttempts indicates an expected call of ResetLoginAttempts.
+func (mr *MockRepositoryMockRecorder) ResetLoginAttempts(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetLoginAttempts", reflect.TypeOf((*MockRepository)(nil).ResetLoginAttempts), ctx, key)
+}
+
+// RevokeGrant mocks base method.
+func (m *MockRepository) RevokeGrant(ctx context.Context, tx *sqlx.Tx, userID, featureID uuid.UUID) (*internal.UserFeature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeGrant", ctx, tx, userID, featureID)
+	ret0, _ := ret[0].(*internal.UserFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeGrant indicates an expected call of RevokeGrant.
+func (mr *MockRepositoryMockRecorder) RevokeGrant(ctx, tx, userID, featureID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeGrant", reflect.TypeOf((*MockRepository)(nil).RevokeGrant), ctx, tx, userID, featureID)
+}
+
+// SetAutoRenew mocks base method.
+func (m *MockRepository) SetAutoRenew(ctx context.Context, userID, featureID uuid.UUID, autoRenew bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAutoRenew", ctx, userID, featureID, autoRenew)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAutoRenew indicates an expected call of SetAutoRenew.
+func (mr *MockRepositoryMockRecorder) SetAutoRenew(ctx, userID, featureID, autoRenew any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAutoRenew", reflect.TypeOf((*MockRepository)(nil).SetAutoRenew), ctx, userID, featureID, autoRenew)
+}
+
+// SetUserAdmin mocks base method.
+func (m *MockRepository) SetUserAdmin(ctx context.Context, userID uuid.UUID, isAdmin bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserAdmin", ctx, userID, isAdmin)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserAdmin indicates an expected call of SetUserAdmin.
+func (mr *MockRepositoryMockRecorder) SetUserAdmin(ctx, userID, isAdmin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserAdmin", reflect.TypeOf((*MockRepository)(nil).SetUserAdmin), ctx, userID, isAdmin)
+}
+
+// UpdateBoostStatus mocks base method.
+func (m *MockRepository) UpdateBoostStatus(ctx context.Context, tx *sqlx.Tx, boostID uuid.UUID, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBoostStatus", ctx, tx, boostID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateBoostStatus indicates an expected call of UpdateBoostStatus.
+func (mr *MockRepositoryMockRecorder) UpdateBoostStatus(ctx, tx, boostID, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBoostStatus", reflect.TypeOf((*MockRepository)(nil).UpdateBoostStatus), ctx, tx, boostID, status)
+}
+
+// UpdateBundleStatus mocks base method.
+func (m *MockRepository) UpdateBundleStatus(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBundleStatus", ctx, tx, bundleID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateBundleStatus indicates an expected call of UpdateBundleStatus.
+func (mr *MockRepositoryMockRecorder) UpdateBundleStatus(ctx, tx, bundleID, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBundleStatus", reflect.TypeOf((*MockRepository)(nil).UpdateBundleStatus), ctx, tx, bundleID, status)
+}
+
+// UpdatePayment mocks base method.
+func (m *MockRepository) UpdatePayment(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePayment", ctx, tx, payment)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePayment indicates an expected call of UpdatePayment.
+func (mr *MockRepositoryMockRecorder) UpdatePayment(ctx, tx, payment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePayment", reflect.TypeOf((*MockRepository)(nil).UpdatePayment), ctx, tx, payment)
+}
+
+// UpdateRefund mocks base method.
+func (m *MockRepository) UpdateRefund(ctx context.Context, tx *sqlx.Tx, refund *internal.Refund) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRefund", ctx, tx, refund)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRefund indicates an expected call of UpdateRefund.
+func (mr *MockRepositoryMockRecorder) UpdateRefund(ctx, tx, refund any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRefund", reflect.TypeOf((*MockRepository)(nil).UpdateRefund), ctx, tx, refund)
+}
+
+// UpdateUserIncognito mocks base method.
+func (m *MockRepository) UpdateUserIncognito(ctx context.Context, userID uuid.UUID, incognito bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserIncognito", ctx, userID, incognito)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserIncognito indicates an expected call of UpdateUserIncognito.
+func (mr *MockRepositoryMockRecorder) UpdateUserIncognito(ctx, userID, incognito any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserIncognito", reflect.TypeOf((*MockRepository)(nil).UpdateUserIncognito), ctx, userID, incognito)
+}
+
+// UpdateUserLocation mocks base method.
+func (m *MockRepository) UpdateUserLocation(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserLocation", ctx, userID, location)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserLocation indicates an expected call of UpdateUserLocation.
+func (mr *MockRepositoryMockRecorder) UpdateUserLocation(ctx, userID, location any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserLocation", reflect.TypeOf((*MockRepository)(nil).UpdateUserLocation), ctx, userID, location)
+}
+
+// UpdateUserPassport mocks base method.
+func (m *MockRepository) UpdateUserPassport(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassport", ctx, userID, location)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPassport indicates an expected call of UpdateUserPassport.
+func (mr *MockRepositoryMockRecorder) UpdateUserPassport(ctx, userID, location any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassport", reflect.TypeOf((*MockRepository)(nil).UpdateUserPassport), ctx, userID, location)
+}
+
+// UpdateUserTOTPSecret mocks base method.
+func (m *MockRepository) UpdateUserTOTPSecret(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTOTPSecret", ctx, tx, userID, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserTOTPSecret indicates an expected call of UpdateUserTOTPSecret.
+func (mr *MockRepositoryMockRecorder) UpdateUserTOTPSecret(ctx, tx, userID, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTOTPSecret", reflect.TypeOf((*MockRepository)(nil).UpdateUserTOTPSecret), ctx, tx, userID, secret)
+}
+
+// UpdateUserTimezone mocks base method.
+func (m *MockRepository) UpdateUserTimezone(ctx context.Context, userID uuid.UUID, timezone, previous string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTimezone", ctx, userID, timezone, previous)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserTimezone indicates an expected call of UpdateUserTimezone.
+func (mr *MockRepositoryMockRecorder) UpdateUserTimezone(ctx, userID, timezone, previous any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTimezone", reflect.TypeOf((*MockRepository)(nil).UpdateUserTimezone), ctx, userID, timezone, previous)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockRepository) UseRecoveryCode(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, tx, userID, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockRepositoryMockRecorder) UseRecoveryCode(ctx, tx, userID, codeHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockRepository)(nil).UseRecoveryCode), ctx, tx, userID, codeHash)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, userID, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockRepositoryMockRecorder) UseTOTPStep(ctx, userID, step any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockRepository)(nil).UseTOTPStep), ctx, userID, step)
 }
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repository) CreateNotification(ctx context.Context, tx *sqlx.Tx, notification *internal.Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, type, actor_id, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		notification.UserID,
+		notification.Type,
+		notification.ActorID,
+	).Scan(&notification.ID, &notification.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert notification: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotifications returns the user's latest notifications, newest first.
+func (r *repository) GetNotifications(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.type, n.actor_id, u.name AS actor_name, n.created_at
+		FROM notifications n
+		LEFT JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1
+		ORDER BY n.created_at DESC
+		LIMIT $2`
+
+	notifications := []*internal.Notification{}
+	if err := r.db.SelectContext(ctx, &notifications, query, userID, limit); err != nil {
+		return nil, fmt.Errorf("select notifications: %w", err)
+	}
+
+	return notifications, nil
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repository) CreatePayment(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) error {
+	query := `
+		INSERT INTO payments (user_id, bundle_id, provider, amount, currency, status, description,
+			payment_method, renewal, boost, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		payment.UserID,
+		payment.BundleID,
+		payment.Provider,
+		payment.Amount,
+		payment.Currency,
+		payment.Status,
+		payment.Description,
+		payment.PaymentMethod,
+		payment.Renewal,
+		payment.Boost,
+	).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("insert payment: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePayment stores the provider payment ID and status of payment while
+// it is still awaiting its outcome. It returns false when the payment was
+// already settled, e.g. by a webhook that arrived first.
+func (r *repository) UpdatePayment(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) (bool, error) {
+	query := `
+		UPDATE payments
+		SET provider_payment_id = $2, status = $3, updated_at = NOW()
+		WHERE id = $1
+			AND status IN ('pending', 'authorized')`
+
+	result, err := tx.ExecContext(ctx, query, payment.ID, payment.ProviderPaymentID, payment.Status)
+	if err != nil {
+		return false, fmt.Errorf("update payment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("update payment: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// UpdateBundleStatus sets the status of every user feature bought together
+// under bundleID.
+func (r *repository) UpdateBundleStatus(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID, status string) error {
+	query := `
+		UPDATE user_features
+		SET status = $2, updated_at = NOW()
+		WHERE bundle_id = $1`
+
+	_, err := tx.ExecContext(ctx, query, bundleID, status)
+	if err != nil {
+		return fmt.Errorf("update bundle status: %w", err)
+	}
+
+	return nil
+}
+
+// CreatePaymentEvent records event with its raw payload. It returns false when
+// the provider already delivered the event.
+func (r *repository) CreatePaymentEvent(ctx context.Context, tx *sqlx.Tx, event *internal.PaymentEvent, payload []byte) (bool, error) {
+	query := `
+		INSERT INTO payment_events (provider, provider_event_id, event_type, provider_payment_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5::jsonb, NOW())
+		ON CONFLICT (provider, provider_event_id) DO NOTHING
+		RETURNING id, created_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		event.Provider,
+		event.ProviderEventID,
+		event.Type,
+		event.ProviderPaymentID,
+		string(payload),
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("insert payment event: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetPaymentByProviderID locks and returns the payment the provider knows as
+// providerPaymentID.
+func (r *repository) GetPaymentByProviderID(ctx context.Context, tx *sqlx.Tx, provider, providerPaymentID string) (*internal.Payment, error) {
+	payment := &internal.Payment{}
+	query := `
+		SELECT id, user_id, bundle_id, provider, provider_payment_id, amount, currency, status,
+			description, refunded_amount, payment_method, renewal, boost, created_at, updated_at
+		FROM payments
+		WHERE provider = $1
+			AND provider_payment_id = $2
+		FOR UPDATE`
+
+	err := tx.GetContext(ctx, payment, query, provider, providerPaymentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("select payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// GetPayment locks and returns the payment with paymentID.
+func (r *repository) GetPayment(ctx context.Context, tx *sqlx.Tx, paymentID uuid.UUID) (*internal.Payment, error) {
+	payment := &internal.Payment{}
+	query := `
+		SELECT id, user_id, bundle_id, provider, provider_payment_id, amount, currency, status,
+			description, refunded_amount, payment_method, renewal, boost, created_at, updated_at
+		FROM payments
+		WHERE id = $1
+		FOR UPDATE`
+
+	err := tx.GetContext(ctx, payment, query, paymentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("select payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// GetBundlePayment locks and returns the latest succeeded payment for
+// bundleID.
+func (r *repository) GetBundlePayment(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) (*internal.Payment, error) {
+	payment := &internal.Payment{}
+	query := `
+		SELECT id, user_id, bundle_id, provider, provider_payment_id, amount, currency, status,
+			description, refunded_amount, payment_method, renewal, boost, created_at, updated_at
+		FROM payments
+		WHERE bundle_id = $1
+			AND status = 'succeeded'
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE`
+
+	err := tx.GetContext(ctx, payment, query, bundleID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("select bundle payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// GetUnsettledPayments returns the payments with provider that have awaited
+// their outcome for at least olderThan.
+func (r *repository) GetUnsettledPayments(ctx context.Context, provider string, olderThan time.Duration) ([]*internal.Payment, error) {
+	var payments []*internal.Payment
+	query := `
+		SELECT id, user_id, bundle_id, provider, provider_payment_id, amount, currency, status,
+			description, refunded_amount, payment_method, renewal, boost, created_at, updated_at
+		FROM payments
+		WHERE provider = $1
+			AND status IN ('pending', 'authorized')
+			AND created_at <= NOW() - $2 * INTERVAL '1 second'
+		ORDER BY created_at`
+
+	err := r.db.SelectContext(ctx, &payments, query, provider, olderThan.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("select unsettled payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// RecordRefund stores the refunded amount and status of payment.
+func (r *repository) RecordRefund(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) error {
+	query := `
+		UPDATE payments
+		SET refunded_amount = $2, status = $3, updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := tx.ExecContext(ctx, query, payment.ID, payment.RefundedAmount, payment.Status)
+	if err != nil {
+		return fmt.Errorf("record refund: %w", err)
+	}
+
+	return nil
+}
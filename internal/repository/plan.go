@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+)
+
+// GetPlans returns the active plans with their bundled features and active
+// prices.
+func (r *repository) GetPlans(ctx context.Context) ([]*internal.Plan, error) {
+	var plans []*internal.Plan
+	query := `
+		SELECT id, name, COALESCE(description, '') AS description, active, trial_days, created_at, updated_at
+		FROM plans
+		WHERE active = TRUE
+		ORDER BY name`
+
+	if err := r.db.SelectContext(ctx, &plans, query); err != nil {
+		return nil, fmt.Errorf("select plans: %w", err)
+	}
+
+	var features []*internal.PlanFeature
+	featuresQuery := `
+		SELECT pf.plan_id, pf.feature_id, sf.name AS feature_name, pf.value
+		FROM plan_features pf
+		JOIN plans p ON p.id = pf.plan_id
+		JOIN subscription_features sf ON sf.id = pf.feature_id
+		WHERE p.active = TRUE
+		ORDER BY sf.name`
+
+	if err := r.db.SelectContext(ctx, &features, featuresQuery); err != nil {
+		return nil, fmt.Errorf("select plan features: %w", err)
+	}
+
+	var prices []*internal.PlanPrice
+	pricesQuery := `
+		SELECT pp.id, pp.plan_id, pp.period, pp.period_months, pp.amount, pp.currency,
+			pp.active, pp.created_at, pp.updated_at
+		FROM plan_prices pp
+		JOIN plans p ON p.id = pp.plan_id
+		WHERE p.active = TRUE
+			AND pp.active = TRUE
+		ORDER BY pp.currency, pp.period_months`
+
+	if err := r.db.SelectContext(ctx, &prices, pricesQuery); err != nil {
+		return nil, fmt.Errorf("select plan prices: %w", err)
+	}
+
+	byID := make(map[uuid.UUID]*internal.Plan, len(plans))
+	for _, p := range plans {
+		p.Features = []*internal.PlanFeature{}
+		p.Prices = []*internal.PlanPrice{}
+		byID[p.ID] = p
+	}
+	for _, f := range features {
+		byID[f.PlanID].Features = append(byID[f.PlanID].Features, f)
+	}
+	for _, p := range prices {
+		byID[p.PlanID].Prices = append(byID[p.PlanID].Prices, p)
+	}
+
+	return plans, nil
+}
+
+// GetPlanPrice finds the active price for period and currency of an active
+// plan bundling featureID. If planID is nil the cheapest such plan is chosen.
+func (r *repository) GetPlanPrice(ctx context.Context, featureID uuid.UUID, planID *uuid.UUID, period, currency string) (*internal.PlanPrice, error) {
+	price := &internal.PlanPrice{}
+	query := `
+		SELECT pp.id, pp.plan_id, pp.period, pp.period_months, pp.amount, pp.currency,
+			pp.active, pp.created_at, pp.updated_at
+		FROM plan_prices pp
+		JOIN plans p ON p.id = pp.plan_id
+		JOIN plan_features pf ON pf.plan_id = p.id
+		WHERE pf.feature_id = $1
+			AND ($2::uuid IS NULL OR p.id = $2)
+			AND pp.period = $3
+			AND pp.currency = $4
+			AND p.active = TRUE
+			AND pp.active = TRUE
+		ORDER BY pp.amount
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, price, query, featureID, planID, period, currency)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrPlanPriceNotFound
+		}
+		return nil, fmt.Errorf("select plan price: %w", err)
+	}
+
+	return price, nil
+}
+
+// GetPlanPriceByID returns the plan price with planPriceID, active or not.
+func (r *repository) GetPlanPriceByID(ctx context.Context, planPriceID uuid.UUID) (*internal.PlanPrice, error) {
+	price := &internal.PlanPrice{}
+	query := `
+		SELECT id, plan_id, period, period_months, amount, currency, active, created_at, updated_at
+		FROM plan_prices
+		WHERE id = $1`
+
+	err := r.db.GetContext(ctx, price, query, planPriceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrPlanPriceNotFound
+		}
+		return nil, fmt.Errorf("select plan price: %w", err)
+	}
+
+	return price, nil
+}
+
+// GetTrialPlan finds an active plan bundling featureID that offers a free
+// trial, preferring the longest trial when planID is nil.
+func (r *repository) GetTrialPlan(ctx context.Context, featureID uuid.UUID, planID *uuid.UUID) (*internal.Plan, error) {
+	plan := &internal.Plan{}
+	query := `
+		SELECT p.id, p.name, COALESCE(p.description, '') AS description, p.active, p.trial_days,
+			p.created_at, p.updated_at
+		FROM plans p
+		JOIN plan_features pf ON pf.plan_id = p.id
+		WHERE pf.feature_id = $1
+			AND ($2::uuid IS NULL OR p.id = $2)
+			AND p.active = TRUE
+			AND p.trial_days > 0
+		ORDER BY p.trial_days DESC
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, plan, query, featureID, planID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrTrialUnavailable
+		}
+		return nil, fmt.Errorf("select trial plan: %w", err)
+	}
+
+	return plan, nil
+}
+
+func (r *repository) GetPlanFeatures(ctx context.Context, planID uuid.UUID) ([]*internal.PlanFeature, error) {
+	var features []*internal.PlanFeature
+	query := `
+		SELECT pf.plan_id, pf.feature_id, sf.name AS feature_name, pf.value
+		FROM plan_features pf
+		JOIN subscription_features sf ON sf.id = pf.feature_id
+		WHERE pf.plan_id = $1
+		ORDER BY sf.name`
+
+	if err := r.db.SelectContext(ctx, &features, query, planID); err != nil {
+		return nil, fmt.Errorf("select plan features: %w", err)
+	}
+
+	return features, nil
+}
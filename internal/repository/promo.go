@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// GetPromoCodeForUpdate locks and returns the promo code with its plan
+// restrictions.
+func (r *repository) GetPromoCodeForUpdate(ctx context.Context, tx *sqlx.Tx, code string) (*internal.PromoCode, error) {
+	promo := &internal.PromoCode{}
+	query := `
+		SELECT id, code, discount_type, discount_value, currency, max_redemptions, redemption_count,
+			expires_at, active, created_at, updated_at
+		FROM promo_codes
+		WHERE code = $1
+		FOR UPDATE`
+
+	err := tx.GetContext(ctx, promo, query, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrPromoCodeNotFound
+		}
+		return nil, fmt.Errorf("select promo code: %w", err)
+	}
+
+	plansQuery := `SELECT plan_id FROM promo_code_plans WHERE promo_code_id = $1`
+	if err := tx.SelectContext(ctx, &promo.PlanIDs, plansQuery, promo.ID); err != nil {
+		return nil, fmt.Errorf("select promo code plans: %w", err)
+	}
+
+	return promo, nil
+}
+
+// CreatePromoRedemption records the redemption and counts it against the
+// code's redemption limit.
+func (r *repository) CreatePromoRedemption(ctx context.Context, tx *sqlx.Tx, redemption *internal.PromoRedemption) error {
+	query := `
+		INSERT INTO promo_redemptions (promo_code_id, user_id, bundle_id, discount_amount, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, created_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		redemption.PromoCodeID,
+		redemption.UserID,
+		redemption.BundleID,
+		redemption.DiscountAmount,
+	).Scan(&redemption.ID, &redemption.CreatedAt)
+	if err != nil {
+		if isPgUniqueViolation(err) {
+			return internal.ErrPromoCodeAlreadyRedeemed
+		}
+		return fmt.Errorf("insert promo redemption: %w", err)
+	}
+
+	countQuery := `
+		UPDATE promo_codes
+		SET redemption_count = redemption_count + 1, updated_at = NOW()
+		WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, countQuery, redemption.PromoCodeID); err != nil {
+		return fmt.Errorf("count promo redemption: %w", err)
+	}
+
+	return nil
+}
+
+// ReleasePromoRedemption undoes the redemption made for bundleID, if any, so
+// the code can be used again.
+func (r *repository) ReleasePromoRedemption(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) error {
+	query := `
+		WITH released AS (
+			DELETE FROM promo_redemptions
+			WHERE bundle_id = $1
+			RETURNING promo_code_id
+		)
+		UPDATE promo_codes pc
+		SET redemption_count = pc.redemption_count - 1, updated_at = NOW()
+		FROM released r
+		WHERE pc.id = r.promo_code_id`
+
+	if _, err := tx.ExecContext(ctx, query, bundleID); err != nil {
+		return fmt.Errorf("release promo redemption: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// CreateRefund records refund as pending.
+func (r *repository) CreateRefund(ctx context.Context, tx *sqlx.Tx, refund *internal.Refund) error {
+	query := `
+		INSERT INTO refunds (payment_id, amount, status, attempts, created_at, updated_at)
+		VALUES ($1, $2, 'pending', 0, NOW(), NOW())
+		RETURNING id, status, attempts, created_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query, refund.PaymentID, refund.Amount).
+		Scan(&refund.ID, &refund.Status, &refund.Attempts, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("insert refund: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingRefunds returns the pending refunds last attempted more than
+// retryAfter ago.
+func (r *repository) GetPendingRefunds(ctx context.Context, retryAfter time.Duration) ([]*internal.Refund, error) {
+	var refunds []*internal.Refund
+	query := `
+		SELECT rf.id, rf.payment_id, p.provider_payment_id, rf.amount, rf.status, rf.attempts,
+			rf.created_at, rf.updated_at
+		FROM refunds rf
+		JOIN payments p ON p.id = rf.payment_id
+		WHERE rf.status = 'pending'
+			AND rf.updated_at <= NOW() - $1 * INTERVAL '1 second'
+		ORDER BY rf.created_at`
+
+	err := r.db.SelectContext(ctx, &refunds, query, retryAfter.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("select pending refunds: %w", err)
+	}
+
+	return refunds, nil
+}
+
+// UpdateRefund stores the status and attempts of refund while it is still
+// pending. It returns false when the refund was already settled.
+func (r *repository) UpdateRefund(ctx context.Context, tx *sqlx.Tx, refund *internal.Refund) (bool, error) {
+	query := `
+		UPDATE refunds
+		SET status = $2, attempts = $3, updated_at = NOW()
+		WHERE id = $1
+			AND status = 'pending'`
+
+	result, err := tx.ExecContext(ctx, query, refund.ID, refund.Status, refund.Attempts)
+	if err != nil {
+		return false, fmt.Errorf("update refund: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("update refund: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// ClaimRefund marks the oldest pending or succeeded refund of amount of
+// paymentID that no provider webhook has been matched to yet as confirmed,
+// and returns it.
+func (r *repository) ClaimRefund(ctx context.Context, tx *sqlx.Tx, paymentID uuid.UUID, amount int64) (*internal.Refund, error) {
+	refund := &internal.Refund{}
+	query := `
+		UPDATE refunds
+		SET confirmed = TRUE, updated_at = NOW()
+		WHERE id = (
+			SELECT id
+			FROM refunds
+			WHERE payment_id = $1
+				AND amount = $2
+				AND status IN ('pending', 'succeeded')
+				AND confirmed = FALSE
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE
+		)
+		RETURNING id, payment_id, amount, status, attempts, created_at, updated_at`
+
+	err := tx.GetContext(ctx, refund, query, paymentID, amount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrRefundNotFound
+		}
+		return nil, fmt.Errorf("claim refund: %w", err)
+	}
+
+	return refund, nil
+}
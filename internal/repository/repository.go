@@ -17,12 +17,81 @@ import (
 type Repository interface {
 	BeginTx(ctx context.Context) (*sqlx.Tx, error)
 	CreateProfileResponse(ctx context.Context, tx *sqlx.Tx, response *internal.ProfileResponse) error
-	GetProfiles(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.User, error)
+	DeleteLatestPass(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, window time.Duration) (*internal.ProfileResponse, error)
+	CountLikesReceived(ctx context.Context, userID uuid.UUID) (int, error)
+	GetLikesReceived(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.ReceivedLike, error)
+	GetProfiles(ctx context.Context, userID uuid.UUID, radiusKm, limit int) ([]*internal.Candidate, error)
 	CreateUser(ctx context.Context, tx *sqlx.Tx, user *internal.User) (uuid.UUID, error)
 	GetUserByEmail(ctx context.Context, email string) (*internal.User, error)
-	GetDailyInteractionCount(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*internal.User, error)
+	UpdateUserTOTPSecret(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, secret string) error
+	EnableUserTOTP(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHash string) (bool, error)
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	GetLoginAttempt(ctx context.Context, key string) (*internal.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*internal.LoginAttempt, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	CreateOAuthState(ctx context.Context, state *internal.OAuthState) error
+	ConsumeOAuthState(ctx context.Context, state, provider string) (*internal.OAuthState, error)
+	GetUserByIdentity(ctx context.Context, provider, subject string) (*internal.User, error)
+	CreateUserIdentity(ctx context.Context, tx *sqlx.Tx, identity *internal.UserIdentity) error
+	ConsumeDailyResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, day time.Time, limit int) (int, error)
+	GetDailyUsage(ctx context.Context, userID uuid.UUID, day time.Time) (int, error)
+	RefundDailyResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, day time.Time) error
+	ConsumeWeeklySuperLike(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, weekStart time.Time, limit int) (int, error)
+	GetWeeklySuperLikes(ctx context.Context, userID uuid.UUID, weekStart time.Time) (int, error)
+	ConsumeWeeklyBoost(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, weekStart time.Time, limit int) (int, error)
+	CreateNotification(ctx context.Context, tx *sqlx.Tx, notification *internal.Notification) error
+	GetNotifications(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.Notification, error)
+	CreateBoost(ctx context.Context, tx *sqlx.Tx, boost *internal.Boost) error
+	HasRunningBoost(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) (bool, error)
+	UpdateBoostStatus(ctx context.Context, tx *sqlx.Tx, boostID uuid.UUID, status string) error
+	GetBoost(ctx context.Context, boostID uuid.UUID) (*internal.Boost, error)
+	GetBoosts(ctx context.Context, userID uuid.UUID) ([]*internal.Boost, error)
+	RecordProfileViews(ctx context.Context, viewerID uuid.UUID, userIDs []uuid.UUID) error
 	GetFeatures(ctx context.Context) ([]*internal.SubscriptionFeature, error)
 	GetFeatureByID(ctx context.Context, featureID uuid.UUID) (*internal.SubscriptionFeature, error)
+	GetFeatureByName(ctx context.Context, name string) (*internal.SubscriptionFeature, error)
+	GetPlans(ctx context.Context) ([]*internal.Plan, error)
+	GetPlanPrice(ctx context.Context, featureID uuid.UUID, planID *uuid.UUID, period, currency string) (*internal.PlanPrice, error)
+	GetPlanPriceByID(ctx context.Context, planPriceID uuid.UUID) (*internal.PlanPrice, error)
+	GetPlanFeatures(ctx context.Context, planID uuid.UUID) ([]*internal.PlanFeature, error)
+	GetTrialPlan(ctx context.Context, featureID uuid.UUID, planID *uuid.UUID) (*internal.Plan, error)
+	GetPromoCodeForUpdate(ctx context.Context, tx *sqlx.Tx, code string) (*internal.PromoCode, error)
+	CreatePromoRedemption(ctx context.Context, tx *sqlx.Tx, redemption *internal.PromoRedemption) error
+	ReleasePromoRedemption(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) error
+	CreatePayment(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) error
+	UpdatePayment(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) (bool, error)
+	UpdateBundleStatus(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID, status string) error
+	CreatePaymentEvent(ctx context.Context, tx *sqlx.Tx, event *internal.PaymentEvent, payload []byte) (bool, error)
+	GetUnsettledPayments(ctx context.Context, provider string, olderThan time.Duration) ([]*internal.Payment, error)
+	GetPaymentByProviderID(ctx context.Context, tx *sqlx.Tx, provider, providerPaymentID string) (*internal.Payment, error)
+	ExtendBundle(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) (bool, error)
+	CreateRenewalPayments(ctx context.Context, tx *sqlx.Tx, provider string) ([]*internal.Payment, error)
+	ExpireSubscriptions(ctx context.Context, tx *sqlx.Tx, gracePeriod time.Duration, userID *uuid.UUID) (int64, error)
+	SetAutoRenew(ctx context.Context, userID, featureID uuid.UUID, autoRenew bool) error
+	GetLiveSubscription(ctx context.Context, tx *sqlx.Tx, userID, featureID uuid.UUID) (*internal.UserFeature, error)
+	CancelSubscription(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature, atPeriodEnd bool) error
+	ReplaceSubscription(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature) error
+	ReplaceChangedSubscription(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) error
+	RevokeGrant(ctx context.Context, tx *sqlx.Tx, userID, featureID uuid.UUID) (*internal.UserFeature, error)
+	SetUserAdmin(ctx context.Context, userID uuid.UUID, isAdmin bool) error
+	UpdateUserTimezone(ctx context.Context, userID uuid.UUID, timezone, previous string) error
+	UpdateUserIncognito(ctx context.Context, userID uuid.UUID, incognito bool) error
+	UpdateUserLocation(ctx context.Context, userID uuid.UUID, location *internal.Location) error
+	UpdateUserPassport(ctx context.Context, userID uuid.UUID, location *internal.Location) error
+	GetBundlePayment(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) (*internal.Payment, error)
+	GetPayment(ctx context.Context, tx *sqlx.Tx, paymentID uuid.UUID) (*internal.Payment, error)
+	RecordRefund(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) error
+	CreateRefund(ctx context.Context, tx *sqlx.Tx, refund *internal.Refund) error
+	GetPendingRefunds(ctx context.Context, retryAfter time.Duration) ([]*internal.Refund, error)
+	UpdateRefund(ctx context.Context, tx *sqlx.Tx, refund *internal.Refund) (bool, error)
+	ClaimRefund(ctx context.Context, tx *sqlx.Tx, paymentID uuid.UUID, amount int64) (*internal.Refund, error)
+	CreateInvoice(ctx context.Context, tx *sqlx.Tx, invoice *internal.Invoice) error
+	GetInvoices(ctx context.Context, userID uuid.UUID) ([]*internal.Invoice, error)
+	GetInvoice(ctx context.Context, userID, invoiceID uuid.UUID) (*internal.Invoice, error)
 	CreateUserFeature(ctx context.Context, tx *sqlx.Tx, feature *internal.UserFeature) error
 	GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*internal.UserFeature, error)
 	HasActiveFeature(ctx context.Context, userID uuid.UUID, featureName string) (bool, error)
@@ -63,51 +132,160 @@ func (r *repository) CreateProfileResponse(ctx context.Context, tx *sqlx.Tx, res
 	return nil
 }
 
-func (r *repository) BeginTx(ctx context.Context) (*sqlx.Tx, error) {
-	return r.db.BeginTxx(ctx, nil)
-}
-
-func (r *repository) GetDailyInteractionCount(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
-	var count int
+// DeleteLatestPass deletes the user's most recent response if it is a pass
+// made within window and returns it. It returns internal.ErrNothingToRewind
+// when there is no such response.
+func (r *repository) DeleteLatestPass(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, window time.Duration) (*internal.ProfileResponse, error) {
 	query := `
-		SELECT COUNT(*)
-		FROM profile_responses
-		WHERE from_user_id = $1
-		AND created_at >= $2`
+		DELETE FROM profile_responses
+		WHERE id = (
+			SELECT id
+			FROM profile_responses
+			WHERE from_user_id = $1
+			ORDER BY created_at DESC
+			LIMIT 1
+		)
+		AND response_type = 'pass'
+		AND created_at >= NOW() - make_interval(secs => $2)
+		RETURNING id, from_user_id, to_user_id, response_type, created_at, updated_at`
 
-	err := r.db.GetContext(ctx, &count, query, userID, since)
+	var response internal.ProfileResponse
+	err := tx.GetContext(ctx, &response, query, userID, window.Seconds())
 	if err != nil {
-		return 0, fmt.Errorf("get daily interaction count: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrNothingToRewind
+		}
+		return nil, fmt.Errorf("delete latest pass: %w", err)
 	}
 
-	return count, nil
+	return &response, nil
 }
 
-func (r *repository) GetProfiles(ctx context.Context, userID uuid.UUID, limit int) ([]*internal.User, error) {
+func (r *repository) BeginTx(ctx context.Context) (*sqlx.Tx, error) {
+	return r.db.BeginTxx(ctx, nil)
+}
+
+// boostMultiplier is how many times likelier a boosted profile is to be
+// picked as a candidate.
+const boostMultiplier = 10
+
+// distanceBucketsKm are the distances candidates are reported within,
+// rounding up the exact distance. Beyond the last bucket distances are
+// rounded up to a multiple of it.
+var distanceBucketsKm = []int64{2, 5, 10, 25, 50}
+
+// browsingLocation selects where users is browsing from, and shown to others
+// at: their passport location while they have one and the passport feature
+// ($5), or else their real location.
+const browsingLocation = `
+	SELECT p.visiting,
+		CASE WHEN p.visiting THEN users.passport_latitude ELSE users.latitude END AS latitude,
+		CASE WHEN p.visiting THEN users.passport_longitude ELSE users.longitude END AS longitude
+	FROM (
+		SELECT users.passport_latitude IS NOT NULL AND EXISTS (
+			SELECT 1
+			FROM user_features uf
+			JOIN subscription_features sf ON sf.id = uf.feature_id
+			WHERE uf.user_id = users.id
+				AND sf.name = $5
+				AND` + liveFeature + `
+		) AS visiting
+	) p`
+
+// GetProfiles picks candidates at random within radiusKm, boosted profiles
+// boostMultiplier times as likely as others. Users without a location are
+// candidates at any distance. Candidates who super liked the user come first.
+// Users in incognito mode are only candidates for users they liked, and only
+// while they have the incognito feature.
+//
+// Users with the passport feature and a passport location are located there,
+// both when browsing and when shown to others, who see them as visiting.
+func (r *repository) GetProfiles(ctx context.Context, userID uuid.UUID, radiusKm, limit int) ([]*internal.Candidate, error) {
 	query := `
-		SELECT id, email, name, bio, birth_date, gender, created_at, updated_at
+		WITH viewer AS (
+			SELECT location.latitude, location.longitude
+			FROM users
+			CROSS JOIN LATERAL (` + browsingLocation + `
+			) location
+			WHERE users.id = $1
+		)
+		SELECT users.id, users.email, users.name, users.bio, users.birth_date, users.gender,
+			users.created_at, users.updated_at,
+			candidate.visiting,
+			CASE WHEN candidate.visiting THEN users.passport_city END AS visiting_city,
+			COALESCE(
+				(SELECT MIN(bucket) FROM UNNEST($7::integer[]) AS bucket WHERE bucket >= distance.km),
+				CEIL(distance.km / $8) * $8
+			)::integer AS distance_km
 		FROM users
-		WHERE id != $1
-		AND id NOT IN (
+		CROSS JOIN viewer
+		CROSS JOIN LATERAL (` + browsingLocation + `
+		) candidate
+		CROSS JOIN LATERAL (
+			SELECT 6371 * 2 * ASIN(SQRT(
+				POWER(SIN(RADIANS(candidate.latitude - viewer.latitude) / 2), 2)
+				+ COS(RADIANS(viewer.latitude)) * COS(RADIANS(candidate.latitude))
+				* POWER(SIN(RADIANS(candidate.longitude - viewer.longitude) / 2), 2)
+			)) AS km
+		) distance
+		WHERE users.id != $1
+		AND users.id NOT IN (
 			SELECT to_user_id
 			FROM profile_responses
 			WHERE from_user_id = $1
 		)
-		ORDER BY RANDOM()
+		AND (distance.km IS NULL OR distance.km <= $4)
+		AND (
+			NOT users.incognito
+			OR NOT EXISTS (
+				SELECT 1
+				FROM user_features uf
+				JOIN subscription_features sf ON sf.id = uf.feature_id
+				WHERE uf.user_id = users.id
+					AND sf.name = $6
+					AND` + liveFeature + `
+			)
+			OR EXISTS (
+				SELECT 1
+				FROM profile_responses liked
+				WHERE liked.from_user_id = users.id
+					AND liked.to_user_id = $1
+					AND liked.response_type IN ('like', 'super_like')
+			)
+		)
+		ORDER BY EXISTS (
+			SELECT 1
+			FROM profile_responses sl
+			WHERE sl.from_user_id = users.id
+				AND sl.to_user_id = $1
+				AND sl.response_type = 'super_like'
+		) DESC,
+		POWER(RANDOM(), 1.0 / CASE WHEN EXISTS (` + runningBoost + `
+		) THEN $3::float8 ELSE 1 END) DESC
 		LIMIT $2`
 
-	var users []*internal.User
-	if err := r.db.SelectContext(ctx, &users, query, userID, limit); err != nil {
+	var candidates []*internal.Candidate
+	err := r.db.SelectContext(ctx, &candidates, query,
+		userID,
+		limit,
+		boostMultiplier,
+		radiusKm,
+		internal.FeaturePassport,
+		internal.FeatureIncognito,
+		pq.Int64Array(distanceBucketsKm),
+		distanceBucketsKm[len(distanceBucketsKm)-1],
+	)
+	if err != nil {
 		return nil, fmt.Errorf("select candidates: %w", err)
 	}
 
-	return users, nil
+	return candidates, nil
 }
 
 func (r *repository) CreateUser(ctx context.Context, tx *sqlx.Tx, user *internal.User) (uuid.UUID, error) {
 	query := `
-		INSERT INTO users (email, password_hash, name, bio, birth_date, gender, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (email, password_hash, name, bio, birth_date, gender, timezone, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, COALESCE(NULLIF($7, ''), 'UTC'), $8, $9)
 		RETURNING id`
 
 	var id uuid.UUID
@@ -119,6 +297,7 @@ func (r *repository) CreateUser(ctx context.Context, tx *sqlx.Tx, user *internal
 		user.Bio,
 		user.BirthDate,
 		user.Gender,
+		user.Timezone,
 		user.CreatedAt,
 		user.UpdatedAt,
 	).Scan(&id)
@@ -135,7 +314,8 @@ func (r *repository) CreateUser(ctx context.Context, tx *sqlx.Tx, user *internal
 func (r *repository) GetUserByEmail(ctx context.Context, email string) (*internal.User, error) {
 	user := &internal.User{}
 	query := `
-		SELECT id, email, password_hash, name, bio, birth_date, gender, created_at, updated_at
+		SELECT id, email, COALESCE(password_hash, '') AS password_hash, name, bio, birth_date, gender,
+			timezone, totp_secret, totp_enabled, is_admin, created_at, updated_at
 		FROM users
 		WHERE email = $1`
 
@@ -150,6 +330,228 @@ func (r *repository) GetUserByEmail(ctx context.Context, email string) (*interna
 	return user, nil
 }
 
+func (r *repository) GetUserByID(ctx context.Context, userID uuid.UUID) (*internal.User, error) {
+	user := &internal.User{}
+	query := `
+		SELECT id, email, COALESCE(password_hash, '') AS password_hash, name, bio, birth_date, gender,
+			timezone, previous_timezone, timezone_changed_at, totp_secret, totp_enabled, is_admin,
+			created_at, updated_at
+		FROM users
+		WHERE id = $1`
+
+	err := r.db.GetContext(ctx, user, query, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("select user: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateUserTimezone sets the user's time zone, recording previous as the
+// one daily limits reset in until now.
+func (r *repository) UpdateUserTimezone(ctx context.Context, userID uuid.UUID, timezone, previous string) error {
+	query := `
+		UPDATE users
+		SET timezone = $2, previous_timezone = $3, timezone_changed_at = NOW(), updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID, timezone, previous)
+	if err != nil {
+		return fmt.Errorf("update user timezone: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user timezone: %w", err)
+	}
+	if rows == 0 {
+		return internal.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) UpdateUserIncognito(ctx context.Context, userID uuid.UUID, incognito bool) error {
+	query := `
+		UPDATE users
+		SET incognito = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID, incognito)
+	if err != nil {
+		return fmt.Errorf("update user incognito: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user incognito: %w", err)
+	}
+	if rows == 0 {
+		return internal.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) UpdateUserLocation(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	query := `
+		UPDATE users
+		SET latitude = $2, longitude = $3, updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID, location.Latitude, location.Longitude)
+	if err != nil {
+		return fmt.Errorf("update user location: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user location: %w", err)
+	}
+	if rows == 0 {
+		return internal.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateUserPassport sets the user's passport location, leaving their real
+// location alone. A nil location clears it.
+func (r *repository) UpdateUserPassport(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	var (
+		latitude, longitude *float64
+		city                *string
+	)
+	if location != nil {
+		latitude, longitude = &location.Latitude, &location.Longitude
+		if location.City != "" {
+			city = &location.City
+		}
+	}
+
+	query := `
+		UPDATE users
+		SET passport_latitude = $2, passport_longitude = $3, passport_city = $4, updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID, latitude, longitude, city)
+	if err != nil {
+		return fmt.Errorf("update user passport: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user passport: %w", err)
+	}
+	if rows == 0 {
+		return internal.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) UpdateUserTOTPSecret(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $2, updated_at = NOW()
+		WHERE id = $1
+			AND totp_enabled = FALSE`
+
+	result, err := tx.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return fmt.Errorf("update totp secret: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update totp secret rows affected: %w", err)
+	}
+	if rows == 0 {
+		return internal.ErrTwoFactorAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (r *repository) EnableUserTOTP(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET totp_enabled = TRUE, updated_at = NOW()
+		WHERE id = $1
+			AND totp_secret IS NOT NULL`
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("enable totp: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	query := `
+		INSERT INTO user_recovery_codes (user_id, code_hash, created_at)
+		VALUES ($1, $2, NOW())`
+
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, query, userID, hash); err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) UseRecoveryCode(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1
+			AND code_hash = $2
+			AND used_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("use recovery code rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// UseTOTPStep records step as the user's latest accepted TOTP time step. It
+// reports false when a code of that step or a later one was already accepted,
+// so each code only works once.
+func (r *repository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_last_step = $2
+		WHERE id = $1
+			AND (totp_last_step IS NULL OR totp_last_step < $2)`
+
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("use totp step: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("use totp step rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
 func (r *repository) GetFeatures(ctx context.Context) ([]*internal.SubscriptionFeature, error) {
 	var features []*internal.SubscriptionFeature
 	query := `
@@ -178,23 +580,58 @@ func (r *repository) GetFeatureByID(ctx context.Context, featureID uuid.UUID) (*
 	return feature, nil
 }
 
+func (r *repository) GetFeatureByName(ctx context.Context, name string) (*internal.SubscriptionFeature, error) {
+	feature := &internal.SubscriptionFeature{}
+	query := `
+		SELECT id, name, description, created_at, updated_at
+		FROM subscription_features
+		WHERE name = $1`
+
+	if err := r.db.GetContext(ctx, feature, query, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrFeatureNotFound
+		}
+		return nil, fmt.Errorf("select feature: %w", err)
+	}
+
+	return feature, nil
+}
+
 func (r *repository) CreateUserFeature(ctx context.Context, tx *sqlx.Tx, feature *internal.UserFeature) error {
 	query := `
 		INSERT INTO user_features (
-			user_id, feature_id, value, start_date, end_date,
-			status, created_at, updated_at
+			user_id, feature_id, value, start_date, end_date, status, auto_renew,
+			plan_id, plan_price_id, bundle_id, replaces_id, price_amount, currency, source,
+			grant_reason, granted_by, created_at, updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())`
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
 
-	_, err := tx.ExecContext(ctx, query,
+	err := tx.QueryRowContext(ctx, query,
 		feature.UserID,
 		feature.FeatureID,
 		feature.Value,
 		feature.StartDate,
 		feature.EndDate,
 		feature.Status,
-	)
+		feature.AutoRenew,
+		feature.PlanID,
+		feature.PlanPriceID,
+		feature.BundleID,
+		feature.ReplacesID,
+		feature.PriceAmount,
+		feature.Currency,
+		feature.Source,
+		feature.GrantReason,
+		feature.GrantedBy,
+	).Scan(&feature.ID, &feature.CreatedAt, &feature.UpdatedAt)
 	if err != nil {
+		if isPgUniqueViolationOf(err, "idx_user_features_one_trial") {
+			return internal.ErrTrialAlreadyUsed
+		}
+		if isPgUniqueViolationOf(err, "idx_user_features_pending_change") {
+			return internal.ErrPlanChangeNotAllowed
+		}
 		if isPgUniqueViolation(err) {
 			return internal.ErrFeatureAlreadySubscribed
 		}
@@ -204,9 +641,17 @@ func (r *repository) CreateUserFeature(ctx context.Context, tx *sqlx.Tx, feature
 	return nil
 }
 
-func (r *repository) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*internal.UserFeature, error) {
-	currentTime := time.Now()
+// liveFeature matches the user_features rows uf that grant their feature now:
+// started and active, or cancelled at period end, until their end date, or
+// past due until their grace period ends.
+const liveFeature = `
+	uf.start_date <= NOW()
+	AND (
+		(uf.status IN ('active', 'pending_cancellation') AND (uf.end_date IS NULL OR uf.end_date > NOW()))
+		OR (uf.status = 'past_due' AND uf.grace_until > NOW())
+	)`
 
+func (r *repository) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*internal.UserFeature, error) {
 	query := `
 		SELECT
 			uf.id,
@@ -216,6 +661,17 @@ func (r *repository) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*
 			uf.start_date,
 			uf.end_date,
 			uf.status,
+			uf.auto_renew,
+			uf.grace_until,
+			uf.cancelled_at,
+			uf.source,
+			uf.grant_reason,
+			uf.granted_by,
+			uf.plan_id,
+			uf.plan_price_id,
+			uf.bundle_id,
+			uf.price_amount,
+			uf.currency,
 			uf.created_at,
 			uf.updated_at,
 			sf.name as feature_name,
@@ -223,13 +679,11 @@ func (r *repository) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*
 		FROM user_features uf
 		JOIN subscription_features sf ON sf.id = uf.feature_id
 		WHERE uf.user_id = $1
-			AND uf.status = 'active'
-			AND uf.start_date <= $2
-			AND (uf.end_date IS NULL OR uf.end_date > $2)
+			AND` + liveFeature + `
 		ORDER BY uf.created_at DESC`
 
 	var features []*internal.UserFeature
-	if err := r.db.SelectContext(ctx, &features, query, userID, currentTime); err != nil {
+	if err := r.db.SelectContext(ctx, &features, query, userID); err != nil {
 		return nil, fmt.Errorf("select user features: %w", err)
 	}
 
@@ -244,9 +698,7 @@ func (r *repository) HasActiveFeature(ctx context.Context, userID uuid.UUID, fea
 			JOIN subscription_features sf ON sf.id = uf.feature_id
 			WHERE uf.user_id = $1
 				AND sf.name = $2
-				AND uf.start_date <= NOW()
-				AND (uf.end_date IS NULL OR uf.end_date > NOW())
-				AND uf.status = 'active'
+				AND` + liveFeature + `
 		)`
 
 	var exists bool
@@ -255,11 +707,157 @@ func (r *repository) HasActiveFeature(ctx context.Context, userID uuid.UUID, fea
 		return false, fmt.Errorf("check active feature: %w", err)
 	}
 
-	fmt.Println("exists", exists)
-
 	return exists, nil
 }
 
+func (r *repository) GetLoginAttempt(ctx context.Context, key string) (*internal.LoginAttempt, error) {
+	attempt := &internal.LoginAttempt{}
+	query := `
+		SELECT key, failed_count, last_failed_at, locked_until, created_at, updated_at
+		FROM login_attempts
+		WHERE key = $1`
+
+	err := r.db.GetContext(ctx, attempt, query, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &internal.LoginAttempt{Key: key}, nil
+		}
+		return nil, fmt.Errorf("select login attempt: %w", err)
+	}
+
+	return attempt, nil
+}
+
+// RecordLoginFailure increments the failure counter for key. Failures older
+// than window are forgotten, so the counter restarts at one.
+func (r *repository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*internal.LoginAttempt, error) {
+	query := `
+		INSERT INTO login_attempts (key, failed_count, last_failed_at, created_at, updated_at)
+		VALUES ($1, 1, NOW(), NOW(), NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failed_count = CASE
+				WHEN login_attempts.last_failed_at < NOW() - $2 * INTERVAL '1 second' THEN 1
+				ELSE login_attempts.failed_count + 1
+			END,
+			last_failed_at = NOW(),
+			updated_at = NOW()
+		RETURNING key, failed_count, last_failed_at, locked_until, created_at, updated_at`
+
+	attempt := &internal.LoginAttempt{}
+	if err := r.db.GetContext(ctx, attempt, query, key, window.Seconds()); err != nil {
+		return nil, fmt.Errorf("record login failure: %w", err)
+	}
+
+	return attempt, nil
+}
+
+func (r *repository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $2, updated_at = NOW()
+		WHERE key = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, key, until); err != nil {
+		return fmt.Errorf("lock login: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) ResetLoginAttempts(ctx context.Context, key string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("reset login attempts: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) CreateOAuthState(ctx context.Context, state *internal.OAuthState) error {
+	query := `
+		INSERT INTO oauth_states (state, provider, code_verifier, nonce, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())`
+
+	_, err := r.db.ExecContext(ctx, query,
+		state.State,
+		state.Provider,
+		state.CodeVerifier,
+		state.Nonce,
+		state.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert oauth state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeOAuthState deletes and returns a pending state so that each
+// authorization response can only be redeemed once.
+func (r *repository) ConsumeOAuthState(ctx context.Context, state, provider string) (*internal.OAuthState, error) {
+	oauthState := &internal.OAuthState{}
+	query := `
+		DELETE FROM oauth_states
+		WHERE state = $1
+			AND provider = $2
+		RETURNING state, provider, code_verifier, nonce, expires_at, created_at`
+
+	err := r.db.GetContext(ctx, oauthState, query, state, provider)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrInvalidOAuthState
+		}
+		return nil, fmt.Errorf("consume oauth state: %w", err)
+	}
+
+	if oauthState.ExpiresAt.Before(time.Now()) {
+		return nil, internal.ErrInvalidOAuthState
+	}
+
+	return oauthState, nil
+}
+
+func (r *repository) GetUserByIdentity(ctx context.Context, provider, subject string) (*internal.User, error) {
+	user := &internal.User{}
+	query := `
+		SELECT u.id, u.email, COALESCE(u.password_hash, '') AS password_hash, u.name, u.bio, u.birth_date, u.gender,
+			u.timezone, u.totp_secret, u.totp_enabled, u.created_at, u.updated_at
+		FROM users u
+		JOIN user_identities ui ON ui.user_id = u.id
+		WHERE ui.provider = $1
+			AND ui.subject = $2`
+
+	err := r.db.GetContext(ctx, user, query, provider, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("select user by identity: %w", err)
+	}
+
+	return user, nil
+}
+
+func (r *repository) CreateUserIdentity(ctx context.Context, tx *sqlx.Tx, identity *internal.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())`
+
+	_, err := tx.ExecContext(ctx, query,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	)
+	if err != nil {
+		if isPgUniqueViolation(err) {
+			return internal.ErrOAuthIdentityExists
+		}
+		return fmt.Errorf("insert user identity: %w", err)
+	}
+
+	return nil
+}
+
 func isPgUniqueViolation(err error) bool {
 	var pqErr *pq.Error
 	if errors.As(err, &pqErr) {
@@ -267,3 +865,13 @@ func isPgUniqueViolation(err error) bool {
 	}
 	return false
 }
+
+// isPgUniqueViolationOf reports whether err violates the named unique
+// constraint or index.
+func isPgUniqueViolationOf(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" && pqErr.Constraint == constraint
+	}
+	return false
+}
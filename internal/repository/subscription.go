@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ExtendBundle renews the live features of bundleID for another billing
+// period of their plan price, counted from the current end date or from now
+// if that has passed. It reports whether any feature was renewed.
+func (r *repository) ExtendBundle(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE user_features uf
+		SET end_date = GREATEST(COALESCE(uf.end_date, NOW()), NOW()) + make_interval(months => pp.period_months),
+			status = 'active',
+			grace_until = NULL,
+			updated_at = NOW()
+		FROM plan_prices pp
+		WHERE uf.bundle_id = $1
+			AND uf.status IN ('active', 'past_due')
+			AND pp.id = uf.plan_price_id`
+
+	result, err := tx.ExecContext(ctx, query, bundleID)
+	if err != nil {
+		return false, fmt.Errorf("extend bundle: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("extend bundle: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// CreateRenewalPayments creates a pending renewal payment with provider for
+// every auto-renewing subscription that has reached its end date, charging
+// the plan price of another period to the payment method of the user's latest
+// successful payment. A subscription gets one renewal attempt per period, so
+// one whose renewal is declined lapses through its grace period. The attempt
+// is unique per subscription and period end, so concurrent sweeps create it
+// once.
+func (r *repository) CreateRenewalPayments(ctx context.Context, tx *sqlx.Tx, provider string) ([]*internal.Payment, error) {
+	var payments []*internal.Payment
+	query := `
+		INSERT INTO payments (user_id, bundle_id, provider, amount, currency, status, description,
+			payment_method, renewal, period_end, created_at, updated_at)
+		SELECT DISTINCT ON (uf.bundle_id)
+			uf.user_id, uf.bundle_id, $1, pp.amount, pp.currency, 'pending',
+			sf.name || ' subscription, ' || pp.period, method.payment_method, TRUE, uf.end_date,
+			NOW(), NOW()
+		FROM user_features uf
+		JOIN plan_prices pp ON pp.id = uf.plan_price_id
+		JOIN subscription_features sf ON sf.id = uf.feature_id
+		CROSS JOIN LATERAL (
+			SELECT p.payment_method
+			FROM payments p
+			WHERE p.user_id = uf.user_id
+				AND p.status = 'succeeded'
+				AND p.payment_method IS NOT NULL
+			ORDER BY p.created_at DESC
+			LIMIT 1
+		) method
+		WHERE uf.auto_renew = TRUE
+			AND uf.source = 'purchase'
+			AND uf.bundle_id IS NOT NULL
+			AND uf.end_date <= NOW()
+			AND (uf.status = 'active' OR (uf.status = 'past_due' AND uf.grace_until > NOW()))
+			AND NOT EXISTS (
+				SELECT 1
+				FROM payments attempt
+				WHERE attempt.bundle_id = uf.bundle_id
+					AND attempt.renewal = TRUE
+					AND attempt.created_at >= uf.end_date
+			)
+		ORDER BY uf.bundle_id, uf.price_amount DESC
+		ON CONFLICT (bundle_id, period_end) WHERE renewal = TRUE DO NOTHING
+		RETURNING id, user_id, bundle_id, provider, provider_payment_id, amount, currency, status,
+			description, refunded_amount, payment_method, renewal, boost, created_at, updated_at`
+
+	err := tx.SelectContext(ctx, &payments, query, provider)
+	if err != nil {
+		return nil, fmt.Errorf("create renewal payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// ExpireSubscriptions moves lapsed subscriptions along their lifecycle:
+// auto-renewing ones past their end date become past due for gracePeriod, the
+// ones cancelled at period end become cancelled, and the rest, along with
+// past due ones whose grace period is over, expire. Each ended subscription
+// is written to the billing history. When userID is set only that user's
+// subscriptions are considered. It returns the number of subscriptions ended.
+func (r *repository) ExpireSubscriptions(ctx context.Context, tx *sqlx.Tx, gracePeriod time.Duration, userID *uuid.UUID) (int64, error) {
+	pastDueQuery := `
+		UPDATE user_features
+		SET status = 'past_due',
+			grace_until = end_date + $1 * INTERVAL '1 second',
+			updated_at = NOW()
+		WHERE status = 'active'
+			AND auto_renew = TRUE
+			AND end_date <= NOW()
+			AND ($2::uuid IS NULL OR user_id = $2)`
+
+	if _, err := tx.ExecContext(ctx, pastDueQuery, gracePeriod.Seconds(), userID); err != nil {
+		return 0, fmt.Errorf("mark subscriptions past due: %w", err)
+	}
+
+	expireQuery := `
+		WITH ended AS (
+			UPDATE user_features
+			SET status = CASE WHEN status = 'pending_cancellation' THEN 'cancelled' ELSE 'expired' END,
+				updated_at = NOW()
+			WHERE (
+					(status IN ('active', 'pending_cancellation') AND end_date <= NOW())
+					OR (status = 'past_due' AND grace_until <= NOW())
+				)
+				AND ($1::uuid IS NULL OR user_id = $1)
+			RETURNING user_id, feature_id, bundle_id, status
+		)
+		INSERT INTO invoices (user_id, bundle_id, kind, description, created_at)
+		SELECT e.user_id, e.bundle_id, 'change', sf.name || ' subscription ' || e.status, NOW()
+		FROM ended e
+		JOIN subscription_features sf ON sf.id = e.feature_id`
+
+	result, err := tx.ExecContext(ctx, expireQuery, userID)
+	if err != nil {
+		return 0, fmt.Errorf("expire subscriptions: %w", err)
+	}
+
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("expire subscriptions: %w", err)
+	}
+
+	return expired, nil
+}
+
+// SetAutoRenew changes the auto-renew flag of the user's live subscription to
+// featureID and of every feature bought with it.
+func (r *repository) SetAutoRenew(ctx context.Context, userID, featureID uuid.UUID, autoRenew bool) error {
+	query := `
+		WITH target AS (
+			SELECT id, bundle_id
+			FROM user_features
+			WHERE user_id = $1
+				AND feature_id = $2
+				AND status IN ('pending', 'active', 'past_due')
+		)
+		UPDATE user_features uf
+		SET auto_renew = $3, updated_at = NOW()
+		FROM target t
+		WHERE uf.user_id = $1
+			AND (uf.id = t.id OR uf.bundle_id = t.bundle_id)`
+
+	result, err := r.db.ExecContext(ctx, query, userID, featureID, autoRenew)
+	if err != nil {
+		return fmt.Errorf("set auto renew: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set auto renew: %w", err)
+	}
+	if rows == 0 {
+		return internal.ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// GetLiveSubscription locks and returns the user's live subscription to
+// featureID.
+func (r *repository) GetLiveSubscription(ctx context.Context, tx *sqlx.Tx, userID, featureID uuid.UUID) (*internal.UserFeature, error) {
+	feature := &internal.UserFeature{}
+	query := `
+		SELECT
+			uf.id,
+			uf.user_id,
+			uf.feature_id,
+			uf.value,
+			uf.start_date,
+			uf.end_date,
+			uf.status,
+			uf.auto_renew,
+			uf.grace_until,
+			uf.cancelled_at,
+			uf.source,
+			uf.grant_reason,
+			uf.granted_by,
+			uf.plan_id,
+			uf.plan_price_id,
+			uf.bundle_id,
+			uf.price_amount,
+			uf.currency,
+			uf.created_at,
+			uf.updated_at,
+			sf.name as feature_name,
+			sf.description as feature_description
+		FROM user_features uf
+		JOIN subscription_features sf ON sf.id = uf.feature_id
+		WHERE uf.user_id = $1
+			AND uf.feature_id = $2
+			AND uf.status IN ('pending', 'active', 'past_due', 'pending_cancellation')
+		FOR UPDATE OF uf`
+
+	err := tx.GetContext(ctx, feature, query, userID, featureID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("select live subscription: %w", err)
+	}
+
+	return feature, nil
+}
+
+// CancelSubscription cancels subscription and the features bought with it,
+// either now or at the end of the current period. Either way they no longer
+// renew. The status, end date and cancellation time are written back to
+// subscription.
+func (r *repository) CancelSubscription(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature, atPeriodEnd bool) error {
+	query := `
+		UPDATE user_features
+		SET status = CASE WHEN $4 THEN 'pending_cancellation' ELSE 'cancelled' END,
+			end_date = CASE WHEN $4 THEN end_date ELSE NOW() END,
+			auto_renew = FALSE,
+			grace_until = NULL,
+			cancelled_at = NOW(),
+			updated_at = NOW()
+		WHERE user_id = $1
+			AND (id = $2 OR bundle_id = $3)
+			AND status IN ('pending', 'active', 'past_due', 'pending_cancellation')
+		RETURNING id, status, end_date, cancelled_at, updated_at`
+
+	rows, err := tx.QueryContext(ctx, query, subscription.UserID, subscription.ID, subscription.BundleID, atPeriodEnd)
+	if err != nil {
+		return fmt.Errorf("cancel subscription: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id          uuid.UUID
+			status      string
+			endDate     *time.Time
+			cancelledAt *time.Time
+			updatedAt   time.Time
+		)
+		if err := rows.Scan(&id, &status, &endDate, &cancelledAt, &updatedAt); err != nil {
+			return fmt.Errorf("scan cancelled subscription: %w", err)
+		}
+		if id == subscription.ID {
+			subscription.Status = status
+			subscription.EndDate = endDate
+			subscription.CancelledAt = cancelledAt
+			subscription.UpdatedAt = updatedAt
+			subscription.AutoRenew = false
+			subscription.GraceUntil = nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cancel subscription: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceSubscription ends the subscription, and every feature bought with
+// it, now because it has been changed to another plan.
+func (r *repository) ReplaceSubscription(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature) error {
+	query := `
+		UPDATE user_features
+		SET status = 'replaced',
+			end_date = NOW(),
+			auto_renew = FALSE,
+			grace_until = NULL,
+			updated_at = NOW()
+		WHERE user_id = $1
+			AND (id = $2 OR bundle_id = $3)
+			AND status IN ('pending', 'active', 'past_due', 'pending_cancellation')`
+
+	_, err := tx.ExecContext(ctx, query, subscription.UserID, subscription.ID, subscription.BundleID)
+	if err != nil {
+		return fmt.Errorf("replace subscription: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceChangedSubscription ends the subscription, and every feature bought
+// with it, that the pending plan change bought as bundleID replaces. It does
+// nothing when bundleID is not a pending plan change.
+func (r *repository) ReplaceChangedSubscription(ctx context.Context, tx *sqlx.Tx, bundleID uuid.UUID) error {
+	query := `
+		UPDATE user_features uf
+		SET status = 'replaced',
+			end_date = NOW(),
+			auto_renew = FALSE,
+			grace_until = NULL,
+			updated_at = NOW()
+		FROM user_features changed
+		JOIN user_features old ON old.id = changed.replaces_id
+		WHERE changed.bundle_id = $1
+			AND changed.status = 'pending_change'
+			AND uf.user_id = old.user_id
+			AND (uf.id = old.id OR uf.bundle_id = old.bundle_id)
+			AND uf.status IN ('pending', 'active', 'past_due', 'pending_cancellation')`
+
+	_, err := tx.ExecContext(ctx, query, bundleID)
+	if err != nil {
+		return fmt.Errorf("replace changed subscription: %w", err)
+	}
+
+	return nil
+}
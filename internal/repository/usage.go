@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ConsumeDailyResponse counts one response against the user's limit for day
+// and returns the day's new count. The check and the increment are a single
+// statement, so concurrent responses cannot exceed limit; the row stays
+// locked until tx ends. A limit of internal.Unlimited is never exceeded.
+func (r *repository) ConsumeDailyResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, day time.Time, limit int) (int, error) {
+	query := `
+		INSERT INTO daily_usage (user_id, usage_date, response_count, created_at, updated_at)
+		SELECT $1, $2::date, 1, NOW(), NOW()
+		WHERE $3 < 0 OR $3 > 0
+		ON CONFLICT (user_id, usage_date) DO UPDATE
+		SET response_count = daily_usage.response_count + 1,
+			updated_at = NOW()
+		WHERE $3 < 0 OR daily_usage.response_count < $3
+		RETURNING response_count`
+
+	var count int
+	err := tx.QueryRowContext(ctx, query, userID, day.Format(time.DateOnly), limit).Scan(&count)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, internal.ErrDailyInteractionLimitExceeded
+		}
+		return 0, fmt.Errorf("consume daily response: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *repository) GetDailyUsage(ctx context.Context, userID uuid.UUID, day time.Time) (int, error) {
+	var count int
+	query := `
+		SELECT COALESCE(SUM(response_count), 0)
+		FROM daily_usage
+		WHERE user_id = $1
+			AND usage_date = $2::date`
+
+	err := r.db.GetContext(ctx, &count, query, userID, day.Format(time.DateOnly))
+	if err != nil {
+		return 0, fmt.Errorf("get daily usage: %w", err)
+	}
+
+	return count, nil
+}
+
+// RefundDailyResponse takes one response back off the user's count for day.
+func (r *repository) RefundDailyResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, day time.Time) error {
+	query := `
+		UPDATE daily_usage
+		SET response_count = response_count - 1,
+			updated_at = NOW()
+		WHERE user_id = $1
+			AND usage_date = $2::date
+			AND response_count > 0`
+
+	if _, err := tx.ExecContext(ctx, query, userID, day.Format(time.DateOnly)); err != nil {
+		return fmt.Errorf("refund daily response: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeWeeklySuperLike counts one super like against the user's limit for
+// the week starting on weekStart, like ConsumeDailyResponse.
+func (r *repository) ConsumeWeeklySuperLike(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, weekStart time.Time, limit int) (int, error) {
+	query := `
+		INSERT INTO weekly_usage (user_id, week_start, super_like_count, created_at, updated_at)
+		SELECT $1, $2::date, 1, NOW(), NOW()
+		WHERE $3 < 0 OR $3 > 0
+		ON CONFLICT (user_id, week_start) DO UPDATE
+		SET super_like_count = weekly_usage.super_like_count + 1,
+			updated_at = NOW()
+		WHERE $3 < 0 OR weekly_usage.super_like_count < $3
+		RETURNING super_like_count`
+
+	var count int
+	err := tx.QueryRowContext(ctx, query, userID, weekStart.Format(time.DateOnly), limit).Scan(&count)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, internal.ErrSuperLikeLimitExceeded
+		}
+		return 0, fmt.Errorf("consume weekly super like: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *repository) GetWeeklySuperLikes(ctx context.Context, userID uuid.UUID, weekStart time.Time) (int, error) {
+	var count int
+	query := `
+		SELECT COALESCE(SUM(super_like_count), 0)
+		FROM weekly_usage
+		WHERE user_id = $1
+			AND week_start = $2::date`
+
+	err := r.db.GetContext(ctx, &count, query, userID, weekStart.Format(time.DateOnly))
+	if err != nil {
+		return 0, fmt.Errorf("get weekly super likes: %w", err)
+	}
+
+	return count, nil
+}
+
+// ConsumeWeeklyBoost counts one redeemed boost against the user's limit for
+// the week starting on weekStart, like ConsumeDailyResponse.
+func (r *repository) ConsumeWeeklyBoost(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, weekStart time.Time, limit int) (int, error) {
+	query := `
+		INSERT INTO weekly_usage (user_id, week_start, boost_count, created_at, updated_at)
+		SELECT $1, $2::date, 1, NOW(), NOW()
+		WHERE $3 < 0 OR $3 > 0
+		ON CONFLICT (user_id, week_start) DO UPDATE
+		SET boost_count = weekly_usage.boost_count + 1,
+			updated_at = NOW()
+		WHERE $3 < 0 OR weekly_usage.boost_count < $3
+		RETURNING boost_count`
+
+	var count int
+	err := tx.QueryRowContext(ctx, query, userID, weekStart.Format(time.DateOnly), limit).Scan(&count)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, internal.ErrNoBoostsLeft
+		}
+		return 0, fmt.Errorf("consume weekly boost: %w", err)
+	}
+
+	return count, nil
+}
@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"datingapp/internal"
+	"datingapp/internal/auth"
 	"datingapp/internal/config"
 	"datingapp/internal/handler"
 	datingappMiddleware "datingapp/internal/middleware"
+	"datingapp/internal/oidc"
+	"datingapp/internal/payment"
 	"datingapp/internal/repository"
 	"datingapp/internal/service"
 
@@ -20,10 +25,23 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// signingKeyReloadInterval is how often the JWT key directory is re-read to
+// pick up rotated keys.
+const signingKeyReloadInterval = time.Minute
+
+// subscriptionSweepInterval is how often due subscriptions are renewed, lapsed
+// ones moved to past due or expired and pending refunds retried.
+const subscriptionSweepInterval = time.Minute
+
 type Server struct {
-	db     *sqlx.DB
-	echo   *echo.Echo
-	config config.Config
+	db       *sqlx.DB
+	echo     *echo.Echo
+	config   config.Config
+	keys     *auth.KeySet
+	payments internal.PaymentProvider
+	// ctx is cancelled by Shutdown to stop background jobs.
+	ctx  context.Context
+	stop context.CancelFunc
 }
 
 type CustomValidator struct {
@@ -45,10 +63,39 @@ func NewServer(db *sqlx.DB, config config.Config) *Server {
 
 	e.Validator = &CustomValidator{validator: v}
 
+	keys := auth.NewHMACKeySet(config.JWTSecret)
+	if config.JWTKeyDir != "" {
+		keys, err = auth.LoadKeySet(config.JWTKeyDir)
+		if err != nil {
+			log.Fatalf("failed to load JWT signing keys: %v", err)
+		}
+	}
+
+	payments, err := newPaymentProvider(config.PaymentProvider)
+	if err != nil {
+		log.Fatalf("failed to create payment provider: %v", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	go keys.Watch(ctx, signingKeyReloadInterval)
+
 	return &Server{
-		config: config,
-		db:     db,
-		echo:   e,
+		config:   config,
+		db:       db,
+		echo:     e,
+		keys:     keys,
+		payments: payments,
+		ctx:      ctx,
+		stop:     stop,
+	}
+}
+
+func newPaymentProvider(name string) (internal.PaymentProvider, error) {
+	switch name {
+	case "fake":
+		return payment.NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
 	}
 }
 
@@ -59,11 +106,14 @@ func (s *Server) Start(port string) error {
 
 func (s *Server) setupRoutes() {
 	repo := repository.NewRepository(s.db)
-	userSvc := service.NewUserService(repo, s.config.JWTSecret)
-	featureSvc := service.NewFeatureService(repo)
-	profileSvc := service.NewProfileService(repo, s.config.JWTSecret)
+	tokens := auth.NewTokens(s.keys, s.config.JWTIssuer, s.config.JWTAudience)
+	userSvc := service.NewUserService(repo, tokens, s.config.TOTPIssuer, s.oauthProviders())
+	featureSvc := service.NewFeatureService(repo, s.payments, s.config.DefaultCurrency, s.config.PaymentWebhookSecret, s.config.SubscriptionGracePeriod, s.config.BoostPrice)
+	profileSvc := service.NewProfileService(repo, s.config.JWTSecret, s.config.FreeDailyResponses, s.config.FreeWeeklySuperLikes, s.config.RewindWindow, s.config.DiscoveryRadiusKm)
 	h := handler.NewHandler(userSvc, featureSvc, profileSvc)
 
+	go sweepSubscriptions(s.ctx, featureSvc, subscriptionSweepInterval)
+
 	s.echo.Use(middleware.Logger())
 	s.echo.Use(middleware.Recover())
 	s.echo.Use(middleware.CORS())
@@ -78,24 +128,106 @@ func (s *Server) setupRoutes() {
 		}
 	}()
 
+	s.echo.GET("/.well-known/jwks.json", handler.JWKS(s.keys))
+
 	v1 := s.echo.Group("/api/v1")
 
 	v1.POST("/signup", h.SignUp)
 	v1.POST("/login", h.Login)
+	v1.POST("/login/2fa", h.VerifyTwoFactor)
+	v1.GET("/oauth/:provider/authorize", h.StartOAuthLogin)
+	v1.POST("/oauth/:provider/callback", h.CompleteOAuthLogin)
+	v1.GET("/plans", h.GetPlans)
+	v1.POST("/webhooks/payments", h.PaymentWebhook)
 
 	protected := v1.Group("")
-	protected.Use(datingappMiddleware.JWTMiddleware(s.config.JWTSecret))
+	protected.Use(datingappMiddleware.JWTMiddleware(tokens))
 	protected.Use(datingappMiddleware.ActiveFeatures(repo))
 
 	protected.GET("/profiles", h.GetProfiles)
 	protected.POST("/profiles/:id/response", h.CreateProfileResponse)
+	protected.GET("/likes/received", h.GetLikesReceived)
+	protected.POST("/profiles/rewind", h.RewindProfileResponse, datingappMiddleware.RequireFeature(repo, internal.FeatureRewind))
+
+	twoFactor := protected.Group("/2fa")
+	twoFactor.POST("/enroll", h.EnrollTOTP)
+	twoFactor.POST("/confirm", h.ConfirmTOTP)
+
+	me := protected.Group("/me")
+	me.PUT("/timezone", h.SetTimezone)
+	me.PUT("/incognito", h.SetIncognito)
+	me.PUT("/location", h.SetLocation)
+	me.PUT("/passport", h.SetPassport)
+	me.DELETE("/passport", h.ClearPassport)
+	me.GET("/quota", h.GetQuota)
+	me.GET("/notifications", h.GetNotifications)
+	me.GET("/billing", h.GetBillingHistory)
+	me.GET("/billing/:id/receipt", h.GetReceipt)
 
 	features := protected.Group("/features")
 	features.GET("", h.GetFeatures)
 	features.GET("/my", h.GetUserFeatures)
 	features.POST("/:id/subscribe", h.SubscribeToFeature)
+	features.PATCH("/:id/auto-renew", h.SetAutoRenew)
+	features.POST("/:id/cancel", h.CancelSubscription)
+	features.POST("/:id/change/preview", h.PreviewPlanChange)
+	features.POST("/:id/change", h.ChangePlan)
+
+	boosts := protected.Group("/boosts")
+	boosts.GET("", h.GetBoosts)
+	boosts.POST("", h.ActivateBoost)
+
+	admin := protected.Group("/admin")
+	admin.Use(datingappMiddleware.RequireAdmin(repo))
+	admin.POST("/users/:id/grants", h.GrantFeature)
+	admin.DELETE("/users/:id/grants/:feature_id", h.RevokeGrant)
+}
+
+// sweepSubscriptions renews due subscriptions, expires lapsed ones, retries
+// pending refunds and settles stale payments every interval until ctx is
+// cancelled.
+func sweepSubscriptions(ctx context.Context, featureSvc internal.FeatureService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Renew due subscriptions first so that a successful charge
+			// keeps them from lapsing.
+			if err := featureSvc.RenewSubscriptions(ctx); err != nil {
+				log.Printf("failed to renew subscriptions: %v", err)
+			}
+			if err := featureSvc.ExpireSubscriptions(ctx); err != nil {
+				log.Printf("failed to expire subscriptions: %v", err)
+			}
+			if err := featureSvc.RetryRefunds(ctx); err != nil {
+				log.Printf("failed to retry refunds: %v", err)
+			}
+			if err := featureSvc.ReconcilePayments(ctx); err != nil {
+				log.Printf("failed to reconcile payments: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Server) oauthProviders() []*oidc.Provider {
+	providers := make([]*oidc.Provider, 0, len(s.config.OIDC))
+	for _, p := range s.config.OIDC {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			IssuerURL:    p.IssuerURL,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+		}, nil))
+	}
+	return providers
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.stop()
 	return s.echo.Shutdown(ctx)
 }
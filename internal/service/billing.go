@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func (s *featureService) GetBillingHistory(ctx context.Context, userID uuid.UUID) ([]*internal.Invoice, error) {
+	return s.repo.GetInvoices(ctx, userID)
+}
+
+func (s *featureService) GetReceipt(ctx context.Context, userID, invoiceID uuid.UUID) (*internal.Receipt, error) {
+	invoice, err := s.repo.GetInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("get invoice: %w", err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	return &internal.Receipt{
+		Invoice: invoice,
+		Name:    user.Name,
+		Email:   user.Email,
+	}, nil
+}
+
+// recordPaymentInvoice adds a billing history entry for money moved by
+// payment.
+func (s *featureService) recordPaymentInvoice(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment, kind string, amount int64, description string) error {
+	err := s.repo.CreateInvoice(ctx, tx, &internal.Invoice{
+		UserID:      payment.UserID,
+		BundleID:    &payment.BundleID,
+		PaymentID:   &payment.ID,
+		Kind:        kind,
+		Description: description,
+		Amount:      amount,
+		Currency:    payment.Currency,
+	})
+	if err != nil {
+		return fmt.Errorf("create invoice: %w", err)
+	}
+
+	return nil
+}
+
+// recordChangeInvoice adds a billing history entry for a subscription change
+// that moved no money.
+func (s *featureService) recordChangeInvoice(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature, description string) error {
+	err := s.repo.CreateInvoice(ctx, tx, &internal.Invoice{
+		UserID:      subscription.UserID,
+		BundleID:    subscription.BundleID,
+		Kind:        internal.InvoiceKindChange,
+		Description: description,
+		Currency:    subscription.Currency,
+	})
+	if err != nil {
+		return fmt.Errorf("create invoice: %w", err)
+	}
+
+	return nil
+}
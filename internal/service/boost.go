@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// boostDuration is how long a boost runs.
+const boostDuration = 30 * time.Minute
+
+// ActivateBoost starts a boost of the user's profile, either redeemed from
+// their weekly allowance or bought. A bought boost starts once its payment
+// succeeds. Only one boost can run or await its payment at a time.
+func (s *featureService) ActivateBoost(ctx context.Context, req *internal.BoostRequest) (*internal.Boost, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	boost, payment, err := s.activateBoost(ctx, tx, req)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	if payment == nil {
+		return boost, nil
+	}
+
+	status, err := s.charge(ctx, payment, req.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	if status == internal.FeatureStatusPaymentFailed {
+		return nil, internal.ErrPaymentDeclined
+	}
+
+	return s.repo.GetBoost(ctx, boost.ID)
+}
+
+// activateBoost creates the boost within tx. It returns the payment still to
+// be charged for a bought boost.
+func (s *featureService) activateBoost(ctx context.Context, tx *sqlx.Tx, req *internal.BoostRequest) (*internal.Boost, *internal.Payment, error) {
+	running, err := s.repo.HasRunningBoost(ctx, tx, req.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("check running boost: %w", err)
+	}
+	if running {
+		return nil, nil, internal.ErrBoostActive
+	}
+
+	boost := &internal.Boost{
+		ID:              uuid.New(),
+		UserID:          req.UserID,
+		Source:          req.Source,
+		Status:          internal.FeatureStatusPending,
+		DurationSeconds: int(boostDuration.Seconds()),
+	}
+
+	if req.Source == internal.BoostSourceRedeem {
+		day, err := localDay(ctx, s.repo, req.UserID, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		limit := internal.FeatureQuota(ctx, internal.FeatureBoosts, 0)
+		if _, err := s.repo.ConsumeWeeklyBoost(ctx, tx, req.UserID, weekStart(day), limit); err != nil {
+			return nil, nil, fmt.Errorf("consume weekly boost: %w", err)
+		}
+
+		boost.Status = internal.FeatureStatusActive
+		if err := s.repo.CreateBoost(ctx, tx, boost); err != nil {
+			return nil, nil, fmt.Errorf("create boost: %w", err)
+		}
+		return boost, nil, nil
+	}
+
+	if err := s.repo.CreateBoost(ctx, tx, boost); err != nil {
+		return nil, nil, fmt.Errorf("create boost: %w", err)
+	}
+
+	payment := &internal.Payment{
+		UserID:        req.UserID,
+		BundleID:      boost.ID,
+		Provider:      s.payments.Name(),
+		Amount:        s.boostPrice,
+		Currency:      s.defaultCurrency,
+		Status:        internal.PaymentStatusPending,
+		Description:   fmt.Sprintf("Profile boost, %d minutes", int(boostDuration.Minutes())),
+		PaymentMethod: &req.PaymentMethod,
+		Boost:         true,
+	}
+	if err := s.repo.CreatePayment(ctx, tx, payment); err != nil {
+		return nil, nil, fmt.Errorf("create payment: %w", err)
+	}
+
+	return boost, payment, nil
+}
+
+// GetBoosts lists the user's boosts with the views each produced.
+func (s *featureService) GetBoosts(ctx context.Context, userID uuid.UUID) ([]*internal.Boost, error) {
+	boosts, err := s.repo.GetBoosts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, boost := range boosts {
+		boost.ExtraViews = extraViews(boost)
+	}
+
+	return boosts, nil
+}
+
+// extraViews is how many more views the boost produced than its user had in
+// the same time on average over the week before it.
+func extraViews(boost *internal.Boost) int {
+	week := 7 * 24 * time.Hour
+	expected := float64(boost.BaselineViews) * float64(boost.DurationSeconds) / week.Seconds()
+	return max(boost.Views-int(math.Round(expected)), 0)
+}
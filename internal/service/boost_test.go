@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"datingapp/internal"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtraViews(t *testing.T) {
+	tests := []struct {
+		name          string
+		views         int
+		baselineViews int
+		want          int
+	}{
+		{
+			name:  "no views before",
+			views: 12,
+			want:  12,
+		},
+		{
+			name:          "more views than usual",
+			views:         12,
+			baselineViews: 3360, // 10 views per 30 minutes
+			want:          2,
+		},
+		{
+			name:          "fewer views than usual",
+			views:         4,
+			baselineViews: 3360,
+			want:          0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boost := &internal.Boost{
+				DurationSeconds: int(boostDuration.Seconds()),
+				Views:           tt.views,
+				BaselineViews:   tt.baselineViews,
+			}
+			assert.Equal(t, tt.want, extraViews(boost))
+		})
+	}
+}
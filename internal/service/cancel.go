@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CancelSubscription cancels the user's live subscription to a feature and
+// every feature bought with it. Cancelling at period end only stops renewal;
+// cancelling now ends access immediately and can refund the unused part of
+// the latest payment. The refund is committed as pending together with the
+// cancellation before the provider is asked for it, and retried in the
+// background if the provider does not make it.
+func (s *featureService) CancelSubscription(ctx context.Context, req *internal.CancelRequest) (*internal.CancelResult, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	result, refund, err := s.cancelSubscription(ctx, tx, req)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	if refund == nil {
+		return result, nil
+	}
+
+	result.RefundStatus, err = s.makeRefund(ctx, refund)
+	if err != nil {
+		return nil, err
+	}
+	if result.RefundStatus == internal.RefundStatusSucceeded {
+		result.RefundedAmount = refund.Amount
+	}
+
+	return result, nil
+}
+
+// cancelSubscription cancels the subscription within tx. It returns the
+// refund still to be made, or nil when there is nothing to refund.
+func (s *featureService) cancelSubscription(ctx context.Context, tx *sqlx.Tx, req *internal.CancelRequest) (*internal.CancelResult, *internal.Refund, error) {
+	subscription, err := s.repo.GetLiveSubscription(ctx, tx, req.UserID, req.FeatureID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get live subscription: %w", err)
+	}
+
+	result := &internal.CancelResult{Subscription: subscription}
+	var refund *internal.Refund
+	if req.Refund && !req.AtPeriodEnd && subscription.BundleID != nil {
+		refund, err = s.requestUnusedRefund(ctx, tx, subscription, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		if refund != nil {
+			result.RefundStatus = refund.Status
+		}
+	}
+
+	if err := s.repo.CancelSubscription(ctx, tx, subscription, req.AtPeriodEnd); err != nil {
+		return nil, nil, fmt.Errorf("cancel subscription: %w", err)
+	}
+
+	description := subscription.FeatureName + " subscription cancelled"
+	if req.AtPeriodEnd {
+		description = subscription.FeatureName + " subscription set to cancel at period end"
+	}
+	if err := s.recordChangeInvoice(ctx, tx, subscription, description); err != nil {
+		return nil, nil, err
+	}
+
+	return result, refund, nil
+}
+
+// requestUnusedRefund requests a refund of the share of the subscription's
+// latest payment covering the time left until its end date. Subscriptions
+// that were never paid for are not refunded.
+func (s *featureService) requestUnusedRefund(ctx context.Context, tx *sqlx.Tx, subscription *internal.UserFeature, now time.Time) (*internal.Refund, error) {
+	payment, err := s.repo.GetBundlePayment(ctx, tx, *subscription.BundleID)
+	if err != nil {
+		if errors.Is(err, internal.ErrPaymentNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get bundle payment: %w", err)
+	}
+
+	amount, err := s.unusedAmount(ctx, subscription, payment, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.requestRefund(ctx, tx, payment, amount)
+}
+
+// unusedAmount is the share of payment, the subscription's latest charge,
+// covering now to the subscription's end date. The latest charge paid for
+// the billing period ending at the end date, which renewals have moved on
+// from the start date.
+func (s *featureService) unusedAmount(ctx context.Context, subscription *internal.UserFeature, payment *internal.Payment, now time.Time) (int64, error) {
+	if subscription.EndDate == nil {
+		return 0, nil
+	}
+
+	start := subscription.StartDate
+	if subscription.PlanPriceID != nil {
+		price, err := s.repo.GetPlanPriceByID(ctx, *subscription.PlanPriceID)
+		if err != nil {
+			return 0, fmt.Errorf("get plan price: %w", err)
+		}
+		if periodStart := subscription.EndDate.AddDate(0, -price.PeriodMonths, 0); periodStart.After(start) {
+			start = periodStart
+		}
+	}
+
+	return proratedAmount(payment.Amount, start, *subscription.EndDate, now), nil
+}
+
+// proratedAmount is the share of amount, paid for start to end, that covers
+// now to end.
+func proratedAmount(amount int64, start, end, now time.Time) int64 {
+	total := end.Sub(start)
+	remaining := end.Sub(now)
+	if total <= 0 || remaining <= 0 {
+		return 0
+	}
+	if remaining > total {
+		remaining = total
+	}
+
+	return int64(float64(amount) * float64(remaining) / float64(total))
+}
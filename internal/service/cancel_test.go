@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/payment"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestProratedAmount(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int64
+	}{
+		{name: "before start", now: start.AddDate(0, 0, -5), want: 3000},
+		{name: "at start", now: start, want: 3000},
+		{name: "half way", now: start.AddDate(0, 0, 15), want: 1500},
+		{name: "one day left", now: end.AddDate(0, 0, -1), want: 100},
+		{name: "at end", now: end, want: 0},
+		{name: "after end", now: end.AddDate(0, 0, 1), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, proratedAmount(3000, start, end, tt.now))
+		})
+	}
+
+	assert.Equal(t, int64(0), proratedAmount(3000, end, start, start), "empty period")
+}
+
+func TestFeatureService_CancelSubscription(t *testing.T) {
+	userID := uuid.New()
+	featureID := uuid.New()
+
+	tests := []struct {
+		name             string
+		atPeriodEnd      bool
+		knownPayment     bool
+		commitErr        error
+		setupMock        func(repo *mock_repository.MockRepository, p *internal.Payment)
+		wantRefundStatus string
+		wantRefunded     bool
+		wantErr          string
+	}{
+		{
+			name:         "refunds the rest of the latest period",
+			knownPayment: true,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdateRefund(gomock.Any(), gomock.Any(), gomock.Cond(func(refund *internal.Refund) bool {
+					return refund.Status == internal.RefundStatusSucceeded
+				})).Return(true, nil)
+				repo.EXPECT().GetPayment(gomock.Any(), gomock.Any(), p.ID).Return(p, nil)
+				repo.EXPECT().RecordRefund(gomock.Any(), gomock.Any(), p).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), gomock.Cond(func(invoice *internal.Invoice) bool {
+					return invoice.Kind == internal.InvoiceKindRefund
+				})).Return(nil)
+			},
+			wantRefundStatus: internal.RefundStatusSucceeded,
+			wantRefunded:     true,
+		},
+		{
+			name: "refund left pending when the provider fails",
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdateRefund(gomock.Any(), gomock.Any(), gomock.Cond(func(refund *internal.Refund) bool {
+					return refund.Status == internal.RefundStatusPending && refund.Attempts == 1
+				})).Return(true, nil)
+			},
+			wantRefundStatus: internal.RefundStatusPending,
+		},
+		{
+			name:         "commit failure refunds nothing",
+			knownPayment: true,
+			commitErr:    errors.New("connection lost"),
+			setupMock:    func(repo *mock_repository.MockRepository, p *internal.Payment) {},
+			wantErr:      "commit transaction: connection lost",
+		},
+		{
+			name:        "at period end",
+			atPeriodEnd: true,
+			setupMock:   func(repo *mock_repository.MockRepository, p *internal.Payment) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			expectTransactions(t, repo, tt.commitErr)
+
+			provider := payment.NewFakeProvider()
+			providerPaymentID := "fake_pay_unknown"
+			if tt.knownPayment {
+				result, err := provider.CreateCheckout(context.Background(), &internal.Checkout{Amount: 3000, PaymentMethod: payment.FakeMethodSuccess})
+				require.NoError(t, err)
+				_, err = provider.Capture(context.Background(), result.ProviderPaymentID)
+				require.NoError(t, err)
+				providerPaymentID = result.ProviderPaymentID
+			}
+
+			// The subscription was renewed once, so only its latest month
+			// was paid for by the payment.
+			now := time.Now()
+			bundleID := uuid.New()
+			price := &internal.PlanPrice{ID: uuid.New(), PeriodMonths: 1}
+			endDate := now.AddDate(0, 0, 15)
+			subscription := &internal.UserFeature{
+				ID:          uuid.New(),
+				UserID:      userID,
+				FeatureID:   featureID,
+				FeatureName: internal.FeatureDailyResponses,
+				BundleID:    &bundleID,
+				PlanPriceID: &price.ID,
+				StartDate:   endDate.AddDate(0, -2, 0),
+				EndDate:     &endDate,
+				Status:      internal.FeatureStatusActive,
+			}
+			p := &internal.Payment{
+				ID:                uuid.New(),
+				UserID:            userID,
+				BundleID:          bundleID,
+				ProviderPaymentID: &providerPaymentID,
+				Amount:            3000,
+				Currency:          "USD",
+				Status:            internal.PaymentStatusSucceeded,
+			}
+			wantAmount := proratedAmount(p.Amount, endDate.AddDate(0, -1, 0), endDate, now)
+
+			repo.EXPECT().GetLiveSubscription(gomock.Any(), gomock.Any(), userID, featureID).Return(subscription, nil)
+			if !tt.atPeriodEnd {
+				repo.EXPECT().GetBundlePayment(gomock.Any(), gomock.Any(), bundleID).Return(p, nil)
+				repo.EXPECT().GetPlanPriceByID(gomock.Any(), price.ID).Return(price, nil)
+				repo.EXPECT().CreateRefund(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, _ *sqlx.Tx, refund *internal.Refund) error {
+						assert.InDelta(t, wantAmount, refund.Amount, 1)
+						refund.ID = uuid.New()
+						refund.Status = internal.RefundStatusPending
+						return nil
+					})
+			}
+			repo.EXPECT().CancelSubscription(gomock.Any(), gomock.Any(), subscription, tt.atPeriodEnd).Return(nil)
+			repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindChange, 0)).Return(nil)
+			tt.setupMock(repo, p)
+
+			svc := NewFeatureService(repo, provider, "USD", "secret", time.Hour, 499)
+			result, err := svc.CancelSubscription(context.Background(), &internal.CancelRequest{
+				UserID:      userID,
+				FeatureID:   featureID,
+				AtPeriodEnd: tt.atPeriodEnd,
+				Refund:      true,
+			})
+			if tt.knownPayment {
+				status, statusErr := provider.Status(context.Background(), providerPaymentID)
+				require.NoError(t, statusErr)
+				if tt.wantRefunded {
+					assert.InDelta(t, wantAmount, status.RefundedAmount, 1)
+				} else {
+					assert.Zero(t, status.RefundedAmount)
+				}
+			}
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRefundStatus, result.RefundStatus)
+			if tt.wantRefunded {
+				assert.InDelta(t, wantAmount, result.RefundedAmount, 1)
+			} else {
+				assert.Zero(t, result.RefundedAmount)
+			}
+		})
+	}
+}
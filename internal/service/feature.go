@@ -4,21 +4,33 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"datingapp/internal"
 	"datingapp/internal/repository"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type featureService struct {
-	repo repository.Repository
+	repo            repository.Repository
+	payments        internal.PaymentProvider
+	defaultCurrency string
+	webhookSecret   string
+	gracePeriod     time.Duration
+	boostPrice      int64
 }
 
-func NewFeatureService(repo repository.Repository) *featureService {
+func NewFeatureService(repo repository.Repository, payments internal.PaymentProvider, defaultCurrency, webhookSecret string, gracePeriod time.Duration, boostPrice int64) *featureService {
 	return &featureService{
-		repo: repo,
+		repo:            repo,
+		payments:        payments,
+		defaultCurrency: defaultCurrency,
+		webhookSecret:   webhookSecret,
+		gracePeriod:     gracePeriod,
+		boostPrice:      boostPrice,
 	}
 }
 
@@ -26,38 +38,440 @@ func (s *featureService) GetFeatures(ctx context.Context) ([]*internal.Subscript
 	return s.repo.GetFeatures(ctx)
 }
 
-func (s *featureService) SubscribeToFeature(ctx context.Context, feature *internal.UserFeature, period string) error {
-	if _, err := s.repo.GetFeatureByID(ctx, feature.FeatureID); err != nil {
-		return internal.ErrFeatureNotFound
+func (s *featureService) GetPlans(ctx context.Context) ([]*internal.Plan, error) {
+	return s.repo.GetPlans(ctx)
+}
+
+// SubscribeToFeature prices the subscription from the plan catalog, applies
+// any promo code, charges it and grants every feature bundled by the chosen
+// plan. The features stay pending until the payment succeeds. A trial request
+// grants the plan's features for its trial period without payment. It returns
+// the row for the requested feature.
+func (s *featureService) SubscribeToFeature(ctx context.Context, req *internal.SubscribeRequest) (*internal.UserFeature, error) {
+	if _, err := s.repo.GetFeatureByID(ctx, req.FeatureID); err != nil {
+		return nil, internal.ErrFeatureNotFound
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	subscribed, payment, err := s.subscribe(ctx, tx, req)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	if payment == nil {
+		return subscribed, nil
+	}
+
+	status, err := s.charge(ctx, payment, req.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	if status == internal.FeatureStatusPaymentFailed {
+		return nil, internal.ErrPaymentDeclined
+	}
+
+	subscribed.Status = status
+	return subscribed, nil
+}
+
+// subscribe creates the subscription within tx. It returns the payment still
+// to be charged, or nil when there is nothing to pay.
+func (s *featureService) subscribe(ctx context.Context, tx *sqlx.Tx, req *internal.SubscribeRequest) (*internal.UserFeature, *internal.Payment, error) {
+	// Expire the user's lapsed subscriptions first so that re-subscribing
+	// does not wait for the background sweep.
+	if _, err := s.repo.ExpireSubscriptions(ctx, tx, s.gracePeriod, &req.UserID); err != nil {
+		return nil, nil, fmt.Errorf("expire subscriptions: %w", err)
+	}
+
+	if req.Trial {
+		subscribed, err := s.startTrial(ctx, tx, req)
+		return subscribed, nil, err
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = s.defaultCurrency
+	}
+
+	price, err := s.repo.GetPlanPrice(ctx, req.FeatureID, req.PlanID, req.Period, currency)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get plan price: %w", err)
+	}
+
+	bundleID := uuid.New()
+	amount := price.Amount
+	if req.PromoCode != "" {
+		discount, err := s.redeemPromoCode(ctx, tx, req.PromoCode, req.UserID, price, bundleID)
+		if err != nil {
+			return nil, nil, err
+		}
+		amount -= discount
 	}
 
 	now := time.Now()
-	feature.StartDate = now
+	endDate := now.AddDate(0, price.PeriodMonths, 0)
+	status := internal.FeatureStatusActive
+	if amount > 0 {
+		status = internal.FeatureStatusPending
+	}
+
+	subscribed, err := s.createBundle(ctx, tx, req, price.PlanID, &internal.UserFeature{
+		StartDate:   now,
+		EndDate:     &endDate,
+		Status:      status,
+		AutoRenew:   amount > 0,
+		PlanPriceID: &price.ID,
+		BundleID:    &bundleID,
+		PriceAmount: amount,
+		Currency:    price.Currency,
+		Source:      internal.FeatureSourcePurchase,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	description := fmt.Sprintf("%s subscription, %s", subscribed.FeatureName, price.Period)
+	if req.PromoCode != "" {
+		description += fmt.Sprintf(" (promo code %s)", strings.ToUpper(strings.TrimSpace(req.PromoCode)))
+	}
+
+	if amount == 0 {
+		if err := s.recordChangeInvoice(ctx, tx, subscribed, description); err != nil {
+			return nil, nil, err
+		}
+		return subscribed, nil, nil
+	}
+
+	payment := &internal.Payment{
+		UserID:        req.UserID,
+		BundleID:      bundleID,
+		Provider:      s.payments.Name(),
+		Amount:        amount,
+		Currency:      price.Currency,
+		Status:        internal.PaymentStatusPending,
+		Description:   description,
+		PaymentMethod: &req.PaymentMethod,
+	}
+	if err := s.repo.CreatePayment(ctx, tx, payment); err != nil {
+		return nil, nil, fmt.Errorf("create payment: %w", err)
+	}
+
+	return subscribed, payment, nil
+}
+
+// startTrial grants the features of a plan offering a trial for its trial
+// period. Trials do not renew.
+func (s *featureService) startTrial(ctx context.Context, tx *sqlx.Tx, req *internal.SubscribeRequest) (*internal.UserFeature, error) {
+	plan, err := s.repo.GetTrialPlan(ctx, req.FeatureID, req.PlanID)
+	if err != nil {
+		return nil, fmt.Errorf("get trial plan: %w", err)
+	}
+
+	now := time.Now()
+	endDate := now.AddDate(0, 0, plan.TrialDays)
+	bundleID := uuid.New()
+
+	subscribed, err := s.createBundle(ctx, tx, req, plan.ID, &internal.UserFeature{
+		StartDate: now,
+		EndDate:   &endDate,
+		Status:    internal.FeatureStatusActive,
+		BundleID:  &bundleID,
+		Source:    internal.FeatureSourceTrial,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("%s trial, %d days", subscribed.FeatureName, plan.TrialDays)
+	if err := s.recordChangeInvoice(ctx, tx, subscribed, description); err != nil {
+		return nil, err
+	}
+
+	return subscribed, nil
+}
+
+// createBundle creates a user feature for every feature of planID, copying
+// the subscription details from template. The bundle's price is recorded on
+// the row of the requested feature, which is returned.
+func (s *featureService) createBundle(ctx context.Context, tx *sqlx.Tx, req *internal.SubscribeRequest, planID uuid.UUID, template *internal.UserFeature) (*internal.UserFeature, error) {
+	planFeatures, err := s.repo.GetPlanFeatures(ctx, planID)
+	if err != nil {
+		return nil, fmt.Errorf("get plan features: %w", err)
+	}
+
+	var subscribed *internal.UserFeature
+	for _, pf := range planFeatures {
+		feature := *template
+		feature.UserID = req.UserID
+		feature.FeatureID = pf.FeatureID
+		feature.FeatureName = pf.FeatureName
+		feature.Value = pf.Value
+		feature.PlanID = &planID
+		feature.PriceAmount = 0
+
+		if pf.FeatureID == req.FeatureID {
+			feature.PriceAmount = template.PriceAmount
+			subscribed = &feature
+		}
+
+		if err := s.repo.CreateUserFeature(ctx, tx, &feature); err != nil {
+			return nil, fmt.Errorf("create user feature: %w", err)
+		}
+	}
+
+	return subscribed, nil
+}
+
+// charge runs the checkout for payment and records its outcome on the payment
+// and its bundle. It returns the resulting feature status.
+func (s *featureService) charge(ctx context.Context, payment *internal.Payment, method string) (string, error) {
+	chargeErr := s.checkout(ctx, payment, method)
+
+	status := featureStatusForPayment(payment.Status)
+	if err := s.recordPayment(ctx, payment); err != nil {
+		return "", err
+	}
+	if chargeErr != nil {
+		return "", fmt.Errorf("charge payment: %w", chargeErr)
+	}
+
+	return status, nil
+}
+
+// checkout charges payment with the provider, capturing it once authorized,
+// and sets its provider payment ID and status. The payment is failed if the
+// provider could not be reached.
+func (s *featureService) checkout(ctx context.Context, payment *internal.Payment, method string) error {
+	result, err := s.payments.CreateCheckout(ctx, &internal.Checkout{
+		Reference:     payment.ID.String(),
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		PaymentMethod: method,
+	})
+	if err == nil && result.Status == internal.PaymentStatusAuthorized {
+		result, err = s.payments.Capture(ctx, result.ProviderPaymentID)
+	}
+	if err != nil {
+		payment.Status = internal.PaymentStatusFailed
+		return err
+	}
+
+	payment.ProviderPaymentID = &result.ProviderPaymentID
+	payment.Status = result.Status
+	return nil
+}
 
-	months := map[string]int{
-		"1_month":   1,
-		"3_months":  3,
-		"6_months":  6,
-		"12_months": 12,
+// recordPayment stores the outcome of a checkout on payment and what it pays
+// for. A payment a webhook already settled keeps the webhook's outcome.
+func (s *featureService) recordPayment(ctx context.Context, payment *internal.Payment) error {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
 	}
 
-	endDate := now.AddDate(0, months[period], 0)
-	feature.EndDate = &endDate
+	settled, err := s.settlePayment(ctx, tx, payment)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return err
+	}
+	if !settled {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		log.Printf("payment %s was settled before its checkout was recorded", payment.ID)
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// settlePayment stores the status of payment, which was awaiting its
+// outcome, and applies it to what the payment is for: a succeeded payment
+// starts its boost or starts, renews or changes to its subscription and is
+// invoiced, and a declined purchase gives back its promo code. It returns
+// false, changing nothing, when the payment had already been settled.
+func (s *featureService) settlePayment(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment) (bool, error) {
+	updated, err := s.repo.UpdatePayment(ctx, tx, payment)
+	if err != nil {
+		return false, fmt.Errorf("update payment: %w", err)
+	}
+	if !updated {
+		return false, nil
+	}
+
+	status := featureStatusForPayment(payment.Status)
+
+	// A renewal only extends the subscription; a declined one lets it lapse
+	// through its grace period.
+	if payment.Renewal {
+		if status != internal.FeatureStatusActive {
+			return true, nil
+		}
+		renewed, err := s.repo.ExtendBundle(ctx, tx, payment.BundleID)
+		if err != nil {
+			return false, fmt.Errorf("extend bundle: %w", err)
+		}
+		if !renewed {
+			log.Printf("renewal payment %s succeeded for subscription that is no longer live", payment.ID)
+		}
+		return true, s.recordPaymentInvoice(ctx, tx, payment, internal.InvoiceKindCharge, payment.Amount, "Renewal: "+payment.Description)
+	}
+
+	switch status {
+	case internal.FeatureStatusPending:
+		// The bundle stays as it was created until the payment settles.
+		return true, nil
+	case internal.FeatureStatusActive:
+		// A plan change only takes over from the subscription it changes
+		// once paid for.
+		if !payment.Boost {
+			if err := s.repo.ReplaceChangedSubscription(ctx, tx, payment.BundleID); err != nil {
+				return false, fmt.Errorf("replace changed subscription: %w", err)
+			}
+		}
+	}
+
+	if err := s.updatePaidStatus(ctx, tx, payment, status); err != nil {
+		return false, err
+	}
+
+	switch status {
+	case internal.FeatureStatusActive:
+		if err := s.recordPaymentInvoice(ctx, tx, payment, internal.InvoiceKindCharge, payment.Amount, payment.Description); err != nil {
+			return false, err
+		}
+	case internal.FeatureStatusPaymentFailed:
+		// A declined purchase does not use up its promo code.
+		if payment.Boost {
+			return true, nil
+		}
+		if err := s.repo.ReleasePromoRedemption(ctx, tx, payment.BundleID); err != nil {
+			return false, fmt.Errorf("release promo redemption: %w", err)
+		}
+	}
 
+	return true, nil
+}
+
+// updatePaidStatus sets the status of what payment paid for, its boost or
+// the features of its bundle.
+func (s *featureService) updatePaidStatus(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment, status string) error {
+	if payment.Boost {
+		if err := s.repo.UpdateBoostStatus(ctx, tx, payment.BundleID, status); err != nil {
+			return fmt.Errorf("update boost status: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.repo.UpdateBundleStatus(ctx, tx, payment.BundleID, status); err != nil {
+		return fmt.Errorf("update bundle status: %w", err)
+	}
+	return nil
+}
+
+// featureStatusForPayment maps a payment status to the status of the features
+// it pays for.
+func featureStatusForPayment(paymentStatus string) string {
+	switch paymentStatus {
+	case internal.PaymentStatusSucceeded:
+		return internal.FeatureStatusActive
+	case internal.PaymentStatusPending, internal.PaymentStatusAuthorized:
+		return internal.FeatureStatusPending
+	default:
+		return internal.FeatureStatusPaymentFailed
+	}
+}
+
+// SetAutoRenew turns renewal of the user's subscription to featureID, and of
+// the features bought with it, on or off.
+func (s *featureService) SetAutoRenew(ctx context.Context, userID, featureID uuid.UUID, autoRenew bool) error {
+	return s.repo.SetAutoRenew(ctx, userID, featureID, autoRenew)
+}
+
+// RenewSubscriptions charges every auto-renewing subscription that has
+// reached its end date for another period. A declined renewal is left to the
+// grace period; a pending one is settled by its webhook.
+func (s *featureService) RenewSubscriptions(ctx context.Context) error {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	payments, err := s.repo.CreateRenewalPayments(ctx, tx, s.payments.Name())
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return fmt.Errorf("create renewal payments: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	for _, payment := range payments {
+		method := ""
+		if payment.PaymentMethod != nil {
+			method = *payment.PaymentMethod
+		}
+		if _, err := s.charge(ctx, payment, method); err != nil {
+			log.Printf("failed to renew subscription %s: %v", payment.BundleID, err)
+		}
+	}
+
+	if len(payments) > 0 {
+		log.Printf("charged %d subscription renewals", len(payments))
+	}
+
+	return nil
+}
+
+// ExpireSubscriptions moves every lapsed subscription to past due or expired.
+func (s *featureService) ExpireSubscriptions(ctx context.Context) error {
 	tx, err := s.repo.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 
-	if err := s.repo.CreateUserFeature(ctx, tx, feature); err != nil {
+	expired, err := s.repo.ExpireSubscriptions(ctx, tx, s.gracePeriod, nil)
+	if err != nil {
 		errRollback := tx.Rollback()
 		if errRollback != nil {
 			log.Printf("failed to rollback transaction: %v", errRollback)
 		}
-		return fmt.Errorf("create user feature: %w", err)
+		return fmt.Errorf("expire subscriptions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	if expired > 0 {
+		log.Printf("expired %d subscriptions", expired)
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 func (s *featureService) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*internal.UserFeature, error) {
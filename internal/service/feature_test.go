@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/payment"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// txConnector is a database driver whose transactions do nothing, so that a
+// mock repository can hand out real transactions. Commits fail with
+// commitErr.
+type txConnector struct {
+	commitErr error
+}
+
+func (c *txConnector) Connect(context.Context) (driver.Conn, error) {
+	return &txConn{commitErr: c.commitErr}, nil
+}
+
+func (c *txConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c *txConnector) Open(string) (driver.Conn, error) {
+	return c.Connect(context.Background())
+}
+
+type txConn struct {
+	commitErr error
+}
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("statements are not supported")
+}
+
+func (c *txConn) Close() error {
+	return nil
+}
+
+func (c *txConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *txConn) Commit() error {
+	return c.commitErr
+}
+
+func (c *txConn) Rollback() error {
+	return nil
+}
+
+// expectTransactions lets repo begin any number of transactions, whose
+// commits fail with commitErr.
+func expectTransactions(t *testing.T, repo *mock_repository.MockRepository, commitErr error) {
+	db := sqlx.NewDb(sql.OpenDB(&txConnector{commitErr: commitErr}), "postgres")
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	repo.EXPECT().BeginTx(gomock.Any()).DoAndReturn(func(ctx context.Context) (*sqlx.Tx, error) {
+		return db.BeginTxx(ctx, nil)
+	}).AnyTimes()
+}
+
+// invoiceOf matches an invoice of kind for amount.
+func invoiceOf(kind string, amount int64) gomock.Matcher {
+	return gomock.Cond(func(invoice *internal.Invoice) bool {
+		return invoice.Kind == kind && invoice.Amount == amount
+	})
+}
+
+// paymentWithStatus matches a payment in status.
+func paymentWithStatus(status string) gomock.Matcher {
+	return gomock.Cond(func(p *internal.Payment) bool {
+		return p.Status == status
+	})
+}
+
+func TestFeatureService_SubscribeToFeature(t *testing.T) {
+	userID := uuid.New()
+	featureID := uuid.New()
+	price := &internal.PlanPrice{ID: uuid.New(), PlanID: uuid.New(), Period: "1_month", PeriodMonths: 1, Amount: 1000, Currency: "USD"}
+
+	tests := []struct {
+		name       string
+		method     string
+		commitErr  error
+		setupMock  func(repo *mock_repository.MockRepository)
+		wantStatus string
+		wantErr    string
+	}{
+		{
+			name:   "paid",
+			method: payment.FakeMethodSuccess,
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusSucceeded)).Return(true, nil)
+				repo.EXPECT().ReplaceChangedSubscription(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), gomock.Any(), internal.FeatureStatusActive).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindCharge, 800)).Return(nil)
+			},
+			wantStatus: internal.FeatureStatusActive,
+		},
+		{
+			name:   "pending",
+			method: payment.FakeMethodPending,
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusPending)).Return(true, nil)
+			},
+			wantStatus: internal.FeatureStatusPending,
+		},
+		{
+			name:   "declined releases promo code",
+			method: payment.FakeMethodDeclined,
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusFailed)).Return(true, nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), gomock.Any(), internal.FeatureStatusPaymentFailed).Return(nil)
+				repo.EXPECT().ReleasePromoRedemption(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantErr: "payment declined",
+		},
+		{
+			name:   "settled by webhook first",
+			method: payment.FakeMethodSuccess,
+			setupMock: func(repo *mock_repository.MockRepository) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+			},
+			wantStatus: internal.FeatureStatusActive,
+		},
+		{
+			name:      "commit failure charges nothing",
+			method:    payment.FakeMethodSuccess,
+			commitErr: errors.New("connection lost"),
+			setupMock: func(repo *mock_repository.MockRepository) {},
+			wantErr:   "commit transaction: connection lost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			expectTransactions(t, repo, tt.commitErr)
+
+			repo.EXPECT().GetFeatureByID(gomock.Any(), featureID).Return(&internal.SubscriptionFeature{ID: featureID}, nil)
+			repo.EXPECT().ExpireSubscriptions(gomock.Any(), gomock.Any(), time.Hour, &userID).Return(int64(0), nil)
+			repo.EXPECT().GetPlanPrice(gomock.Any(), featureID, nil, price.Period, "USD").Return(price, nil)
+			repo.EXPECT().GetPromoCodeForUpdate(gomock.Any(), gomock.Any(), "SAVE20").Return(&internal.PromoCode{
+				ID:            uuid.New(),
+				DiscountType:  internal.DiscountTypePercent,
+				DiscountValue: 20,
+				Active:        true,
+			}, nil)
+			repo.EXPECT().CreatePromoRedemption(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			repo.EXPECT().GetPlanFeatures(gomock.Any(), price.PlanID).Return([]*internal.PlanFeature{
+				{PlanID: price.PlanID, FeatureID: featureID, FeatureName: internal.FeatureDailyResponses, Value: internal.Unlimited},
+			}, nil)
+			repo.EXPECT().CreateUserFeature(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, _ *sqlx.Tx, p *internal.Payment) error {
+					assert.Equal(t, int64(800), p.Amount)
+					p.ID = uuid.New()
+					return nil
+				})
+			tt.setupMock(repo)
+
+			svc := NewFeatureService(repo, payment.NewFakeProvider(), "USD", "secret", time.Hour, 499)
+			subscribed, err := svc.SubscribeToFeature(context.Background(), &internal.SubscribeRequest{
+				UserID:        userID,
+				FeatureID:     featureID,
+				Period:        price.Period,
+				PaymentMethod: tt.method,
+				PromoCode:     "save20",
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, subscribed.Status)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// GrantFeature gives the user a feature without payment. Grants are read
+// like any other subscription but have the grant source, never renew and are
+// refused while the user already has the feature.
+func (s *featureService) GrantFeature(ctx context.Context, req *internal.GrantRequest) (*internal.UserFeature, error) {
+	feature, err := s.repo.GetFeatureByID(ctx, req.FeatureID)
+	if err != nil {
+		return nil, internal.ErrFeatureNotFound
+	}
+
+	if _, err := s.repo.GetUserByID(ctx, req.UserID); err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	granted, err := s.grantFeature(ctx, tx, req, feature)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return granted, nil
+}
+
+func (s *featureService) grantFeature(ctx context.Context, tx *sqlx.Tx, req *internal.GrantRequest, feature *internal.SubscriptionFeature) (*internal.UserFeature, error) {
+	if _, err := s.repo.ExpireSubscriptions(ctx, tx, s.gracePeriod, &req.UserID); err != nil {
+		return nil, fmt.Errorf("expire subscriptions: %w", err)
+	}
+
+	granted := &internal.UserFeature{
+		UserID:             req.UserID,
+		FeatureID:          req.FeatureID,
+		StartDate:          time.Now(),
+		EndDate:            req.ExpiresAt,
+		Status:             internal.FeatureStatusActive,
+		Source:             internal.FeatureSourceGrant,
+		GrantReason:        &req.Reason,
+		GrantedBy:          req.GrantedBy,
+		FeatureName:        feature.Name,
+		FeatureDescription: feature.Description,
+	}
+	if req.Value != nil {
+		granted.Value = *req.Value
+	} else if definition, ok := internal.LookupFeature(feature.Name); ok {
+		granted.Value = definition.PremiumQuota
+	}
+
+	if err := s.repo.CreateUserFeature(ctx, tx, granted); err != nil {
+		return nil, fmt.Errorf("create user feature: %w", err)
+	}
+
+	description := fmt.Sprintf("%s granted: %s", feature.Name, req.Reason)
+	if err := s.recordChangeInvoice(ctx, tx, granted, description); err != nil {
+		return nil, err
+	}
+
+	return granted, nil
+}
+
+// RevokeGrant ends the user's grant of a feature now. Paid subscriptions are
+// not affected.
+func (s *featureService) RevokeGrant(ctx context.Context, userID, featureID uuid.UUID) (*internal.UserFeature, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	revoked, err := s.revokeGrant(ctx, tx, userID, featureID)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return revoked, nil
+}
+
+func (s *featureService) revokeGrant(ctx context.Context, tx *sqlx.Tx, userID, featureID uuid.UUID) (*internal.UserFeature, error) {
+	revoked, err := s.repo.RevokeGrant(ctx, tx, userID, featureID)
+	if err != nil {
+		return nil, fmt.Errorf("revoke grant: %w", err)
+	}
+
+	if err := s.recordChangeInvoice(ctx, tx, revoked, revoked.FeatureName+" grant revoked"); err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
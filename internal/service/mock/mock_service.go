@@ -42,19 +42,120 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 	return m.recorder
 }
 
+// CompleteOAuthLogin mocks base method.
+func (m *MockUserService) CompleteOAuthLogin(ctx context.Context, provider string, callback *internal.OAuthCallback) (*internal.LoginResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteOAuthLogin", ctx, provider, callback)
+	ret0, _ := ret[0].(*internal.LoginResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteOAuthLogin indicates an expected call of CompleteOAuthLogin.
+func (mr *MockUserServiceMockRecorder) CompleteOAuthLogin(ctx, provider, callback any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteOAuthLogin", reflect.TypeOf((*MockUserService)(nil).CompleteOAuthLogin), ctx, provider, callback)
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockUserService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, userID, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockUserServiceMockRecorder) ConfirmTOTP(ctx, userID, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockUserService)(nil).ConfirmTOTP), ctx, userID, code)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockUserService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (*internal.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", ctx, userID)
+	ret0, _ := ret[0].(*internal.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockUserServiceMockRecorder) EnrollTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockUserService)(nil).EnrollTOTP), ctx, userID)
+}
+
 // Login mocks base method.
-func (m *MockUserService) Login(ctx context.Context, email, password string) (string, error) {
+func (m *MockUserService) Login(ctx context.Context, email, password, ip string) (*internal.LoginResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Login", ctx, email, password)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "Login", ctx, email, password, ip)
+	ret0, _ := ret[0].(*internal.LoginResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Login indicates an expected call of Login.
-func (mr *MockUserServiceMockRecorder) Login(ctx, email, password any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) Login(ctx, email, password, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserService)(nil).Login), ctx, email, password, ip)
+}
+
+// SetIncognito mocks base method.
+func (m *MockUserService) SetIncognito(ctx context.Context, userID uuid.UUID, incognito bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetIncognito", ctx, userID, incognito)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetIncognito indicates an expected call of SetIncognito.
+func (mr *MockUserServiceMockRecorder) SetIncognito(ctx, userID, incognito any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetIncognito", reflect.TypeOf((*MockUserService)(nil).SetIncognito), ctx, userID, incognito)
+}
+
+// SetLocation mocks base method.
+func (m *MockUserService) SetLocation(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLocation", ctx, userID, location)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLocation indicates an expected call of SetLocation.
+func (mr *MockUserServiceMockRecorder) SetLocation(ctx, userID, location any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserService)(nil).Login), ctx, email, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLocation", reflect.TypeOf((*MockUserService)(nil).SetLocation), ctx, userID, location)
+}
+
+// SetPassport mocks base method.
+func (m *MockUserService) SetPassport(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPassport", ctx, userID, location)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPassport indicates an expected call of SetPassport.
+func (mr *MockUserServiceMockRecorder) SetPassport(ctx, userID, location any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassport", reflect.TypeOf((*MockUserService)(nil).SetPassport), ctx, userID, location)
+}
+
+// SetTimezone mocks base method.
+func (m *MockUserService) SetTimezone(ctx context.Context, userID uuid.UUID, timezone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTimezone", ctx, userID, timezone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTimezone indicates an expected call of SetTimezone.
+func (mr *MockUserServiceMockRecorder) SetTimezone(ctx, userID, timezone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTimezone", reflect.TypeOf((*MockUserService)(nil).SetTimezone), ctx, userID, timezone)
 }
 
 // SignUp mocks base method.
@@ -71,6 +172,36 @@ func (mr *MockUserServiceMockRecorder) SignUp(ctx, user, password any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignUp", reflect.TypeOf((*MockUserService)(nil).SignUp), ctx, user, password)
 }
 
+// StartOAuthLogin mocks base method.
+func (m *MockUserService) StartOAuthLogin(ctx context.Context, provider string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartOAuthLogin", ctx, provider)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartOAuthLogin indicates an expected call of StartOAuthLogin.
+func (mr *MockUserServiceMockRecorder) StartOAuthLogin(ctx, provider any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartOAuthLogin", reflect.TypeOf((*MockUserService)(nil).StartOAuthLogin), ctx, provider)
+}
+
+// VerifyTwoFactor mocks base method.
+func (m *MockUserService) VerifyTwoFactor(ctx context.Context, challengeToken, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyTwoFactor", ctx, challengeToken, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyTwoFactor indicates an expected call of VerifyTwoFactor.
+func (mr *MockUserServiceMockRecorder) VerifyTwoFactor(ctx, challengeToken, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyTwoFactor", reflect.TypeOf((*MockUserService)(nil).VerifyTwoFactor), ctx, challengeToken, code)
+}
+
 // MockProfileService is a mock of ProfileService interface.
 type MockProfileService struct {
 	ctrl     *gomock.Controller
@@ -96,11 +227,12 @@ func (m *MockProfileService) EXPECT() *MockProfileServiceMockRecorder {
 }
 
 // CreateProfileResponse mocks base method.
-func (m *MockProfileService) CreateProfileResponse(ctx context.Context, fromUserID, toUserID uuid.UUID, responseType string) error {
+func (m *MockProfileService) CreateProfileResponse(ctx context.Context, fromUserID, toUserID uuid.UUID, responseType string) (*internal.Quota, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CreateProfileResponse", ctx, fromUserID, toUserID, responseType)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(*internal.Quota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // CreateProfileResponse indicates an expected call of CreateProfileResponse.
@@ -109,21 +241,82 @@ func (mr *MockProfileServiceMockRecorder) CreateProfileResponse(ctx, fromUserID,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProfileResponse", reflect.TypeOf((*MockProfileService)(nil).CreateProfileResponse), ctx, fromUserID, toUserID, responseType)
 }
 
-// GetProfiles mocks base method.
-func (m *MockProfileService) GetProfiles(ctx context.Context, userID uuid.UUID) ([]*internal.User, error) {
+// GetLikesReceived mocks base method.
+func (m *MockProfileService) GetLikesReceived(ctx context.Context, userID uuid.UUID) (*internal.LikesReceived, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetProfiles", ctx, userID)
-	ret0, _ := ret[0].([]*internal.User)
+	ret := m.ctrl.Call(m, "GetLikesReceived", ctx, userID)
+	ret0, _ := ret[0].(*internal.LikesReceived)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// GetLikesReceived indicates an expected call of GetLikesReceived.
+func (mr *MockProfileServiceMockRecorder) GetLikesReceived(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLikesReceived", reflect.TypeOf((*MockProfileService)(nil).GetLikesReceived), ctx, userID)
+}
+
+// GetNotifications mocks base method.
+func (m *MockProfileService) GetNotifications(ctx context.Context, userID uuid.UUID) ([]*internal.Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotifications", ctx, userID)
+	ret0, _ := ret[0].([]*internal.Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotifications indicates an expected call of GetNotifications.
+func (mr *MockProfileServiceMockRecorder) GetNotifications(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotifications", reflect.TypeOf((*MockProfileService)(nil).GetNotifications), ctx, userID)
+}
+
+// GetProfiles mocks base method.
+func (m *MockProfileService) GetProfiles(ctx context.Context, userID uuid.UUID) ([]*internal.Candidate, *internal.Quota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfiles", ctx, userID)
+	ret0, _ := ret[0].([]*internal.Candidate)
+	ret1, _ := ret[1].(*internal.Quota)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
 // GetProfiles indicates an expected call of GetProfiles.
 func (mr *MockProfileServiceMockRecorder) GetProfiles(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfiles", reflect.TypeOf((*MockProfileService)(nil).GetProfiles), ctx, userID)
 }
 
+// GetQuota mocks base method.
+func (m *MockProfileService) GetQuota(ctx context.Context, userID uuid.UUID) (*internal.Quota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuota", ctx, userID)
+	ret0, _ := ret[0].(*internal.Quota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuota indicates an expected call of GetQuota.
+func (mr *MockProfileServiceMockRecorder) GetQuota(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuota", reflect.TypeOf((*MockProfileService)(nil).GetQuota), ctx, userID)
+}
+
+// RewindProfileResponse mocks base method.
+func (m *MockProfileService) RewindProfileResponse(ctx context.Context, userID uuid.UUID) (*internal.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewindProfileResponse", ctx, userID)
+	ret0, _ := ret[0].(*internal.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RewindProfileResponse indicates an expected call of RewindProfileResponse.
+func (mr *MockProfileServiceMockRecorder) RewindProfileResponse(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewindProfileResponse", reflect.TypeOf((*MockProfileService)(nil).RewindProfileResponse), ctx, userID)
+}
+
 // MockFeatureService is a mock of FeatureService interface.
 type MockFeatureService struct {
 	ctrl     *gomock.Controller
@@ -148,6 +341,95 @@ func (m *MockFeatureService) EXPECT() *MockFeatureServiceMockRecorder {
 	return m.recorder
 }
 
+// ActivateBoost mocks base method.
+func (m *MockFeatureService) ActivateBoost(ctx context.Context, req *internal.BoostRequest) (*internal.Boost, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActivateBoost", ctx, req)
+	ret0, _ := ret[0].(*internal.Boost)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActivateBoost indicates an expected call of ActivateBoost.
+func (mr *MockFeatureServiceMockRecorder) ActivateBoost(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateBoost", reflect.TypeOf((*MockFeatureService)(nil).ActivateBoost), ctx, req)
+}
+
+// CancelSubscription mocks base method.
+func (m *MockFeatureService) CancelSubscription(ctx context.Context, req *internal.CancelRequest) (*internal.CancelResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelSubscription", ctx, req)
+	ret0, _ := ret[0].(*internal.CancelResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelSubscription indicates an expected call of CancelSubscription.
+func (mr *MockFeatureServiceMockRecorder) CancelSubscription(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSubscription", reflect.TypeOf((*MockFeatureService)(nil).CancelSubscription), ctx, req)
+}
+
+// ChangePlan mocks base method.
+func (m *MockFeatureService) ChangePlan(ctx context.Context, req *internal.PlanChangeRequest) (*internal.UserFeature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePlan", ctx, req)
+	ret0, _ := ret[0].(*internal.UserFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePlan indicates an expected call of ChangePlan.
+func (mr *MockFeatureServiceMockRecorder) ChangePlan(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePlan", reflect.TypeOf((*MockFeatureService)(nil).ChangePlan), ctx, req)
+}
+
+// ExpireSubscriptions mocks base method.
+func (m *MockFeatureService) ExpireSubscriptions(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireSubscriptions", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpireSubscriptions indicates an expected call of ExpireSubscriptions.
+func (mr *MockFeatureServiceMockRecorder) ExpireSubscriptions(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireSubscriptions", reflect.TypeOf((*MockFeatureService)(nil).ExpireSubscriptions), ctx)
+}
+
+// GetBillingHistory mocks base method.
+func (m *MockFeatureService) GetBillingHistory(ctx context.Context, userID uuid.UUID) ([]*internal.Invoice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBillingHistory", ctx, userID)
+	ret0, _ := ret[0].([]*internal.Invoice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBillingHistory indicates an expected call of GetBillingHistory.
+func (mr *MockFeatureServiceMockRecorder) GetBillingHistory(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillingHistory", reflect.TypeOf((*MockFeatureService)(nil).GetBillingHistory), ctx, userID)
+}
+
+// GetBoosts mocks base method.
+func (m *MockFeatureService) GetBoosts(ctx context.Context, userID uuid.UUID) ([]*internal.Boost, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBoosts", ctx, userID)
+	ret0, _ := ret[0].([]*internal.Boost)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoosts indicates an expected call of GetBoosts.
+func (mr *MockFeatureServiceMockRecorder) GetBoosts(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoosts", reflect.TypeOf((*MockFeatureService)(nil).GetBoosts), ctx, userID)
+}
+
 // GetFeatures mocks base method.
 func (m *MockFeatureService) GetFeatures(ctx context.Context) ([]*internal.SubscriptionFeature, error) {
 	m.ctrl.T.Helper()
@@ -163,6 +445,36 @@ func (mr *MockFeatureServiceMockRecorder) GetFeatures(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeatures", reflect.TypeOf((*MockFeatureService)(nil).GetFeatures), ctx)
 }
 
+// GetPlans mocks base method.
+func (m *MockFeatureService) GetPlans(ctx context.Context) ([]*internal.Plan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlans", ctx)
+	ret0, _ := ret[0].([]*internal.Plan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlans indicates an expected call of GetPlans.
+func (mr *MockFeatureServiceMockRecorder) GetPlans(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlans", reflect.TypeOf((*MockFeatureService)(nil).GetPlans), ctx)
+}
+
+// GetReceipt mocks base method.
+func (m *MockFeatureService) GetReceipt(ctx context.Context, userID, invoiceID uuid.UUID) (*internal.Receipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReceipt", ctx, userID, invoiceID)
+	ret0, _ := ret[0].(*internal.Receipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReceipt indicates an expected call of GetReceipt.
+func (mr *MockFeatureServiceMockRecorder) GetReceipt(ctx, userID, invoiceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReceipt", reflect.TypeOf((*MockFeatureService)(nil).GetReceipt), ctx, userID, invoiceID)
+}
+
 // GetUserFeatures mocks base method.
 func (m *MockFeatureService) GetUserFeatures(ctx context.Context, userID uuid.UUID) ([]*internal.UserFeature, error) {
 	m.ctrl.T.Helper()
@@ -178,16 +490,230 @@ func (mr *MockFeatureServiceMockRecorder) GetUserFeatures(ctx, userID any) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserFeatures", reflect.TypeOf((*MockFeatureService)(nil).GetUserFeatures), ctx, userID)
 }
 
-// SubscribeToFeature mocks base method.
-func (m *MockFeatureService) SubscribeToFeature(ctx context.Context, feature *internal.UserFeature, period string) error {
+// GrantFeature mocks base method.
+func (m *MockFeatureService) GrantFeature(ctx context.Context, req *internal.GrantRequest) (*internal.UserFeature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantFeature", ctx, req)
+	ret0, _ := ret[0].(*internal.UserFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrantFeature indicates an expected call of GrantFeature.
+func (mr *MockFeatureServiceMockRecorder) GrantFeature(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantFeature", reflect.TypeOf((*MockFeatureService)(nil).GrantFeature), ctx, req)
+}
+
+// HandlePaymentWebhook mocks base method.
+func (m *MockFeatureService) HandlePaymentWebhook(ctx context.Context, payload []byte, signature string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandlePaymentWebhook", ctx, payload, signature)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HandlePaymentWebhook indicates an expected call of HandlePaymentWebhook.
+func (mr *MockFeatureServiceMockRecorder) HandlePaymentWebhook(ctx, payload, signature any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandlePaymentWebhook", reflect.TypeOf((*MockFeatureService)(nil).HandlePaymentWebhook), ctx, payload, signature)
+}
+
+// PreviewPlanChange mocks base method.
+func (m *MockFeatureService) PreviewPlanChange(ctx context.Context, req *internal.PlanChangeRequest) (*internal.PlanChangePreview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewPlanChange", ctx, req)
+	ret0, _ := ret[0].(*internal.PlanChangePreview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewPlanChange indicates an expected call of PreviewPlanChange.
+func (mr *MockFeatureServiceMockRecorder) PreviewPlanChange(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewPlanChange", reflect.TypeOf((*MockFeatureService)(nil).PreviewPlanChange), ctx, req)
+}
+
+// ReconcilePayments mocks base method.
+func (m *MockFeatureService) ReconcilePayments(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcilePayments", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReconcilePayments indicates an expected call of ReconcilePayments.
+func (mr *MockFeatureServiceMockRecorder) ReconcilePayments(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcilePayments", reflect.TypeOf((*MockFeatureService)(nil).ReconcilePayments), ctx)
+}
+
+// RenewSubscriptions mocks base method.
+func (m *MockFeatureService) RenewSubscriptions(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenewSubscriptions", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RenewSubscriptions indicates an expected call of RenewSubscriptions.
+func (mr *MockFeatureServiceMockRecorder) RenewSubscriptions(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenewSubscriptions", reflect.TypeOf((*MockFeatureService)(nil).RenewSubscriptions), ctx)
+}
+
+// RetryRefunds mocks base method.
+func (m *MockFeatureService) RetryRefunds(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryRefunds", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryRefunds indicates an expected call of RetryRefunds.
+func (mr *MockFeatureServiceMockRecorder) RetryRefunds(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryRefunds", reflect.TypeOf((*MockFeatureService)(nil).RetryRefunds), ctx)
+}
+
+// RevokeGrant mocks base method.
+func (m *MockFeatureService) RevokeGrant(ctx context.Context, userID, featureID uuid.UUID) (*internal.UserFeature, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SubscribeToFeature", ctx, feature, period)
+	ret := m.ctrl.Call(m, "RevokeGrant", ctx, userID, featureID)
+	ret0, _ := ret[0].(*internal.UserFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeGrant indicates an expected call of RevokeGrant.
+func (mr *MockFeatureServiceMockRecorder) RevokeGrant(ctx, userID, featureID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeGrant", reflect.TypeOf((*MockFeatureService)(nil).RevokeGrant), ctx, userID, featureID)
+}
+
+// SetAutoRenew mocks base method.
+func (m *MockFeatureService) SetAutoRenew(ctx context.Context, userID, featureID uuid.UUID, autoRenew bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAutoRenew", ctx, userID, featureID, autoRenew)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// SetAutoRenew indicates an expected call of SetAutoRenew.
+func (mr *MockFeatureServiceMockRecorder) SetAutoRenew(ctx, userID, featureID, autoRenew any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAutoRenew", reflect.TypeOf((*MockFeatureService)(nil).SetAutoRenew), ctx, userID, featureID, autoRenew)
+}
+
+// SubscribeToFeature mocks base method.
+func (m *MockFeatureService) SubscribeToFeature(ctx context.Context, req *internal.SubscribeRequest) (*internal.UserFeature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeToFeature", ctx, req)
+	ret0, _ := ret[0].(*internal.UserFeature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // SubscribeToFeature indicates an expected call of SubscribeToFeature.
-func (mr *MockFeatureServiceMockRecorder) SubscribeToFeature(ctx, feature, period any) *gomock.Call {
+func (mr *MockFeatureServiceMockRecorder) SubscribeToFeature(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToFeature", reflect.TypeOf((*MockFeatureService)(nil).SubscribeToFeature), ctx, req)
+}
+
+// MockPaymentProvider is a mock of PaymentProvider interface.
+type MockPaymentProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockPaymentProviderMockRecorder
+	isgomock struct{}
+}
+
+// MockPaymentProviderMockRecorder is the mock recorder for MockPaymentProvider.
+type MockPaymentProviderMockRecorder struct {
+	mock *MockPaymentProvider
+}
+
+// NewMockPaymentProvider creates a new mock instance.
+func NewMockPaymentProvider(ctrl *gomock.Controller) *MockPaymentProvider {
+	mock := &MockPaymentProvider{ctrl: ctrl}
+	mock.recorder = &MockPaymentProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPaymentProvider) EXPECT() *MockPaymentProviderMockRecorder {
+	return m.recorder
+}
+
+// Capture mocks base method.
+func (m *MockPaymentProvider) Capture(ctx context.Context, providerPaymentID string) (*internal.PaymentResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capture", ctx, providerPaymentID)
+	ret0, _ := ret[0].(*internal.PaymentResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Capture indicates an expected call of Capture.
+func (mr *MockPaymentProviderMockRecorder) Capture(ctx, providerPaymentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capture", reflect.TypeOf((*MockPaymentProvider)(nil).Capture), ctx, providerPaymentID)
+}
+
+// CreateCheckout mocks base method.
+func (m *MockPaymentProvider) CreateCheckout(ctx context.Context, checkout *internal.Checkout) (*internal.PaymentResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCheckout", ctx, checkout)
+	ret0, _ := ret[0].(*internal.PaymentResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCheckout indicates an expected call of CreateCheckout.
+func (mr *MockPaymentProviderMockRecorder) CreateCheckout(ctx, checkout any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCheckout", reflect.TypeOf((*MockPaymentProvider)(nil).CreateCheckout), ctx, checkout)
+}
+
+// Name mocks base method.
+func (m *MockPaymentProvider) Name() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Name")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Name indicates an expected call of Name.
+func (mr *MockPaymentProviderMockRecorder) Name() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockPaymentProvider)(nil).Name))
+}
+
+// Refund mocks base method.
+func (m *MockPaymentProvider) Refund(ctx context.Context, providerPaymentID string, amount int64, reference string) (*internal.PaymentResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refund", ctx, providerPaymentID, amount, reference)
+	ret0, _ := ret[0].(*internal.PaymentResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refund indicates an expected call of Refund.
+func (mr *MockPaymentProviderMockRecorder) Refund(ctx, providerPaymentID, amount, reference any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refund", reflect.TypeOf((*MockPaymentProvider)(nil).Refund), ctx, providerPaymentID, amount, reference)
+}
+
+// Status mocks base method.
+func (m *MockPaymentProvider) Status(ctx context.Context, providerPaymentID string) (*internal.PaymentResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", ctx, providerPaymentID)
+	ret0, _ := ret[0].(*internal.PaymentResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockPaymentProviderMockRecorder) Status(ctx, providerPaymentID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToFeature", reflect.TypeOf((*MockFeatureService)(nil).SubscribeToFeature), ctx, feature, period)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockPaymentProvider)(nil).Status), ctx, providerPaymentID)
 }
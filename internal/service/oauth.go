@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/oidc"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+func (s *userService) StartOAuthLogin(ctx context.Context, provider string) (string, error) {
+	p, ok := s.oauthProviders[provider]
+	if !ok {
+		return "", internal.ErrUnknownOAuthProvider
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("generate state: %w", err)
+	}
+
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", fmt.Errorf("generate pkce: %w", err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", fmt.Errorf("build authorization url: %w", err)
+	}
+
+	err = s.repo.CreateOAuthState(ctx, &internal.OAuthState{
+		State:        state,
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create oauth state: %w", err)
+	}
+
+	return authURL, nil
+}
+
+// CompleteOAuthLogin redeems the authorization code and signs the user in.
+// Identities are matched by provider subject first, then linked to an
+// existing account by verified email; otherwise a password-less account is
+// created.
+func (s *userService) CompleteOAuthLogin(ctx context.Context, provider string, callback *internal.OAuthCallback) (*internal.LoginResult, error) {
+	p, ok := s.oauthProviders[provider]
+	if !ok {
+		return nil, internal.ErrUnknownOAuthProvider
+	}
+
+	state, err := s.repo.ConsumeOAuthState(ctx, callback.State, provider)
+	if err != nil {
+		return nil, fmt.Errorf("consume oauth state: %w", err)
+	}
+
+	identity, err := p.Exchange(ctx, callback.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("exchange authorization code: %w", err)
+	}
+
+	user, err := s.repo.GetUserByIdentity(ctx, provider, identity.Subject)
+	if err != nil {
+		if !errors.Is(err, internal.ErrUserNotFound) {
+			return nil, fmt.Errorf("get user by identity: %w", err)
+		}
+
+		user, err = s.linkOAuthIdentity(ctx, provider, identity, callback)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if user.TOTPEnabled {
+		challengeToken, err := s.signChallengeToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return &internal.LoginResult{ChallengeToken: challengeToken}, nil
+	}
+
+	token, err := s.signAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &internal.LoginResult{Token: token}, nil
+}
+
+func (s *userService) linkOAuthIdentity(ctx context.Context, provider string, identity *oidc.Identity, callback *internal.OAuthCallback) (*internal.User, error) {
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, internal.ErrOAuthEmailNotVerified
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, identity.Email)
+	if err != nil && !errors.Is(err, internal.ErrUserNotFound) {
+		return nil, fmt.Errorf("get user by email: %w", err)
+	}
+
+	isNewUser := user == nil
+	if isNewUser {
+		if callback.BirthDate == nil || callback.Gender == "" {
+			return nil, internal.ErrProfileIncomplete
+		}
+
+		now := time.Now()
+		user = &internal.User{
+			Email:     identity.Email,
+			Name:      identity.Name,
+			BirthDate: *callback.BirthDate,
+			Gender:    callback.Gender,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if user.Name == "" {
+			user.Name = identity.Email
+		}
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if isNewUser {
+		id, err := s.repo.CreateUser(ctx, tx, user)
+		if err != nil {
+			errRollback := tx.Rollback()
+			if errRollback != nil {
+				log.Printf("failed to rollback transaction: %v", errRollback)
+			}
+			return nil, fmt.Errorf("create user: %w", err)
+		}
+		user.ID = id
+	}
+
+	err = s.repo.CreateUserIdentity(ctx, tx, &internal.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, fmt.Errorf("create user identity: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PreviewPlanChange prices moving the user's live subscription to another
+// plan or period without changing anything.
+func (s *featureService) PreviewPlanChange(ctx context.Context, req *internal.PlanChangeRequest) (*internal.PlanChangePreview, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	// The preview only reads, so its transaction is always rolled back.
+	defer func() {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+	}()
+
+	change, err := s.quotePlanChange(ctx, tx, req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return change.preview, nil
+}
+
+// ChangePlan moves the user's live subscription to another plan or period,
+// with the unused share of the current payment credited against the new
+// price. Without an amount due the current bundle is replaced by a new one
+// starting now, and leftover credit is refunded once that is committed.
+// Otherwise the new bundle is committed as pending_change together with its
+// payment before the payment is charged: the current subscription stays live
+// until the payment succeeds, and is left untouched if it is declined. A
+// payment that never settles is failed by ReconcilePayments, which frees the
+// subscription for another change.
+func (s *featureService) ChangePlan(ctx context.Context, req *internal.PlanChangeRequest) (*internal.UserFeature, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	changed, payment, refund, err := s.changePlan(ctx, tx, req)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	if refund != nil {
+		if _, err := s.makeRefund(ctx, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	if payment == nil {
+		return changed, nil
+	}
+
+	status, err := s.charge(ctx, payment, req.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case internal.FeatureStatusPaymentFailed:
+		return nil, internal.ErrPaymentDeclined
+	case internal.FeatureStatusActive:
+		changed.Status = status
+	}
+
+	return changed, nil
+}
+
+// planChange is a priced plan change.
+type planChange struct {
+	preview *internal.PlanChangePreview
+	price   *internal.PlanPrice
+	// payment is the current subscription's payment, nil if it was never
+	// paid for.
+	payment *internal.Payment
+}
+
+func (s *featureService) quotePlanChange(ctx context.Context, tx *sqlx.Tx, req *internal.PlanChangeRequest, now time.Time) (*planChange, error) {
+	if _, err := s.repo.ExpireSubscriptions(ctx, tx, s.gracePeriod, &req.UserID); err != nil {
+		return nil, fmt.Errorf("expire subscriptions: %w", err)
+	}
+
+	current, err := s.repo.GetLiveSubscription(ctx, tx, req.UserID, req.FeatureID)
+	if err != nil {
+		return nil, fmt.Errorf("get live subscription: %w", err)
+	}
+	if current.Status == internal.FeatureStatusPending {
+		return nil, internal.ErrPlanChangeNotAllowed
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = current.Currency
+	}
+	if currency == "" {
+		currency = s.defaultCurrency
+	}
+
+	price, err := s.repo.GetPlanPrice(ctx, req.FeatureID, req.PlanID, req.Period, currency)
+	if err != nil {
+		return nil, fmt.Errorf("get plan price: %w", err)
+	}
+	if current.PlanPriceID != nil && *current.PlanPriceID == price.ID {
+		return nil, internal.ErrPlanUnchanged
+	}
+
+	change := &planChange{
+		price: price,
+		preview: &internal.PlanChangePreview{
+			Current:     current,
+			PlanID:      price.PlanID,
+			PlanPriceID: price.ID,
+			Period:      price.Period,
+			Currency:    price.Currency,
+			Price:       price.Amount,
+			StartDate:   now,
+			EndDate:     now.AddDate(0, price.PeriodMonths, 0),
+		},
+	}
+
+	if current.BundleID != nil {
+		change.payment, err = s.repo.GetBundlePayment(ctx, tx, *current.BundleID)
+		if err != nil && !errors.Is(err, internal.ErrPaymentNotFound) {
+			return nil, fmt.Errorf("get bundle payment: %w", err)
+		}
+	}
+
+	// Credit can only be given in the currency the current plan was paid in.
+	if payment := change.payment; payment != nil && payment.Currency == price.Currency {
+		credit, err := s.unusedAmount(ctx, current, payment, now)
+		if err != nil {
+			return nil, err
+		}
+		change.preview.Credit = min(credit, payment.Amount-payment.RefundedAmount)
+	}
+
+	change.preview.AmountDue = max(price.Amount-change.preview.Credit, 0)
+	change.preview.Refund = max(change.preview.Credit-price.Amount, 0)
+
+	return change, nil
+}
+
+// changePlan changes the plan within tx. It returns the payment still to be
+// charged for the change, or the refund of leftover credit still to be made;
+// either is nil when there is none.
+func (s *featureService) changePlan(ctx context.Context, tx *sqlx.Tx, req *internal.PlanChangeRequest) (*internal.UserFeature, *internal.Payment, *internal.Refund, error) {
+	change, err := s.quotePlanChange(ctx, tx, req, time.Now())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	preview := change.preview
+	current := preview.Current
+
+	template := &internal.UserFeature{
+		StartDate:   preview.StartDate,
+		EndDate:     &preview.EndDate,
+		Status:      internal.FeatureStatusActive,
+		AutoRenew:   preview.Price > 0,
+		PlanPriceID: &preview.PlanPriceID,
+		PriceAmount: preview.AmountDue,
+		Currency:    preview.Currency,
+		Source:      internal.FeatureSourcePurchase,
+	}
+	if preview.AmountDue > 0 {
+		template.Status = internal.FeatureStatusPendingChange
+		template.ReplacesID = &current.ID
+	} else if err := s.repo.ReplaceSubscription(ctx, tx, current); err != nil {
+		return nil, nil, nil, fmt.Errorf("replace subscription: %w", err)
+	}
+
+	bundleID := uuid.New()
+	template.BundleID = &bundleID
+	changed, err := s.createBundle(ctx, tx, &internal.SubscribeRequest{UserID: req.UserID, FeatureID: req.FeatureID}, preview.PlanID, template)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	description := fmt.Sprintf("%s subscription, %s (plan change)", changed.FeatureName, preview.Period)
+
+	// A paid change is billed by its charge once that succeeds.
+	if preview.AmountDue > 0 {
+		payment := &internal.Payment{
+			UserID:        req.UserID,
+			BundleID:      bundleID,
+			Provider:      s.payments.Name(),
+			Amount:        preview.AmountDue,
+			Currency:      preview.Currency,
+			Status:        internal.PaymentStatusPending,
+			Description:   description,
+			PaymentMethod: &req.PaymentMethod,
+		}
+		if err := s.repo.CreatePayment(ctx, tx, payment); err != nil {
+			return nil, nil, nil, fmt.Errorf("create payment: %w", err)
+		}
+		return changed, payment, nil, nil
+	}
+
+	if err := s.recordChangeInvoice(ctx, tx, changed, description); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var refund *internal.Refund
+	if preview.Refund > 0 {
+		refund, err = s.requestRefund(ctx, tx, change.payment, preview.Refund)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return changed, nil, refund, nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/payment"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFeatureService_ChangePlan(t *testing.T) {
+	userID := uuid.New()
+	featureID := uuid.New()
+	cheaper := &internal.PlanPrice{ID: uuid.New(), PlanID: uuid.New(), Period: "1_month", PeriodMonths: 1, Amount: 500, Currency: "USD"}
+	dearer := &internal.PlanPrice{ID: uuid.New(), PlanID: uuid.New(), Period: "12_months", PeriodMonths: 12, Amount: 20000, Currency: "USD"}
+
+	// bundleWithStatus matches a new bundle row in status.
+	bundleWithStatus := func(status string) gomock.Matcher {
+		return gomock.Cond(func(feature *internal.UserFeature) bool {
+			return feature.Status == status
+		})
+	}
+
+	tests := []struct {
+		name       string
+		price      *internal.PlanPrice
+		method     string
+		commitErr  error
+		setupMock  func(repo *mock_repository.MockRepository, current *internal.UserFeature, p *internal.Payment)
+		wantStatus string
+		wantRefund bool
+		wantErr    string
+	}{
+		{
+			name:  "cheaper plan refunds leftover credit",
+			price: cheaper,
+			setupMock: func(repo *mock_repository.MockRepository, current *internal.UserFeature, p *internal.Payment) {
+				repo.EXPECT().ReplaceSubscription(gomock.Any(), gomock.Any(), current).Return(nil)
+				repo.EXPECT().CreateUserFeature(gomock.Any(), gomock.Any(), bundleWithStatus(internal.FeatureStatusActive)).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindChange, 0)).Return(nil)
+				repo.EXPECT().CreateRefund(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, _ *sqlx.Tx, refund *internal.Refund) error {
+						refund.ID = uuid.New()
+						refund.Status = internal.RefundStatusPending
+						return nil
+					})
+				repo.EXPECT().UpdateRefund(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+				repo.EXPECT().GetPayment(gomock.Any(), gomock.Any(), p.ID).Return(p, nil)
+				repo.EXPECT().RecordRefund(gomock.Any(), gomock.Any(), p).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), gomock.Cond(func(invoice *internal.Invoice) bool {
+					return invoice.Kind == internal.InvoiceKindRefund
+				})).Return(nil)
+			},
+			wantStatus: internal.FeatureStatusActive,
+			wantRefund: true,
+		},
+		{
+			name:   "paid change replaces the current plan once paid",
+			price:  dearer,
+			method: payment.FakeMethodSuccess,
+			setupMock: func(repo *mock_repository.MockRepository, current *internal.UserFeature, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusSucceeded)).Return(true, nil)
+				repo.EXPECT().ReplaceChangedSubscription(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), gomock.Any(), internal.FeatureStatusActive).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), gomock.Cond(func(invoice *internal.Invoice) bool {
+					return invoice.Kind == internal.InvoiceKindCharge
+				})).Return(nil)
+			},
+			wantStatus: internal.FeatureStatusActive,
+		},
+		{
+			name:   "pending change keeps the current plan",
+			price:  dearer,
+			method: payment.FakeMethodPending,
+			setupMock: func(repo *mock_repository.MockRepository, current *internal.UserFeature, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusPending)).Return(true, nil)
+			},
+			wantStatus: internal.FeatureStatusPendingChange,
+		},
+		{
+			name:   "declined change keeps the current plan",
+			price:  dearer,
+			method: payment.FakeMethodDeclined,
+			setupMock: func(repo *mock_repository.MockRepository, current *internal.UserFeature, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusFailed)).Return(true, nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), gomock.Any(), internal.FeatureStatusPaymentFailed).Return(nil)
+				repo.EXPECT().ReleasePromoRedemption(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantErr: "payment declined",
+		},
+		{
+			name:      "commit failure charges nothing",
+			price:     dearer,
+			method:    payment.FakeMethodSuccess,
+			commitErr: errors.New("connection lost"),
+			setupMock: func(repo *mock_repository.MockRepository, current *internal.UserFeature, p *internal.Payment) {},
+			wantErr:   "commit transaction: connection lost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			expectTransactions(t, repo, tt.commitErr)
+
+			provider := payment.NewFakeProvider()
+			result, err := provider.CreateCheckout(context.Background(), &internal.Checkout{Amount: 3000, PaymentMethod: payment.FakeMethodSuccess})
+			require.NoError(t, err)
+			_, err = provider.Capture(context.Background(), result.ProviderPaymentID)
+			require.NoError(t, err)
+
+			// Half of the current month is left.
+			now := time.Now()
+			bundleID := uuid.New()
+			currentPrice := &internal.PlanPrice{ID: uuid.New(), PlanID: uuid.New(), Period: "1_month", PeriodMonths: 1, Amount: 3000, Currency: "USD"}
+			endDate := now.AddDate(0, 0, 15)
+			current := &internal.UserFeature{
+				ID:          uuid.New(),
+				UserID:      userID,
+				FeatureID:   featureID,
+				FeatureName: internal.FeatureDailyResponses,
+				BundleID:    &bundleID,
+				PlanPriceID: &currentPrice.ID,
+				StartDate:   endDate.AddDate(0, -1, 0),
+				EndDate:     &endDate,
+				Status:      internal.FeatureStatusActive,
+				Currency:    "USD",
+			}
+			p := &internal.Payment{
+				ID:                uuid.New(),
+				UserID:            userID,
+				BundleID:          bundleID,
+				ProviderPaymentID: &result.ProviderPaymentID,
+				Amount:            3000,
+				Currency:          "USD",
+				Status:            internal.PaymentStatusSucceeded,
+			}
+			wantRefund := proratedAmount(p.Amount, current.StartDate, endDate, now) - cheaper.Amount
+
+			repo.EXPECT().ExpireSubscriptions(gomock.Any(), gomock.Any(), time.Hour, &userID).Return(int64(0), nil)
+			repo.EXPECT().GetLiveSubscription(gomock.Any(), gomock.Any(), userID, featureID).Return(current, nil)
+			repo.EXPECT().GetPlanPrice(gomock.Any(), featureID, nil, tt.price.Period, "USD").Return(tt.price, nil)
+			repo.EXPECT().GetBundlePayment(gomock.Any(), gomock.Any(), bundleID).Return(p, nil)
+			repo.EXPECT().GetPlanPriceByID(gomock.Any(), currentPrice.ID).Return(currentPrice, nil)
+			repo.EXPECT().GetPlanFeatures(gomock.Any(), tt.price.PlanID).Return([]*internal.PlanFeature{
+				{PlanID: tt.price.PlanID, FeatureID: featureID, FeatureName: internal.FeatureDailyResponses, Value: internal.Unlimited},
+			}, nil)
+			if tt.price.Amount > p.Amount {
+				// A paid change waits for its payment to replace the current
+				// plan.
+				repo.EXPECT().CreateUserFeature(gomock.Any(), gomock.Any(), gomock.Cond(func(feature *internal.UserFeature) bool {
+					return feature.Status == internal.FeatureStatusPendingChange && *feature.ReplacesID == current.ID
+				})).Return(nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, _ *sqlx.Tx, change *internal.Payment) error {
+						assert.Less(t, change.Amount, tt.price.Amount)
+						change.ID = uuid.New()
+						return nil
+					})
+			}
+			tt.setupMock(repo, current, p)
+
+			svc := NewFeatureService(repo, provider, "USD", "secret", time.Hour, 499)
+			changed, err := svc.ChangePlan(context.Background(), &internal.PlanChangeRequest{
+				UserID:        userID,
+				FeatureID:     featureID,
+				Period:        tt.price.Period,
+				PaymentMethod: tt.method,
+			})
+
+			status, statusErr := provider.Status(context.Background(), result.ProviderPaymentID)
+			require.NoError(t, statusErr)
+			if tt.wantRefund {
+				assert.InDelta(t, wantRefund, status.RefundedAmount, 1)
+			} else {
+				assert.Zero(t, status.RefundedAmount)
+			}
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, changed.Status)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -10,38 +11,72 @@ import (
 	"datingapp/internal/repository"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type profileService struct {
-	repo      repository.Repository
-	jwtSecret []byte
+	repo                 repository.Repository
+	jwtSecret            []byte
+	freeDailyResponses   int
+	freeWeeklySuperLikes int
+	rewindWindow         time.Duration
+	discoveryRadiusKm    int
 }
 
-func NewProfileService(repo repository.Repository, jwtSecret string) *profileService {
+func NewProfileService(repo repository.Repository, jwtSecret string, freeDailyResponses, freeWeeklySuperLikes int, rewindWindow time.Duration, discoveryRadiusKm int) *profileService {
 	return &profileService{
-		repo:      repo,
-		jwtSecret: []byte(jwtSecret),
+		repo:                 repo,
+		jwtSecret:            []byte(jwtSecret),
+		freeDailyResponses:   freeDailyResponses,
+		freeWeeklySuperLikes: freeWeeklySuperLikes,
+		rewindWindow:         rewindWindow,
+		discoveryRadiusKm:    discoveryRadiusKm,
 	}
 }
 
-func (s *profileService) CreateProfileResponse(ctx context.Context, fromUserID, toUserID uuid.UUID, responseType string) error {
-	unlimitedResponses := internal.HasFeature(ctx, internal.FeatureDailyResponses)
+const (
+	// notificationsLimit is the number of notifications listed.
+	notificationsLimit = 50
+	// likesReceivedLimit is the number of likes received listed.
+	likesReceivedLimit = 50
+)
 
-	if !unlimitedResponses {
-		since := time.Now().Truncate(24 * time.Hour)
-		count, err := s.repo.GetDailyInteractionCount(ctx, fromUserID, since)
-		if err != nil {
-			return fmt.Errorf("get daily interaction count: %w", err)
-		}
+// CreateProfileResponse records the response and counts it against the
+// user's limit in one transaction, so concurrent responses cannot go over the
+// limit. Super likes count against the weekly super like limit instead of
+// the daily one and notify the recipient. It returns the user's quota after
+// the response, also when the response is refused for going over a limit.
+func (s *profileService) CreateProfileResponse(ctx context.Context, fromUserID, toUserID uuid.UUID, responseType string) (*internal.Quota, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
 
-		if count >= 10 {
-			return internal.ErrDailyInteractionLimitExceeded
+	quota, err := s.createProfileResponse(ctx, tx, fromUserID, toUserID, responseType)
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
 		}
+		return quota, err
 	}
 
-	tx, err := s.repo.BeginTx(ctx)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return quota, nil
+}
+
+func (s *profileService) createProfileResponse(ctx context.Context, tx *sqlx.Tx, fromUserID, toUserID uuid.UUID, responseType string) (*internal.Quota, error) {
+	day, err := s.usageDay(ctx, fromUserID, time.Now())
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return nil, err
+	}
+
+	quota, err := s.consumeResponse(ctx, tx, fromUserID, day, responseType)
+	if err != nil {
+		return quota, err
 	}
 
 	response := &internal.ProfileResponse{
@@ -52,30 +87,299 @@ func (s *profileService) CreateProfileResponse(ctx context.Context, fromUserID,
 	}
 
 	if err := s.repo.CreateProfileResponse(ctx, tx, response); err != nil {
+		return nil, fmt.Errorf("create profile response: %w", err)
+	}
+
+	if responseType == internal.ResponseTypeSuperLike {
+		err := s.repo.CreateNotification(ctx, tx, &internal.Notification{
+			UserID:  toUserID,
+			Type:    internal.NotificationTypeSuperLike,
+			ActorID: &fromUserID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create notification: %w", err)
+		}
+	}
+
+	return quota, nil
+}
+
+// consumeResponse counts a response of responseType made on day against the
+// user's limit for it and returns the resulting quota. A super like leaves
+// the daily quota as it is and adds the super like quota to it. When the
+// limit is used up it returns the exhausted quota with a *DailyLimitError or
+// *SuperLikeLimitError.
+func (s *profileService) consumeResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, day time.Time, responseType string) (*internal.Quota, error) {
+	if responseType != internal.ResponseTypeSuperLike {
+		limit := s.dailyResponseLimit(ctx)
+		used, err := s.repo.ConsumeDailyResponse(ctx, tx, userID, day, limit)
+		if errors.Is(err, internal.ErrDailyInteractionLimitExceeded) {
+			return newQuota(limit, limit, day.AddDate(0, 0, 1)), &internal.DailyLimitError{ResetAt: day.AddDate(0, 0, 1)}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("consume daily response: %w", err)
+		}
+		return newQuota(limit, used, day.AddDate(0, 0, 1)), nil
+	}
+
+	week := weekStart(day)
+	limit := s.weeklySuperLikeLimit(ctx)
+	used, consumeErr := s.repo.ConsumeWeeklySuperLike(ctx, tx, userID, week, limit)
+	if errors.Is(consumeErr, internal.ErrSuperLikeLimitExceeded) {
+		used = limit
+	} else if consumeErr != nil {
+		return nil, fmt.Errorf("consume weekly super like: %w", consumeErr)
+	}
+
+	quota, err := s.dailyQuota(ctx, userID, day)
+	if err != nil {
+		return nil, err
+	}
+	quota.SuperLikes = newQuota(limit, used, week.AddDate(0, 0, 7))
+
+	if consumeErr != nil {
+		return quota, &internal.SuperLikeLimitError{ResetAt: week.AddDate(0, 0, 7)}
+	}
+	return quota, nil
+}
+
+// RewindProfileResponse undoes the user's latest response if it is a pass
+// made within the rewind window. The profile returns to the deck, the pass is
+// refunded to the daily response limit and the rewound profile is returned.
+// Likes and super likes are not rewound, as they may already have matched or
+// notified the other user.
+func (s *profileService) RewindProfileResponse(ctx context.Context, userID uuid.UUID) (*internal.User, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	response, err := s.rewindProfileResponse(ctx, tx, userID)
+	if err != nil {
 		errRollback := tx.Rollback()
 		if errRollback != nil {
 			log.Printf("failed to rollback transaction: %v", errRollback)
 		}
-		return fmt.Errorf("create profile response: %w", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
 	}
 
-	return tx.Commit()
+	profile, err := s.repo.GetUserByID(ctx, response.ToUserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	return profile, nil
 }
 
-func (s *profileService) GetProfiles(ctx context.Context, userID uuid.UUID) ([]*internal.User, error) {
-	unlimitedResponses := internal.HasFeature(ctx, internal.FeatureDailyResponses)
+func (s *profileService) rewindProfileResponse(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID) (*internal.ProfileResponse, error) {
+	response, err := s.repo.DeleteLatestPass(ctx, tx, userID, s.rewindWindow)
+	if err != nil {
+		return nil, err
+	}
 
-	if !unlimitedResponses {
-		since := time.Now().Truncate(24 * time.Hour)
-		count, err := s.repo.GetDailyInteractionCount(ctx, userID, since)
-		if err != nil {
-			return nil, fmt.Errorf("get daily interaction count: %w", err)
-		}
+	day, err := s.usageDay(ctx, userID, response.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RefundDailyResponse(ctx, tx, userID, day); err != nil {
+		return nil, fmt.Errorf("refund daily response: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetProfiles returns the next candidate within the discovery radius.
+// Candidates who super liked the user come first and boosted ones are
+// likelier to be picked. The view is recorded for boost reports. Users out of
+// daily responses still get candidates while they have super likes left. It
+// returns the user's quota too, also when refusing for the daily limit.
+func (s *profileService) GetProfiles(ctx context.Context, userID uuid.UUID) ([]*internal.Candidate, *internal.Quota, error) {
+	day, err := s.usageDay(ctx, userID, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quota, err := s.quota(ctx, userID, day)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if exhausted(quota) && exhausted(quota.SuperLikes) {
+		return nil, quota, &internal.DailyLimitError{ResetAt: quota.ResetAt}
+	}
+
+	profiles, err := s.repo.GetProfiles(ctx, userID, s.discoveryRadiusKm, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	viewed := make([]uuid.UUID, len(profiles))
+	for i, profile := range profiles {
+		viewed[i] = profile.ID
+	}
+	if err := s.repo.RecordProfileViews(ctx, userID, viewed); err != nil {
+		log.Printf("failed to record profile views for user %s: %v", userID, err)
+	}
+
+	return profiles, quota, nil
+}
 
-		if count >= 10 {
-			return nil, internal.ErrDailyInteractionLimitExceeded
+// GetQuota reports how much of the user's daily response and weekly super
+// like limits is left.
+func (s *profileService) GetQuota(ctx context.Context, userID uuid.UUID) (*internal.Quota, error) {
+	day, err := s.usageDay(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.quota(ctx, userID, day)
+}
+
+func (s *profileService) GetNotifications(ctx context.Context, userID uuid.UUID) ([]*internal.Notification, error) {
+	return s.repo.GetNotifications(ctx, userID, notificationsLimit)
+}
+
+// GetLikesReceived counts the likes the user has not responded to and lists
+// the latest of them. Without the likes received feature the likes are not
+// loaded and the list only holds a blurred placeholder for each.
+func (s *profileService) GetLikesReceived(ctx context.Context, userID uuid.UUID) (*internal.LikesReceived, error) {
+	count, err := s.repo.CountLikesReceived(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("count likes received: %w", err)
+	}
+
+	if !internal.HasFeature(ctx, internal.FeatureLikesReceived) {
+		likes := make([]*internal.ReceivedLike, min(count, likesReceivedLimit))
+		for i := range likes {
+			likes[i] = &internal.ReceivedLike{Blurred: true}
 		}
+		return &internal.LikesReceived{
+			Count: count,
+			Likes: likes,
+		}, nil
+	}
+
+	likes, err := s.repo.GetLikesReceived(ctx, userID, likesReceivedLimit)
+	if err != nil {
+		return nil, fmt.Errorf("get likes received: %w", err)
+	}
+
+	return &internal.LikesReceived{
+		Count: count,
+		Likes: likes,
+	}, nil
+}
+
+// quota is the user's daily response quota for day, with their super like
+// quota for its week.
+func (s *profileService) quota(ctx context.Context, userID uuid.UUID, day time.Time) (*internal.Quota, error) {
+	quota, err := s.dailyQuota(ctx, userID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	week := weekStart(day)
+	superLikes, err := s.repo.GetWeeklySuperLikes(ctx, userID, week)
+	if err != nil {
+		return nil, fmt.Errorf("get weekly super likes: %w", err)
+	}
+	quota.SuperLikes = newQuota(s.weeklySuperLikeLimit(ctx), superLikes, week.AddDate(0, 0, 7))
+
+	return quota, nil
+}
+
+func (s *profileService) dailyQuota(ctx context.Context, userID uuid.UUID, day time.Time) (*internal.Quota, error) {
+	used, err := s.repo.GetDailyUsage(ctx, userID, day)
+	if err != nil {
+		return nil, fmt.Errorf("get daily usage: %w", err)
 	}
 
-	return s.repo.GetProfiles(ctx, userID, 1)
+	return newQuota(s.dailyResponseLimit(ctx), used, day.AddDate(0, 0, 1)), nil
+}
+
+func newQuota(limit, used int, resetAt time.Time) *internal.Quota {
+	quota := &internal.Quota{
+		Used:    used,
+		ResetAt: resetAt,
+	}
+	if limit != internal.Unlimited {
+		remaining := max(limit-used, 0)
+		quota.Limit = &limit
+		quota.Remaining = &remaining
+	}
+
+	return quota
+}
+
+// exhausted reports whether nothing is left of a limited quota.
+func exhausted(quota *internal.Quota) bool {
+	return quota.Remaining != nil && *quota.Remaining == 0
+}
+
+// dailyResponseLimit is the number of responses a day allowed to the user
+// whose active features are in ctx.
+func (s *profileService) dailyResponseLimit(ctx context.Context) int {
+	return internal.FeatureQuota(ctx, internal.FeatureDailyResponses, s.freeDailyResponses)
+}
+
+// weeklySuperLikeLimit is the number of super likes a week allowed to the
+// user whose active features are in ctx.
+func (s *profileService) weeklySuperLikeLimit(ctx context.Context) int {
+	return internal.FeatureQuota(ctx, internal.FeatureSuperLikes, s.freeWeeklySuperLikes)
+}
+
+// usageDay is the start of the day, in the user's time zone, whose limit a
+// response made at t counts against. The limit resets a day later.
+func (s *profileService) usageDay(ctx context.Context, userID uuid.UUID, t time.Time) (time.Time, error) {
+	return localDay(ctx, s.repo, userID, t)
+}
+
+// localDay is the start of the day of t in the user's time zone.
+func localDay(ctx context.Context, repo repository.Repository, userID uuid.UUID, t time.Time) (time.Time, error) {
+	user, err := repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get user: %w", err)
+	}
+
+	timezone := usageTimezone(user, t)
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("invalid timezone %q for user %s, using UTC: %v", timezone, userID, err)
+		loc = time.UTC
+	}
+
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc), nil
+}
+
+// usageTimezone is the time zone whose days the limits of user are counted in
+// at t. After a change of time zone the previous one still applies until the
+// day the change was made on ends there, so that changing to a zone on
+// another date does not start a fresh day early.
+func usageTimezone(user *internal.User, t time.Time) string {
+	if user.PreviousTimezone == nil || user.TimezoneChangedAt == nil {
+		return user.Timezone
+	}
+
+	loc, err := time.LoadLocation(*user.PreviousTimezone)
+	if err != nil {
+		return user.Timezone
+	}
+
+	year, month, day := user.TimezoneChangedAt.In(loc).Date()
+	if t.Before(time.Date(year, month, day+1, 0, 0, 0, 0, loc)) {
+		return *user.PreviousTimezone
+	}
+	return user.Timezone
+}
+
+// weekStart is the Monday starting the week of day.
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
 }
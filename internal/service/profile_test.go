@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/repository"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// TestProfileService_CreateProfileResponse_ConcurrentLimit runs against a
+// migrated database named by TEST_DATABASE_DSN and is skipped without one.
+func TestProfileService_CreateProfileResponse_ConcurrentLimit(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := repository.NewRepository(db)
+	limit := 10
+	svc := NewProfileService(repo, "", limit, 1, time.Minute, 100)
+
+	responses := limit * 3
+
+	userIDs := make([]uuid.UUID, responses+1)
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	for i := range userIDs {
+		userIDs[i], err = repo.CreateUser(ctx, tx, &internal.User{
+			Email:     fmt.Sprintf("quota-%s@example.com", uuid.NewString()),
+			Name:      "Quota Test",
+			BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			Gender:    "female",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	t.Cleanup(func() {
+		ids := make([]string, len(userIDs))
+		for i, id := range userIDs {
+			ids[i] = id.String()
+		}
+		query, args, err := sqlx.In(`DELETE FROM profile_responses WHERE from_user_id IN (?)`, ids)
+		if err == nil {
+			db.MustExec(db.Rebind(query), args...)
+		}
+		query, args, err = sqlx.In(`DELETE FROM daily_usage WHERE user_id IN (?)`, ids)
+		if err == nil {
+			db.MustExec(db.Rebind(query), args...)
+		}
+		query, args, err = sqlx.In(`DELETE FROM users WHERE id IN (?)`, ids)
+		if err == nil {
+			db.MustExec(db.Rebind(query), args...)
+		}
+	})
+
+	fromUserID := userIDs[0]
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+		rejected int
+	)
+	for _, toUserID := range userIDs[1:] {
+		wg.Add(1)
+		go func(toUserID uuid.UUID) {
+			defer wg.Done()
+
+			_, err := svc.CreateProfileResponse(ctx, fromUserID, toUserID, "like")
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				accepted++
+			case errors.Is(err, internal.ErrDailyInteractionLimitExceeded):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(toUserID)
+	}
+	wg.Wait()
+
+	assert.Equal(t, limit, accepted)
+	assert.Equal(t, responses-limit, rejected)
+
+	day, err := svc.usageDay(ctx, fromUserID, time.Now())
+	require.NoError(t, err)
+	count, err := repo.GetDailyUsage(ctx, fromUserID, day)
+	require.NoError(t, err)
+	assert.Equal(t, limit, count)
+}
+
+func TestProfileService_GetLikesReceived(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name        string
+		features    []*internal.UserFeature
+		wantBlurred bool
+	}{
+		{
+			name:        "free user sees placeholders",
+			wantBlurred: true,
+		},
+		{
+			name:     "premium user sees profiles",
+			features: []*internal.UserFeature{{FeatureName: internal.FeatureLikesReceived, Value: internal.Unlimited}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			repo.EXPECT().CountLikesReceived(gomock.Any(), userID).Return(2, nil)
+			if !tt.wantBlurred {
+				likedAt := time.Now()
+				repo.EXPECT().GetLikesReceived(gomock.Any(), userID, likesReceivedLimit).Return([]*internal.ReceivedLike{
+					{Profile: &internal.User{ID: uuid.New()}, ResponseType: internal.ResponseTypeLike, LikedAt: &likedAt},
+					{Profile: &internal.User{ID: uuid.New()}, ResponseType: internal.ResponseTypeSuperLike, LikedAt: &likedAt},
+				}, nil)
+			}
+
+			svc := NewProfileService(repo, "", 10, 1, time.Minute, 100)
+			ctx := internal.SetActiveFeatures(context.Background(), tt.features)
+
+			likes, err := svc.GetLikesReceived(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, 2, likes.Count)
+			require.Len(t, likes.Likes, 2)
+			for _, like := range likes.Likes {
+				assert.Equal(t, tt.wantBlurred, like.Blurred)
+				assert.Equal(t, tt.wantBlurred, like.Profile == nil)
+				assert.Equal(t, tt.wantBlurred, like.ResponseType == "")
+				assert.Equal(t, tt.wantBlurred, like.LikedAt == nil)
+			}
+		})
+	}
+}
+
+func TestProfileService_GetProfiles(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name              string
+		superLikesUsed    int
+		wantLimitExceeded bool
+	}{
+		{
+			name:           "out of responses with super likes left",
+			superLikesUsed: 0,
+		},
+		{
+			name:              "out of responses and super likes",
+			superLikesUsed:    1,
+			wantLimitExceeded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			repo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&internal.User{ID: userID, Timezone: "UTC"}, nil)
+			repo.EXPECT().GetDailyUsage(gomock.Any(), userID, gomock.Any()).Return(10, nil)
+			repo.EXPECT().GetWeeklySuperLikes(gomock.Any(), userID, gomock.Any()).Return(tt.superLikesUsed, nil)
+			candidate := &internal.Candidate{User: internal.User{ID: uuid.New()}}
+			if !tt.wantLimitExceeded {
+				repo.EXPECT().GetProfiles(gomock.Any(), userID, 100, 1).Return([]*internal.Candidate{candidate}, nil)
+				repo.EXPECT().RecordProfileViews(gomock.Any(), userID, []uuid.UUID{candidate.ID}).Return(nil)
+			}
+
+			svc := NewProfileService(repo, "", 10, 1, time.Minute, 100)
+			ctx := internal.SetActiveFeatures(context.Background(), nil)
+
+			profiles, quota, err := svc.GetProfiles(ctx, userID)
+			if tt.wantLimitExceeded {
+				assert.ErrorIs(t, err, internal.ErrDailyInteractionLimitExceeded)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, []*internal.Candidate{candidate}, profiles)
+			}
+			require.NotNil(t, quota)
+			assert.Equal(t, 0, *quota.Remaining)
+			assert.Equal(t, 1-tt.superLikesUsed, *quota.SuperLikes.Remaining)
+		})
+	}
+}
+
+func TestProfileService_CreateProfileResponse_TimezoneChange(t *testing.T) {
+	userID := uuid.New()
+	kiritimati, err := time.LoadLocation("Pacific/Kiritimati")
+	require.NoError(t, err)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		changedAt time.Time
+		// wantDay is the day whose limit the response counts against.
+		wantDay time.Time
+	}{
+		{
+			name:      "change after using up the quota keeps the day",
+			changedAt: now,
+			wantDay:   now.UTC().Truncate(24 * time.Hour),
+		},
+		{
+			name:      "change made on an earlier day applies",
+			changedAt: now.AddDate(0, 0, -2),
+			wantDay: func() time.Time {
+				year, month, day := now.In(kiritimati).Date()
+				return time.Date(year, month, day, 0, 0, 0, 0, kiritimati)
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// The user used up their quota in UTC, then moved to a zone
+			// already on the next day.
+			previous := "UTC"
+			repo := mock_repository.NewMockRepository(ctrl)
+			expectTransactions(t, repo, nil)
+			repo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&internal.User{
+				ID:                userID,
+				Timezone:          "Pacific/Kiritimati",
+				PreviousTimezone:  &previous,
+				TimezoneChangedAt: &tt.changedAt,
+			}, nil)
+			repo.EXPECT().ConsumeDailyResponse(gomock.Any(), gomock.Any(), userID, gomock.Cond(func(day time.Time) bool {
+				return day.Equal(tt.wantDay)
+			}), 10).Return(0, internal.ErrDailyInteractionLimitExceeded)
+
+			svc := NewProfileService(repo, "", 10, 1, time.Minute, 100)
+			ctx := internal.SetActiveFeatures(context.Background(), nil)
+
+			quota, err := svc.CreateProfileResponse(ctx, userID, uuid.New(), internal.ResponseTypeLike)
+			var limitErr *internal.DailyLimitError
+			require.ErrorAs(t, err, &limitErr)
+			assert.True(t, limitErr.ResetAt.Equal(tt.wantDay.AddDate(0, 0, 1)))
+			assert.Equal(t, 0, *quota.Remaining)
+			assert.True(t, quota.ResetAt.Equal(limitErr.ResetAt))
+		})
+	}
+}
+
+func TestProfileService_CreateProfileResponse_SuperLikeQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fromUserID, toUserID := uuid.New(), uuid.New()
+	repo := mock_repository.NewMockRepository(ctrl)
+	expectTransactions(t, repo, nil)
+	repo.EXPECT().GetUserByID(gomock.Any(), fromUserID).Return(&internal.User{ID: fromUserID, Timezone: "UTC"}, nil)
+	// The super like count comes from the consume call, not another read.
+	repo.EXPECT().ConsumeWeeklySuperLike(gomock.Any(), gomock.Any(), fromUserID, gomock.Any(), 1).Return(1, nil)
+	repo.EXPECT().GetDailyUsage(gomock.Any(), fromUserID, gomock.Any()).Return(4, nil)
+	repo.EXPECT().CreateProfileResponse(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	repo.EXPECT().CreateNotification(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+	svc := NewProfileService(repo, "", 10, 1, time.Minute, 100)
+	ctx := internal.SetActiveFeatures(context.Background(), nil)
+
+	quota, err := svc.CreateProfileResponse(ctx, fromUserID, toUserID, internal.ResponseTypeSuperLike)
+	require.NoError(t, err)
+	assert.Equal(t, 6, *quota.Remaining)
+	assert.Equal(t, 1, quota.SuperLikes.Used)
+	assert.Equal(t, 0, *quota.SuperLikes.Remaining)
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// redeemPromoCode checks that code can discount price and records its
+// redemption by the user for bundleID. It returns the discount, which never
+// exceeds the price.
+func (s *featureService) redeemPromoCode(ctx context.Context, tx *sqlx.Tx, code string, userID uuid.UUID, price *internal.PlanPrice, bundleID uuid.UUID) (int64, error) {
+	promo, err := s.repo.GetPromoCodeForUpdate(ctx, tx, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return 0, fmt.Errorf("get promo code: %w", err)
+	}
+
+	switch {
+	case !promo.Active:
+		return 0, internal.ErrPromoCodeNotFound
+	case promo.ExpiresAt != nil && !time.Now().Before(*promo.ExpiresAt):
+		return 0, internal.ErrPromoCodeExpired
+	case promo.MaxRedemptions != nil && promo.RedemptionCount >= *promo.MaxRedemptions:
+		return 0, internal.ErrPromoCodeExhausted
+	case len(promo.PlanIDs) > 0 && !slices.Contains(promo.PlanIDs, price.PlanID):
+		return 0, internal.ErrPromoCodeNotApplicable
+	case promo.DiscountType == internal.DiscountTypeFixed && promo.Currency != price.Currency:
+		return 0, internal.ErrPromoCodeNotApplicable
+	}
+
+	discount := promoDiscount(promo, price.Amount)
+	err = s.repo.CreatePromoRedemption(ctx, tx, &internal.PromoRedemption{
+		PromoCodeID:    promo.ID,
+		UserID:         userID,
+		BundleID:       bundleID,
+		DiscountAmount: discount,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("create promo redemption: %w", err)
+	}
+
+	return discount, nil
+}
+
+// promoDiscount is the discount promo gives on amount, rounded down.
+func promoDiscount(promo *internal.PromoCode, amount int64) int64 {
+	discount := promo.DiscountValue
+	if promo.DiscountType == internal.DiscountTypePercent {
+		discount = amount * promo.DiscountValue / 100
+	}
+	return min(discount, amount)
+}
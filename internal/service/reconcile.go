@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"datingapp/internal"
+)
+
+// paymentCheckDelay is how long a payment awaits its outcome before the
+// provider is asked for it, leaving time for a checkout in flight to be
+// recorded.
+const paymentCheckDelay = 15 * time.Minute
+
+// pendingPaymentTimeout is how long a payment may await its outcome before it
+// is failed, so that what it pays for stops blocking new subscriptions, plan
+// changes and boosts.
+const pendingPaymentTimeout = 24 * time.Hour
+
+// ReconcilePayments settles the payments whose outcome was never recorded,
+// because the checkout was interrupted or its webhook never arrived. Each is
+// settled with the outcome the provider reports, and failed once it has
+// awaited its outcome for pendingPaymentTimeout.
+func (s *featureService) ReconcilePayments(ctx context.Context) error {
+	payments, err := s.repo.GetUnsettledPayments(ctx, s.payments.Name(), paymentCheckDelay)
+	if err != nil {
+		return fmt.Errorf("get unsettled payments: %w", err)
+	}
+
+	for _, payment := range payments {
+		if err := s.reconcilePayment(ctx, payment); err != nil {
+			log.Printf("failed to reconcile payment %s: %v", payment.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reconcilePayment asks the provider for the outcome of payment, capturing it
+// if it was authorized, and records it. A payment the provider never saw, or
+// that is still pending there, is left alone until it times out.
+func (s *featureService) reconcilePayment(ctx context.Context, payment *internal.Payment) error {
+	if payment.ProviderPaymentID != nil {
+		result, err := s.payments.Status(ctx, *payment.ProviderPaymentID)
+		if err == nil && result.Status == internal.PaymentStatusAuthorized {
+			result, err = s.payments.Capture(ctx, result.ProviderPaymentID)
+		}
+		if err != nil {
+			log.Printf("failed to get status of payment %s: %v", payment.ID, err)
+		} else {
+			payment.Status = result.Status
+		}
+	}
+
+	if featureStatusForPayment(payment.Status) == internal.FeatureStatusPending {
+		if time.Since(payment.CreatedAt) < pendingPaymentTimeout {
+			return nil
+		}
+		log.Printf("failing payment %s still awaiting its outcome after %s", payment.ID, pendingPaymentTimeout)
+		payment.Status = internal.PaymentStatusFailed
+	}
+
+	return s.recordPayment(ctx, payment)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/payment"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFeatureService_ReconcilePayments(t *testing.T) {
+	tests := []struct {
+		name string
+		// method is the checkout that was made, or empty when the payment
+		// never reached the provider.
+		method string
+		// settle is the outcome the provider reached after the checkout.
+		settle    string
+		age       time.Duration
+		setupMock func(repo *mock_repository.MockRepository, p *internal.Payment)
+	}{
+		{
+			name:   "payment settled with the provider is recorded",
+			method: payment.FakeMethodPending,
+			settle: internal.PaymentStatusSucceeded,
+			age:    time.Hour,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusSucceeded)).Return(true, nil)
+				repo.EXPECT().ReplaceChangedSubscription(gomock.Any(), gomock.Any(), p.BundleID).Return(nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusActive).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindCharge, p.Amount)).Return(nil)
+			},
+		},
+		{
+			name:      "payment still pending with the provider waits",
+			method:    payment.FakeMethodPending,
+			age:       time.Hour,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {},
+		},
+		{
+			name:   "payment still pending after the timeout fails",
+			method: payment.FakeMethodPending,
+			age:    pendingPaymentTimeout,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusFailed)).Return(true, nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusPaymentFailed).Return(nil)
+				repo.EXPECT().ReleasePromoRedemption(gomock.Any(), gomock.Any(), p.BundleID).Return(nil)
+			},
+		},
+		{
+			name:   "plan change declined by the provider is failed",
+			method: payment.FakeMethodPending,
+			settle: internal.PaymentStatusFailed,
+			age:    time.Hour,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				// Failing the pending_change bundle frees the subscription
+				// for another change and keeps the current plan.
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusFailed)).Return(true, nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusPaymentFailed).Return(nil)
+				repo.EXPECT().ReleasePromoRedemption(gomock.Any(), gomock.Any(), p.BundleID).Return(nil)
+			},
+		},
+		{
+			name: "interrupted checkout fails after the timeout",
+			age:  pendingPaymentTimeout,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), paymentWithStatus(internal.PaymentStatusFailed)).Return(true, nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusPaymentFailed).Return(nil)
+				repo.EXPECT().ReleasePromoRedemption(gomock.Any(), gomock.Any(), p.BundleID).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			expectTransactions(t, repo, nil)
+
+			provider := payment.NewFakeProvider()
+			p := &internal.Payment{
+				ID:          uuid.New(),
+				UserID:      uuid.New(),
+				BundleID:    uuid.New(),
+				Provider:    provider.Name(),
+				Amount:      3000,
+				Currency:    "USD",
+				Status:      internal.PaymentStatusPending,
+				Description: "premium subscription, 1_month",
+				CreatedAt:   time.Now().Add(-tt.age),
+			}
+			if tt.method != "" {
+				result, err := provider.CreateCheckout(context.Background(), &internal.Checkout{
+					Reference:     p.ID.String(),
+					Amount:        p.Amount,
+					Currency:      p.Currency,
+					PaymentMethod: tt.method,
+				})
+				require.NoError(t, err)
+				p.ProviderPaymentID = &result.ProviderPaymentID
+				if tt.settle != "" {
+					require.NoError(t, provider.Settle(result.ProviderPaymentID, tt.settle))
+				}
+			}
+
+			repo.EXPECT().GetUnsettledPayments(gomock.Any(), provider.Name(), paymentCheckDelay).Return([]*internal.Payment{p}, nil)
+			tt.setupMock(repo, p)
+
+			svc := NewFeatureService(repo, provider, "USD", "secret", time.Hour, 499)
+			require.NoError(t, svc.ReconcilePayments(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"datingapp/internal"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// refundRetryDelay is how long a pending refund waits before it is retried,
+// leaving time for an attempt in flight to be recorded.
+const refundRetryDelay = time.Minute
+
+// maxRefundAttempts is how many times a refund is attempted before it is
+// given up as failed.
+const maxRefundAttempts = 5
+
+// requestRefund records a pending refund of up to amount of payment within
+// tx, to be made by makeRefund once tx commits. It returns nil when there is
+// nothing to refund.
+func (s *featureService) requestRefund(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment, amount int64) (*internal.Refund, error) {
+	amount = min(amount, payment.Amount-payment.RefundedAmount)
+	if amount <= 0 || payment.ProviderPaymentID == nil {
+		return nil, nil
+	}
+
+	refund := &internal.Refund{
+		PaymentID:         payment.ID,
+		ProviderPaymentID: *payment.ProviderPaymentID,
+		Amount:            amount,
+	}
+	if err := s.repo.CreateRefund(ctx, tx, refund); err != nil {
+		return nil, fmt.Errorf("create refund: %w", err)
+	}
+
+	return refund, nil
+}
+
+// makeRefund asks the provider for refund and records the outcome. The refund
+// ID is the provider's idempotency reference, so a refund that was made but
+// not recorded is not made again when retried. A refund the provider did not
+// make stays pending until it has been attempted maxRefundAttempts times. It
+// returns the refund's status.
+func (s *featureService) makeRefund(ctx context.Context, refund *internal.Refund) (string, error) {
+	_, refundErr := s.payments.Refund(ctx, refund.ProviderPaymentID, refund.Amount, refund.ID.String())
+
+	refund.Attempts++
+	refund.Status = internal.RefundStatusSucceeded
+	if refundErr != nil {
+		log.Printf("failed to refund payment %s: %v", refund.PaymentID, refundErr)
+		refund.Status = internal.RefundStatusPending
+		if refund.Attempts >= maxRefundAttempts {
+			refund.Status = internal.RefundStatusFailed
+		}
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err := s.recordRefund(ctx, tx, refund); err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return refund.Status, nil
+}
+
+// recordRefund stores the outcome of an attempt at refund and applies a
+// succeeded refund to its payment.
+func (s *featureService) recordRefund(ctx context.Context, tx *sqlx.Tx, refund *internal.Refund) error {
+	updated, err := s.repo.UpdateRefund(ctx, tx, refund)
+	if err != nil {
+		return fmt.Errorf("update refund: %w", err)
+	}
+	if !updated || refund.Status != internal.RefundStatusSucceeded {
+		return nil
+	}
+
+	payment, err := s.repo.GetPayment(ctx, tx, refund.PaymentID)
+	if err != nil {
+		return fmt.Errorf("get payment: %w", err)
+	}
+
+	_, err = s.applyRefund(ctx, tx, payment, refund.Amount, internal.InvoiceKindRefund)
+	return err
+}
+
+// applyRefund adds amount, capped at what has not been refunded yet, to the
+// refunded amount of payment and bills it as kind, a refund or chargeback. A
+// payment is refunded once its whole amount has been returned, and charged
+// back by any chargeback. It returns the amount applied.
+func (s *featureService) applyRefund(ctx context.Context, tx *sqlx.Tx, payment *internal.Payment, amount int64, kind string) (int64, error) {
+	amount = max(min(amount, payment.Amount-payment.RefundedAmount), 0)
+
+	payment.RefundedAmount += amount
+	switch {
+	case kind == internal.InvoiceKindChargeback:
+		payment.Status = internal.PaymentStatusChargedBack
+	case payment.RefundedAmount == payment.Amount:
+		payment.Status = internal.PaymentStatusRefunded
+	}
+	if err := s.repo.RecordRefund(ctx, tx, payment); err != nil {
+		return 0, fmt.Errorf("record refund: %w", err)
+	}
+	if amount == 0 {
+		return 0, nil
+	}
+
+	description := "Refund: " + payment.Description
+	if kind == internal.InvoiceKindChargeback {
+		description = "Chargeback: " + payment.Description
+	}
+	if err := s.recordPaymentInvoice(ctx, tx, payment, kind, -amount, description); err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
+
+// RetryRefunds makes the refunds left pending by an earlier attempt.
+func (s *featureService) RetryRefunds(ctx context.Context) error {
+	refunds, err := s.repo.GetPendingRefunds(ctx, refundRetryDelay)
+	if err != nil {
+		return fmt.Errorf("get pending refunds: %w", err)
+	}
+
+	for _, refund := range refunds {
+		status, err := s.makeRefund(ctx, refund)
+		if err != nil {
+			log.Printf("failed to retry refund %s: %v", refund.ID, err)
+			continue
+		}
+		if status == internal.RefundStatusFailed {
+			log.Printf("gave up refund %s after %d attempts", refund.ID, refund.Attempts)
+		}
+	}
+
+	return nil
+}
@@ -2,52 +2,238 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"datingapp/internal"
+	"datingapp/internal/auth"
+	"datingapp/internal/oidc"
 	"datingapp/internal/repository"
+	"datingapp/internal/totp"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	challengeTokenPurpose = "2fa"
+	challengeTokenTTL     = 5 * time.Minute
+	recoveryCodeCount     = 10
+)
+
 type userService struct {
-	repo      repository.Repository
-	jwtSecret []byte
+	repo       repository.Repository
+	tokens     *auth.Tokens
+	totpIssuer string
+	// dummyHash is compared against when the email is unknown so that the
+	// response time does not reveal whether an account exists.
+	dummyHash      []byte
+	oauthProviders map[string]*oidc.Provider
 }
 
-func NewUserService(repo repository.Repository, jwtSecret string) *userService {
+func NewUserService(repo repository.Repository, tokens *auth.Tokens, totpIssuer string, oauthProviders []*oidc.Provider) *userService {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(uuid.NewString()), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatalf("failed to generate dummy password hash: %v", err)
+	}
+
+	providers := make(map[string]*oidc.Provider, len(oauthProviders))
+	for _, p := range oauthProviders {
+		providers[p.Name()] = p
+	}
+
 	return &userService{
-		repo:      repo,
-		jwtSecret: []byte(jwtSecret),
+		repo:           repo,
+		tokens:         tokens,
+		totpIssuer:     totpIssuer,
+		dummyHash:      dummyHash,
+		oauthProviders: providers,
 	}
 }
 
-func (s *userService) Login(ctx context.Context, email, password string) (string, error) {
+func (s *userService) Login(ctx context.Context, email, password, ip string) (*internal.LoginResult, error) {
+	keys := []loginThrottleKey{accountThrottleKey(email), ipThrottleKey(ip)}
+	if err := s.checkLockout(ctx, keys...); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", fmt.Errorf("get user: %w", err)
+		if !errors.Is(err, internal.ErrUserNotFound) {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password)) //nolint:errcheck // only spends the comparison time
+		s.recordLoginFailure(ctx, keys...)
+		return nil, internal.ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return "", internal.ErrInvalidCredentials
+		s.recordLoginFailure(ctx, keys...)
+		return nil, internal.ErrInvalidCredentials
+	}
+
+	s.resetLoginFailures(ctx, keys[0])
+
+	if user.TOTPEnabled {
+		challengeToken, err := s.signChallengeToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return &internal.LoginResult{ChallengeToken: challengeToken}, nil
+	}
+
+	token, err := s.signAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &internal.LoginResult{Token: token}, nil
+}
+
+func (s *userService) VerifyTwoFactor(ctx context.Context, challengeToken, code string) (string, error) {
+	userID, err := s.parseChallengeToken(challengeToken)
+	if err != nil {
+		return "", err
+	}
+
+	throttleKey := twoFactorThrottleKey(userID.String())
+	if err := s.checkLockout(ctx, throttleKey); err != nil {
+		return "", err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("get user: %w", err)
+	}
+
+	if !user.TOTPEnabled || user.TOTPSecret == nil {
+		return "", internal.ErrTwoFactorNotEnrolled
+	}
+
+	valid, err := s.useTOTPCode(ctx, user, code)
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		used, err := s.useRecoveryCode(ctx, user.ID, code)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			s.recordLoginFailure(ctx, throttleKey)
+			return "", internal.ErrInvalidTwoFactorCode
+		}
+	}
+
+	s.resetLoginFailures(ctx, throttleKey)
+
+	return s.signAccessToken(user)
+}
+
+func (s *userService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (*internal.TOTPEnrollment, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	if user.TOTPEnabled {
+		return nil, internal.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate totp secret: %w", err)
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err := s.repo.UpdateUserTOTPSecret(ctx, tx, userID, secret); err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, fmt.Errorf("update totp secret: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &internal.TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.totpIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *userService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	if user.TOTPEnabled {
+		return nil, internal.ErrTwoFactorAlreadyEnabled
+	}
+
+	if user.TOTPSecret == nil {
+		return nil, internal.ErrTwoFactorNotEnrolled
+	}
+
+	valid, err := s.useTOTPCode(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, internal.ErrInvalidTwoFactorCode
 	}
 
-	claims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"email":   user.Email,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(), // 24 hours
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(s.jwtSecret)
+	tx, err := s.repo.BeginTx(ctx)
 	if err != nil {
-		return "", fmt.Errorf("sign token: %w", err)
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err := s.repo.EnableUserTOTP(ctx, tx, userID); err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, fmt.Errorf("enable totp: %w", err)
 	}
 
-	return signedToken, nil
+	if err := s.repo.ReplaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return nil, fmt.Errorf("replace recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return codes, nil
 }
 
 func (s *userService) SignUp(ctx context.Context, user *internal.User, password string) error {
@@ -79,3 +265,124 @@ func (s *userService) SignUp(ctx context.Context, user *internal.User, password
 
 	return tx.Commit()
 }
+
+// SetTimezone sets the IANA time zone the user's daily limits reset in. The
+// limits keep resetting in the zone they used until its current day ends, so
+// changing zones can't be used to get a fresh day of limits early.
+func (s *userService) SetTimezone(ctx context.Context, userID uuid.UUID, timezone string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return internal.ErrInvalidTimezone
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	return s.repo.UpdateUserTimezone(ctx, userID, timezone, usageTimezone(user, time.Now()))
+}
+
+// SetIncognito turns the user's incognito mode on or off. Turning it on needs
+// the incognito feature; turning it off does not, and takes effect on the
+// next candidate query.
+func (s *userService) SetIncognito(ctx context.Context, userID uuid.UUID, incognito bool) error {
+	if incognito {
+		if err := internal.RequireFeature(ctx, internal.FeatureIncognito); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.UpdateUserIncognito(ctx, userID, incognito)
+}
+
+func (s *userService) SetLocation(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	return s.repo.UpdateUserLocation(ctx, userID, location)
+}
+
+// SetPassport sets the location the user browses candidates from, and is
+// shown to others as visiting, instead of their real one. Setting it needs
+// the passport feature; clearing it with a nil location does not.
+func (s *userService) SetPassport(ctx context.Context, userID uuid.UUID, location *internal.Location) error {
+	if location != nil {
+		if err := internal.RequireFeature(ctx, internal.FeaturePassport); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.UpdateUserPassport(ctx, userID, location)
+}
+
+func (s *userService) signAccessToken(user *internal.User) (string, error) {
+	return s.tokens.IssueAccessToken(user.ID, user.Email)
+}
+
+// signChallengeToken issues a token that only proves the password step of a
+// two-factor login. JWTMiddleware rejects it because it carries a purpose.
+func (s *userService) signChallengeToken(user *internal.User) (string, error) {
+	return s.tokens.IssuePurposeToken(user.ID, challengeTokenPurpose, challengeTokenTTL)
+}
+
+func (s *userService) parseChallengeToken(challengeToken string) (uuid.UUID, error) {
+	claims, err := s.tokens.ParsePurposeToken(challengeToken, challengeTokenPurpose)
+	if err != nil {
+		return uuid.Nil, internal.ErrInvalidChallengeToken
+	}
+
+	return uuid.MustParse(claims.UserID), nil
+}
+
+// useTOTPCode reports whether code is a valid TOTP code of the user that was
+// not used before. Accepting a code uses up its time step and every earlier
+// one, so a replayed code is refused even within the skew window.
+func (s *userService) useTOTPCode(ctx context.Context, user *internal.User, code string) (bool, error) {
+	step, ok := totp.ValidateStep(code, *user.TOTPSecret, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	fresh, err := s.repo.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return false, fmt.Errorf("use totp step: %w", err)
+	}
+
+	return fresh, nil
+}
+
+func (s *userService) useRecoveryCode(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, tx, userID, hashRecoveryCode(code))
+	if err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return used, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("read random recovery code: %w", err)
+	}
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+	return code[:4] + "-" + code[4:], nil
+}
+
+// hashRecoveryCode normalizes a recovery code before hashing so users may
+// type it with or without the dash and in any case.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/auth"
+	mock_repository "datingapp/internal/repository/mock"
+	"datingapp/internal/totp"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUserService_VerifyTwoFactor_Replay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	user := &internal.User{ID: uuid.New(), Email: "user@example.com", TOTPSecret: &secret, TOTPEnabled: true}
+
+	repo := mock_repository.NewMockRepository(ctrl)
+	expectTransactions(t, repo, nil)
+	repo.EXPECT().GetLoginAttempt(gomock.Any(), gomock.Any()).Return(&internal.LoginAttempt{}, nil).AnyTimes()
+	repo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil).AnyTimes()
+	repo.EXPECT().ResetLoginAttempts(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	// The repository accepts each step once, like the totp_last_step update.
+	var lastStep *int64
+	repo.EXPECT().UseTOTPStep(gomock.Any(), user.ID, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, step int64) (bool, error) {
+			if lastStep != nil && *lastStep >= step {
+				return false, nil
+			}
+			lastStep = &step
+			return true, nil
+		}).Times(2)
+	repo.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any(), user.ID, gomock.Any()).Return(false, nil)
+	repo.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(&internal.LoginAttempt{FailedCount: 1}, nil)
+
+	tokens := auth.NewTokens(auth.NewHMACKeySet("secret"), "datingapp", "datingapp-api")
+	svc := NewUserService(repo, tokens, "DatingApp", nil)
+
+	challenge, err := svc.signChallengeToken(user)
+	require.NoError(t, err)
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+
+	token, err := svc.VerifyTwoFactor(context.Background(), challenge, code)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	_, err = svc.VerifyTwoFactor(context.Background(), challenge, code)
+	assert.ErrorIs(t, err, internal.ErrInvalidTwoFactorCode)
+}
+
+func TestUserService_SetIncognito_RequiresFeature(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	repo := mock_repository.NewMockRepository(ctrl)
+	repo.EXPECT().UpdateUserIncognito(gomock.Any(), userID, false).Return(nil)
+
+	svc := NewUserService(repo, nil, "DatingApp", nil)
+	ctx := internal.SetActiveFeatures(context.Background(), nil)
+
+	var required *internal.FeatureRequiredError
+	err := svc.SetIncognito(ctx, userID, true)
+	require.ErrorAs(t, err, &required)
+	assert.Equal(t, internal.FeatureIncognito, required.Feature)
+
+	// Turning it off needs no feature.
+	require.NoError(t, svc.SetIncognito(ctx, userID, false))
+}
+
+func TestUserService_SetTimezone_KeepsCurrentDay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The user just moved from UTC to a zone on another date, so their limits
+	// still reset in UTC today.
+	userID := uuid.New()
+	previous := "UTC"
+	changedAt := time.Now()
+	repo := mock_repository.NewMockRepository(ctrl)
+	repo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&internal.User{
+		ID:                userID,
+		Timezone:          "Pacific/Kiritimati",
+		PreviousTimezone:  &previous,
+		TimezoneChangedAt: &changedAt,
+	}, nil)
+	// Moving again keeps UTC until its day ends.
+	repo.EXPECT().UpdateUserTimezone(gomock.Any(), userID, "Asia/Tokyo", "UTC").Return(nil)
+
+	svc := NewUserService(repo, nil, "DatingApp", nil)
+	require.NoError(t, svc.SetTimezone(context.Background(), userID, "Asia/Tokyo"))
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"datingapp/internal"
+	"datingapp/internal/payment"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// HandlePaymentWebhook verifies and applies a payment provider event. Events
+// are recorded in the same transaction as the state change they cause, so a
+// replayed event is a no-op.
+func (s *featureService) HandlePaymentWebhook(ctx context.Context, payload []byte, signature string) error {
+	if !payment.VerifySignature(s.webhookSecret, payload, signature) {
+		return internal.ErrInvalidWebhookSignature
+	}
+
+	event := &internal.PaymentEvent{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrInvalidWebhookPayload, err)
+	}
+	if event.ProviderEventID == "" || event.Type == "" || event.ProviderPaymentID == "" {
+		return fmt.Errorf("%w: id, type and payment_id are required", internal.ErrInvalidWebhookPayload)
+	}
+	event.Provider = s.payments.Name()
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err := s.applyPaymentEvent(ctx, tx, event, payload); err != nil {
+		errRollback := tx.Rollback()
+		if errRollback != nil {
+			log.Printf("failed to rollback transaction: %v", errRollback)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *featureService) applyPaymentEvent(ctx context.Context, tx *sqlx.Tx, event *internal.PaymentEvent, payload []byte) error {
+	created, err := s.repo.CreatePaymentEvent(ctx, tx, event, payload)
+	if err != nil {
+		return fmt.Errorf("create payment event: %w", err)
+	}
+	if !created {
+		return nil
+	}
+
+	payment, err := s.repo.GetPaymentByProviderID(ctx, tx, event.Provider, event.ProviderPaymentID)
+	if err != nil {
+		return fmt.Errorf("get payment: %w", err)
+	}
+
+	var paymentStatus string
+	switch event.Type {
+	case internal.PaymentEventPaid:
+		paymentStatus = internal.PaymentStatusSucceeded
+	case internal.PaymentEventFailed:
+		paymentStatus = internal.PaymentStatusFailed
+	case internal.PaymentEventRefunded:
+		paymentStatus = internal.PaymentStatusRefunded
+	case internal.PaymentEventChargedBack:
+		paymentStatus = internal.PaymentStatusChargedBack
+	case internal.PaymentEventRenewed:
+		if payment.Status != internal.PaymentStatusSucceeded {
+			log.Printf("ignoring %s event %s for payment in status %s", event.Type, event.ProviderEventID, payment.Status)
+			return nil
+		}
+		renewed, err := s.repo.ExtendBundle(ctx, tx, payment.BundleID)
+		if err != nil {
+			return fmt.Errorf("extend bundle: %w", err)
+		}
+		if !renewed {
+			log.Printf("ignoring %s event %s for subscription that is no longer live", event.Type, event.ProviderEventID)
+			return nil
+		}
+
+		amount := event.Amount
+		if amount == 0 {
+			amount = payment.Amount
+		}
+		return s.recordPaymentInvoice(ctx, tx, payment, internal.InvoiceKindCharge, amount, "Renewal: "+payment.Description)
+	default:
+		return fmt.Errorf("%w: unknown event type %q", internal.ErrInvalidWebhookPayload, event.Type)
+	}
+
+	// Providers may deliver events out of order; ones that no longer apply to
+	// the payment are recorded but not acted on.
+	if !paymentTransitionAllowed(payment.Status, paymentStatus) {
+		log.Printf("ignoring %s event %s for payment in status %s", event.Type, event.ProviderEventID, payment.Status)
+		return nil
+	}
+
+	switch paymentStatus {
+	case internal.PaymentStatusSucceeded, internal.PaymentStatusFailed:
+		payment.Status = paymentStatus
+		_, err := s.settlePayment(ctx, tx, payment)
+		return err
+	case internal.PaymentStatusRefunded:
+		return s.applyRefundEvent(ctx, tx, event, payment)
+	default:
+		if _, err := s.applyRefund(ctx, tx, payment, eventAmount(event, payment), internal.InvoiceKindChargeback); err != nil {
+			return err
+		}
+		return s.updatePaidStatus(ctx, tx, payment, internal.FeatureStatusChargedBack)
+	}
+}
+
+// applyRefundEvent records a refund of payment reported by the provider.
+// Refunds requested by the app are matched to the event so that they are
+// recorded once, whichever of the event and the refund's own outcome comes
+// first. What the payment paid for is only revoked once the whole payment has
+// been refunded.
+func (s *featureService) applyRefundEvent(ctx context.Context, tx *sqlx.Tx, event *internal.PaymentEvent, payment *internal.Payment) error {
+	amount := eventAmount(event, payment)
+
+	refund, err := s.repo.ClaimRefund(ctx, tx, payment.ID, amount)
+	switch {
+	case errors.Is(err, internal.ErrRefundNotFound):
+		// Refunded outside the app, e.g. from the provider's dashboard.
+	case err != nil:
+		return fmt.Errorf("claim refund: %w", err)
+	case refund.Status == internal.RefundStatusSucceeded:
+		return nil
+	default:
+		refund.Status = internal.RefundStatusSucceeded
+		if _, err := s.repo.UpdateRefund(ctx, tx, refund); err != nil {
+			return fmt.Errorf("update refund: %w", err)
+		}
+	}
+
+	if _, err := s.applyRefund(ctx, tx, payment, amount, internal.InvoiceKindRefund); err != nil {
+		return err
+	}
+	if payment.Status != internal.PaymentStatusRefunded {
+		return nil
+	}
+	return s.updatePaidStatus(ctx, tx, payment, internal.FeatureStatusRefunded)
+}
+
+// eventAmount is the amount a refund or chargeback event returns, which is
+// whatever has not been refunded yet when the event does not say.
+func eventAmount(event *internal.PaymentEvent, payment *internal.Payment) int64 {
+	if event.Amount > 0 {
+		return event.Amount
+	}
+	return payment.Amount - payment.RefundedAmount
+}
+
+// paymentTransitionAllowed reports whether a payment in status from may move
+// to status to.
+func paymentTransitionAllowed(from, to string) bool {
+	switch to {
+	case internal.PaymentStatusSucceeded, internal.PaymentStatusFailed:
+		return from == internal.PaymentStatusPending || from == internal.PaymentStatusAuthorized
+	case internal.PaymentStatusRefunded:
+		return from == internal.PaymentStatusSucceeded
+	case internal.PaymentStatusChargedBack:
+		return from == internal.PaymentStatusSucceeded || from == internal.PaymentStatusRefunded
+	default:
+		return false
+	}
+}
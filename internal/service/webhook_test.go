@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"datingapp/internal"
+	"datingapp/internal/payment"
+	mock_repository "datingapp/internal/repository/mock"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPaymentTransitionAllowed(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{internal.PaymentStatusPending, internal.PaymentStatusSucceeded, true},
+		{internal.PaymentStatusAuthorized, internal.PaymentStatusFailed, true},
+		{internal.PaymentStatusFailed, internal.PaymentStatusSucceeded, false},
+		{internal.PaymentStatusSucceeded, internal.PaymentStatusFailed, false},
+		{internal.PaymentStatusSucceeded, internal.PaymentStatusRefunded, true},
+		{internal.PaymentStatusPending, internal.PaymentStatusRefunded, false},
+		{internal.PaymentStatusRefunded, internal.PaymentStatusRefunded, false},
+		{internal.PaymentStatusRefunded, internal.PaymentStatusChargedBack, true},
+		{internal.PaymentStatusChargedBack, internal.PaymentStatusChargedBack, false},
+		{internal.PaymentStatusSucceeded, internal.PaymentStatusPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.want, paymentTransitionAllowed(tt.from, tt.to))
+		})
+	}
+}
+
+func TestFeatureService_HandlePaymentWebhook(t *testing.T) {
+	const secret = "secret"
+
+	tests := []struct {
+		name         string
+		event        string
+		status       string
+		refunded     int64
+		replayed     bool
+		boost        bool
+		setupMock    func(repo *mock_repository.MockRepository, p *internal.Payment)
+		wantStatus   string
+		wantRefunded int64
+	}{
+		{
+			name:       "replayed event",
+			event:      `{"id":"evt_1","type":"payment.paid","payment_id":"fake_pay_1"}`,
+			status:     internal.PaymentStatusPending,
+			replayed:   true,
+			setupMock:  func(repo *mock_repository.MockRepository, p *internal.Payment) {},
+			wantStatus: internal.PaymentStatusPending,
+		},
+		{
+			name:   "paid",
+			event:  `{"id":"evt_1","type":"payment.paid","payment_id":"fake_pay_1"}`,
+			status: internal.PaymentStatusPending,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), p).Return(true, nil)
+				repo.EXPECT().ReplaceChangedSubscription(gomock.Any(), gomock.Any(), p.BundleID).Return(nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusActive).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindCharge, 1000)).Return(nil)
+			},
+			wantStatus: internal.PaymentStatusSucceeded,
+		},
+		{
+			name:   "paid boost starts the boost",
+			event:  `{"id":"evt_1","type":"payment.paid","payment_id":"fake_pay_1"}`,
+			status: internal.PaymentStatusPending,
+			boost:  true,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), p).Return(true, nil)
+				repo.EXPECT().UpdateBoostStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusActive).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindCharge, 1000)).Return(nil)
+			},
+			wantStatus: internal.PaymentStatusSucceeded,
+		},
+		{
+			name:       "paid after failed is ignored",
+			event:      `{"id":"evt_2","type":"payment.paid","payment_id":"fake_pay_1"}`,
+			status:     internal.PaymentStatusFailed,
+			setupMock:  func(repo *mock_repository.MockRepository, p *internal.Payment) {},
+			wantStatus: internal.PaymentStatusFailed,
+		},
+		{
+			name:   "failed releases promo code",
+			event:  `{"id":"evt_1","type":"payment.failed","payment_id":"fake_pay_1"}`,
+			status: internal.PaymentStatusPending,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().UpdatePayment(gomock.Any(), gomock.Any(), p).Return(true, nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusPaymentFailed).Return(nil)
+				repo.EXPECT().ReleasePromoRedemption(gomock.Any(), gomock.Any(), p.BundleID).Return(nil)
+			},
+			wantStatus: internal.PaymentStatusFailed,
+		},
+		{
+			name:   "partial refund keeps the subscription",
+			event:  `{"id":"evt_3","type":"payment.refunded","payment_id":"fake_pay_1","amount":300}`,
+			status: internal.PaymentStatusSucceeded,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().ClaimRefund(gomock.Any(), gomock.Any(), p.ID, int64(300)).Return(nil, internal.ErrRefundNotFound)
+				repo.EXPECT().RecordRefund(gomock.Any(), gomock.Any(), p).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindRefund, -300)).Return(nil)
+			},
+			wantStatus:   internal.PaymentStatusSucceeded,
+			wantRefunded: 300,
+		},
+		{
+			name:     "refund of the rest revokes the subscription",
+			event:    `{"id":"evt_4","type":"payment.refunded","payment_id":"fake_pay_1","amount":500}`,
+			status:   internal.PaymentStatusSucceeded,
+			refunded: 700,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().ClaimRefund(gomock.Any(), gomock.Any(), p.ID, int64(500)).Return(nil, internal.ErrRefundNotFound)
+				repo.EXPECT().RecordRefund(gomock.Any(), gomock.Any(), p).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindRefund, -300)).Return(nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusRefunded).Return(nil)
+			},
+			wantStatus:   internal.PaymentStatusRefunded,
+			wantRefunded: 1000,
+		},
+		{
+			name:     "refund already recorded",
+			event:    `{"id":"evt_5","type":"payment.refunded","payment_id":"fake_pay_1","amount":400}`,
+			status:   internal.PaymentStatusSucceeded,
+			refunded: 400,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().ClaimRefund(gomock.Any(), gomock.Any(), p.ID, int64(400)).
+					Return(&internal.Refund{ID: uuid.New(), PaymentID: p.ID, Amount: 400, Status: internal.RefundStatusSucceeded}, nil)
+			},
+			wantStatus:   internal.PaymentStatusSucceeded,
+			wantRefunded: 400,
+		},
+		{
+			name:   "refund still pending is recorded once",
+			event:  `{"id":"evt_6","type":"payment.refunded","payment_id":"fake_pay_1","amount":400}`,
+			status: internal.PaymentStatusSucceeded,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().ClaimRefund(gomock.Any(), gomock.Any(), p.ID, int64(400)).
+					Return(&internal.Refund{ID: uuid.New(), PaymentID: p.ID, Amount: 400, Status: internal.RefundStatusPending}, nil)
+				repo.EXPECT().UpdateRefund(gomock.Any(), gomock.Any(), gomock.Cond(func(refund *internal.Refund) bool {
+					return refund.Status == internal.RefundStatusSucceeded
+				})).Return(true, nil)
+				repo.EXPECT().RecordRefund(gomock.Any(), gomock.Any(), p).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindRefund, -400)).Return(nil)
+			},
+			wantStatus:   internal.PaymentStatusSucceeded,
+			wantRefunded: 400,
+		},
+		{
+			name:       "refund of refunded payment is ignored",
+			event:      `{"id":"evt_7","type":"payment.refunded","payment_id":"fake_pay_1","amount":100}`,
+			status:     internal.PaymentStatusRefunded,
+			refunded:   1000,
+			setupMock:  func(repo *mock_repository.MockRepository, p *internal.Payment) {},
+			wantStatus: internal.PaymentStatusRefunded,
+			// Nothing more can be refunded.
+			wantRefunded: 1000,
+		},
+		{
+			name:     "chargeback returns the rest",
+			event:    `{"id":"evt_8","type":"payment.charged_back","payment_id":"fake_pay_1"}`,
+			status:   internal.PaymentStatusSucceeded,
+			refunded: 300,
+			setupMock: func(repo *mock_repository.MockRepository, p *internal.Payment) {
+				repo.EXPECT().RecordRefund(gomock.Any(), gomock.Any(), p).Return(nil)
+				repo.EXPECT().CreateInvoice(gomock.Any(), gomock.Any(), invoiceOf(internal.InvoiceKindChargeback, -700)).Return(nil)
+				repo.EXPECT().UpdateBundleStatus(gomock.Any(), gomock.Any(), p.BundleID, internal.FeatureStatusChargedBack).Return(nil)
+			},
+			wantStatus:   internal.PaymentStatusChargedBack,
+			wantRefunded: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mock_repository.NewMockRepository(ctrl)
+			expectTransactions(t, repo, nil)
+
+			providerPaymentID := "fake_pay_1"
+			p := &internal.Payment{
+				ID:                uuid.New(),
+				UserID:            uuid.New(),
+				BundleID:          uuid.New(),
+				Provider:          "fake",
+				ProviderPaymentID: &providerPaymentID,
+				Amount:            1000,
+				Currency:          "USD",
+				Status:            tt.status,
+				RefundedAmount:    tt.refunded,
+				Boost:             tt.boost,
+			}
+
+			repo.EXPECT().CreatePaymentEvent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(!tt.replayed, nil)
+			tt.setupMock(repo, p)
+			repo.EXPECT().GetPaymentByProviderID(gomock.Any(), gomock.Any(), "fake", providerPaymentID).Return(p, nil).MaxTimes(1)
+
+			svc := NewFeatureService(repo, payment.NewFakeProvider(), "USD", secret, time.Hour, 499)
+			payload := []byte(tt.event)
+			err := svc.HandlePaymentWebhook(context.Background(), payload, payment.Sign(secret, payload))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, p.Status)
+			assert.Equal(t, tt.wantRefunded, p.RefundedAmount, fmt.Sprintf("refunded amount of %s", tt.name))
+		})
+	}
+}
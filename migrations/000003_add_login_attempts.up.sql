@@ -0,0 +1,10 @@
+-- Failed login tracking keyed by account (email) or client IP.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR PRIMARY KEY,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (failed_count >= 0)
+);
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_user_features_bundle_id;
+
+ALTER TABLE user_features
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS price_amount,
+    DROP COLUMN IF EXISTS bundle_id,
+    DROP COLUMN IF EXISTS plan_price_id,
+    DROP COLUMN IF EXISTS plan_id;
+
+DROP TABLE IF EXISTS plan_prices;
+DROP TABLE IF EXISTS plan_features;
+DROP TABLE IF EXISTS plans;
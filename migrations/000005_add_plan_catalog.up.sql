@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS plans (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR NOT NULL UNIQUE,
+    description TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Features bundled by a plan and the entitlement value each one grants.
+CREATE TABLE IF NOT EXISTS plan_features (
+    plan_id UUID NOT NULL REFERENCES plans(id),
+    feature_id UUID NOT NULL REFERENCES subscription_features(id),
+    value INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (plan_id, feature_id)
+);
+
+-- Amounts are in the currency's minor unit (e.g. cents).
+CREATE TABLE IF NOT EXISTS plan_prices (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    plan_id UUID NOT NULL REFERENCES plans(id),
+    period VARCHAR NOT NULL,
+    period_months INTEGER NOT NULL,
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (plan_id, period, currency),
+    CHECK (period_months > 0),
+    CHECK (amount >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_plan_features_feature_id ON plan_features(feature_id);
+CREATE INDEX IF NOT EXISTS idx_plan_prices_plan_id ON plan_prices(plan_id);
+
+-- bundle_id groups the user_features rows created by one plan purchase.
+ALTER TABLE user_features
+    ADD COLUMN IF NOT EXISTS plan_id UUID REFERENCES plans(id),
+    ADD COLUMN IF NOT EXISTS plan_price_id UUID REFERENCES plan_prices(id),
+    ADD COLUMN IF NOT EXISTS bundle_id UUID,
+    ADD COLUMN IF NOT EXISTS price_amount BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_user_features_bundle_id ON user_features(bundle_id);
+
+-- Default catalog: the unlimited daily responses plan previously granted for free.
+INSERT INTO plans (name, description) VALUES
+    ('premium', 'Unlimited daily responses');
+
+INSERT INTO plan_features (plan_id, feature_id, value)
+SELECT p.id, sf.id, 0
+FROM plans p, subscription_features sf
+WHERE p.name = 'premium'
+    AND sf.name = 'daily_responses';
+
+INSERT INTO plan_prices (plan_id, period, period_months, amount, currency)
+SELECT p.id, v.period, v.period_months, v.amount, 'USD'
+FROM plans p, (VALUES
+    ('1_month', 1, 999),
+    ('3_months', 3, 2499),
+    ('6_months', 6, 4499),
+    ('12_months', 12, 7999)
+) AS v(period, period_months, amount)
+WHERE p.name = 'premium';
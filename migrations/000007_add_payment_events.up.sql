@@ -0,0 +1,11 @@
+-- Webhook events already applied. The unique key makes replays no-ops.
+CREATE TABLE IF NOT EXISTS payment_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR NOT NULL,
+    provider_event_id VARCHAR NOT NULL,
+    event_type VARCHAR NOT NULL,
+    provider_payment_id VARCHAR NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, provider_event_id)
+);
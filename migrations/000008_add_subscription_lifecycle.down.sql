@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_user_features_status_end_date;
+DROP INDEX IF EXISTS idx_user_features_live;
+
+ALTER TABLE user_features
+    DROP COLUMN IF EXISTS grace_until,
+    DROP COLUMN IF EXISTS auto_renew;
+
+-- Keep only the latest subscription per feature so the old constraint holds.
+DELETE FROM user_features uf
+USING user_features newer
+WHERE newer.user_id = uf.user_id
+    AND newer.feature_id = uf.feature_id
+    AND newer.created_at > uf.created_at;
+
+ALTER TABLE user_features ADD CONSTRAINT user_features_user_id_feature_id_key UNIQUE (user_id, feature_id);
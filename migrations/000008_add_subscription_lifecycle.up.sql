@@ -0,0 +1,13 @@
+-- A user may hold many subscriptions to a feature over time, but only one
+-- that is live (pending payment, active or in its renewal grace period).
+ALTER TABLE user_features DROP CONSTRAINT IF EXISTS user_features_user_id_feature_id_key;
+
+ALTER TABLE user_features
+    ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS grace_until TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_features_live
+    ON user_features(user_id, feature_id)
+    WHERE status IN ('pending', 'active', 'past_due');
+
+CREATE INDEX IF NOT EXISTS idx_user_features_status_end_date ON user_features(status, end_date);
@@ -0,0 +1,11 @@
+UPDATE user_features SET status = 'active' WHERE status = 'pending_cancellation';
+UPDATE user_features SET status = 'expired' WHERE status = 'cancelled';
+
+DROP INDEX IF EXISTS idx_user_features_live;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_features_live
+    ON user_features(user_id, feature_id)
+    WHERE status IN ('pending', 'active', 'past_due');
+
+ALTER TABLE payments DROP COLUMN IF EXISTS refunded_amount;
+
+ALTER TABLE user_features DROP COLUMN IF EXISTS cancelled_at;
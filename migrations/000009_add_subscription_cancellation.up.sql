@@ -0,0 +1,9 @@
+ALTER TABLE user_features ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0;
+
+-- A subscription cancelled at period end stays live until its end date.
+DROP INDEX IF EXISTS idx_user_features_live;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_features_live
+    ON user_features(user_id, feature_id)
+    WHERE status IN ('pending', 'active', 'past_due', 'pending_cancellation');
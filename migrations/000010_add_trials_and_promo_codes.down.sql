@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_code_plans;
+DROP TABLE IF EXISTS promo_codes;
+
+DROP INDEX IF EXISTS idx_user_features_one_trial;
+
+ALTER TABLE user_features
+    DROP CONSTRAINT IF EXISTS user_features_source_check,
+    DROP COLUMN IF EXISTS source;
+
+ALTER TABLE plans DROP COLUMN IF EXISTS trial_days;
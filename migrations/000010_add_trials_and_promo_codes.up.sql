@@ -0,0 +1,50 @@
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS trial_days INTEGER NOT NULL DEFAULT 0;
+
+UPDATE plans SET trial_days = 7 WHERE name = 'premium';
+
+-- source tells purchased subscriptions from free trials. A user gets one
+-- trial per feature.
+ALTER TABLE user_features
+    ADD COLUMN IF NOT EXISTS source VARCHAR NOT NULL DEFAULT 'purchase',
+    ADD CONSTRAINT user_features_source_check CHECK (source IN ('purchase', 'trial'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_features_one_trial
+    ON user_features(user_id, feature_id)
+    WHERE source = 'trial';
+
+-- discount_value is a percentage for 'percent' codes and an amount in the
+-- currency's minor unit for 'fixed' ones. Codes without plans apply to all.
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR NOT NULL UNIQUE,
+    discount_type VARCHAR NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    discount_value BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    max_redemptions INTEGER,
+    redemption_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (discount_value > 0),
+    CHECK (discount_type <> 'percent' OR discount_value <= 100),
+    CHECK (discount_type <> 'fixed' OR currency <> '')
+);
+
+CREATE TABLE IF NOT EXISTS promo_code_plans (
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    plan_id UUID NOT NULL REFERENCES plans(id),
+    PRIMARY KEY (promo_code_id, plan_id)
+);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    bundle_id UUID NOT NULL,
+    discount_amount BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (promo_code_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_bundle_id ON promo_redemptions(bundle_id);
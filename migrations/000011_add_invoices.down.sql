@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS invoices;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS description;
@@ -0,0 +1,19 @@
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS description VARCHAR NOT NULL DEFAULT '';
+
+-- Ledger of every charge, refund and subscription change. Amounts are in the
+-- currency's minor unit: positive for charges, negative for money returned
+-- and zero for changes.
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    number BIGSERIAL NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    bundle_id UUID,
+    payment_id UUID REFERENCES payments(id),
+    kind VARCHAR NOT NULL CHECK (kind IN ('charge', 'refund', 'chargeback', 'change')),
+    description VARCHAR NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_user_id_created_at ON invoices(user_id, created_at);
@@ -0,0 +1,10 @@
+DELETE FROM user_features WHERE source = 'grant';
+
+ALTER TABLE user_features
+    DROP CONSTRAINT IF EXISTS user_features_grant_reason_check,
+    DROP CONSTRAINT IF EXISTS user_features_source_check,
+    ADD CONSTRAINT user_features_source_check CHECK (source IN ('purchase', 'trial')),
+    DROP COLUMN IF EXISTS granted_by,
+    DROP COLUMN IF EXISTS grant_reason;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Grants are features given by support staff rather than bought. granted_by
+-- is NULL for grants made from the command line.
+ALTER TABLE user_features
+    ADD COLUMN IF NOT EXISTS grant_reason TEXT,
+    ADD COLUMN IF NOT EXISTS granted_by UUID REFERENCES users(id),
+    DROP CONSTRAINT IF EXISTS user_features_source_check,
+    ADD CONSTRAINT user_features_source_check CHECK (source IN ('purchase', 'trial', 'grant')),
+    ADD CONSTRAINT user_features_grant_reason_check CHECK (source <> 'grant' OR grant_reason IS NOT NULL);
@@ -0,0 +1,2 @@
+-- timezone is an IANA zone name. Daily limits reset at midnight in it.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,10 @@
+ALTER TABLE user_features DROP CONSTRAINT IF EXISTS user_features_value_check;
+ALTER TABLE plan_features DROP CONSTRAINT IF EXISTS plan_features_value_check;
+
+UPDATE user_features SET value = 0
+WHERE value = -1
+    AND feature_id IN (SELECT id FROM subscription_features WHERE name = 'daily_responses');
+
+UPDATE plan_features SET value = 0
+WHERE value = -1
+    AND feature_id IN (SELECT id FROM subscription_features WHERE name = 'daily_responses');
@@ -0,0 +1,12 @@
+-- value is the quota a feature grants, -1 for unlimited. Daily responses were
+-- unlimited for every subscriber until now.
+UPDATE plan_features SET value = -1
+WHERE value = 0
+    AND feature_id IN (SELECT id FROM subscription_features WHERE name = 'daily_responses');
+
+UPDATE user_features SET value = -1
+WHERE value = 0
+    AND feature_id IN (SELECT id FROM subscription_features WHERE name = 'daily_responses');
+
+ALTER TABLE plan_features ADD CONSTRAINT plan_features_value_check CHECK (value >= -1);
+ALTER TABLE user_features ADD CONSTRAINT user_features_value_check CHECK (value >= -1);
@@ -0,0 +1,11 @@
+DELETE FROM plan_features
+WHERE feature_id IN (SELECT id FROM subscription_features WHERE name = 'super_likes');
+
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS weekly_usage;
+
+UPDATE profile_responses SET response_type = 'like' WHERE response_type = 'super_like';
+
+ALTER TABLE profile_responses
+    DROP CONSTRAINT IF EXISTS profile_responses_response_type_check,
+    ADD CONSTRAINT profile_responses_response_type_check CHECK (response_type IN ('like', 'pass'));
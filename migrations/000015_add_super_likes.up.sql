@@ -0,0 +1,36 @@
+ALTER TABLE profile_responses
+    DROP CONSTRAINT IF EXISTS profile_responses_response_type_check,
+    ADD CONSTRAINT profile_responses_response_type_check CHECK (response_type IN ('like', 'pass', 'super_like'));
+
+-- Super likes are limited per week, separately from daily responses.
+-- week_start is the Monday the week starts on in the user's time zone.
+CREATE TABLE IF NOT EXISTS weekly_usage (
+    user_id UUID NOT NULL REFERENCES users(id),
+    week_start DATE NOT NULL,
+    super_like_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, week_start),
+    CHECK (super_like_count >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    type VARCHAR NOT NULL CHECK (type IN ('super_like')),
+    actor_id UUID REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_created_at ON notifications(user_id, created_at DESC);
+
+INSERT INTO subscription_features (name, description) VALUES
+    ('super_likes', 'Number of super likes allowed per week')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO plan_features (plan_id, feature_id, value)
+SELECT p.id, sf.id, 5
+FROM plans p, subscription_features sf
+WHERE p.name = 'premium'
+    AND sf.name = 'super_likes'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_profile_responses_from_user_id_created_at;
+
+DELETE FROM plan_features
+WHERE feature_id IN (SELECT id FROM subscription_features WHERE name = 'rewind');
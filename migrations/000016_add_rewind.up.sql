@@ -0,0 +1,13 @@
+INSERT INTO subscription_features (name, description) VALUES
+    ('rewind', 'Undo the last response')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO plan_features (plan_id, feature_id, value)
+SELECT p.id, sf.id, -1
+FROM plans p, subscription_features sf
+WHERE p.name = 'premium'
+    AND sf.name = 'rewind'
+ON CONFLICT DO NOTHING;
+
+-- Rewind looks up the user's latest response.
+CREATE INDEX IF NOT EXISTS idx_profile_responses_from_user_id_created_at ON profile_responses(from_user_id, created_at DESC);
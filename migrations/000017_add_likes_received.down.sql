@@ -0,0 +1,2 @@
+DELETE FROM plan_features
+WHERE feature_id IN (SELECT id FROM subscription_features WHERE name = 'likes_received');
@@ -0,0 +1,10 @@
+INSERT INTO subscription_features (name, description) VALUES
+    ('likes_received', 'See who liked you')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO plan_features (plan_id, feature_id, value)
+SELECT p.id, sf.id, -1
+FROM plans p, subscription_features sf
+WHERE p.name = 'premium'
+    AND sf.name = 'likes_received'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,7 @@
+DELETE FROM plan_features
+WHERE feature_id IN (SELECT id FROM subscription_features WHERE name = 'boosts');
+
+ALTER TABLE weekly_usage DROP COLUMN IF EXISTS boost_count;
+
+DROP TABLE IF EXISTS profile_views;
+DROP TABLE IF EXISTS boosts;
@@ -0,0 +1,42 @@
+-- Boosts rank a profile higher in other users' decks for a while. A boost
+-- bought with a payment is the payment's bundle and follows its status; it
+-- starts once the payment succeeds.
+CREATE TABLE IF NOT EXISTS boosts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    source VARCHAR NOT NULL CHECK (source IN ('purchase', 'redeem')),
+    status VARCHAR NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'active', 'payment_failed', 'refunded', 'charged_back')),
+    duration_seconds INTEGER NOT NULL CHECK (duration_seconds > 0),
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_boosts_user_id_ends_at ON boosts(user_id, ends_at);
+
+-- Every time a profile is shown as a candidate, with the boost it had then.
+CREATE TABLE IF NOT EXISTS profile_views (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    viewer_id UUID NOT NULL REFERENCES users(id),
+    boost_id UUID REFERENCES boosts(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_profile_views_user_id_created_at ON profile_views(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_profile_views_boost_id ON profile_views(boost_id);
+
+ALTER TABLE weekly_usage ADD COLUMN IF NOT EXISTS boost_count INTEGER NOT NULL DEFAULT 0 CHECK (boost_count >= 0);
+
+INSERT INTO subscription_features (name, description) VALUES
+    ('boosts', 'Free profile boosts every week')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO plan_features (plan_id, feature_id, value)
+SELECT p.id, sf.id, 1
+FROM plans p, subscription_features sf
+WHERE p.name = 'premium'
+    AND sf.name = 'boosts'
+ON CONFLICT DO NOTHING;
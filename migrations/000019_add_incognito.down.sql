@@ -0,0 +1,4 @@
+DELETE FROM plan_features
+WHERE feature_id IN (SELECT id FROM subscription_features WHERE name = 'incognito');
+
+ALTER TABLE users DROP COLUMN IF EXISTS incognito;
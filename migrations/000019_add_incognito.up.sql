@@ -0,0 +1,14 @@
+-- Incognito users are only shown to users they liked, for as long as they
+-- have the incognito feature.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS incognito BOOLEAN NOT NULL DEFAULT FALSE;
+
+INSERT INTO subscription_features (name, description) VALUES
+    ('incognito', 'Only be shown to people you liked')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO plan_features (plan_id, feature_id, value)
+SELECT p.id, sf.id, -1
+FROM plans p, subscription_features sf
+WHERE p.name = 'premium'
+    AND sf.name = 'incognito'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,9 @@
+DELETE FROM plan_features
+WHERE feature_id IN (SELECT id FROM subscription_features WHERE name = 'passport');
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS passport_city,
+    DROP COLUMN IF EXISTS passport_longitude,
+    DROP COLUMN IF EXISTS passport_latitude,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
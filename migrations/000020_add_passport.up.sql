@@ -0,0 +1,19 @@
+-- A user's real location, and the passport location they browse from while
+-- they have the passport feature.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    ADD COLUMN IF NOT EXISTS passport_latitude DOUBLE PRECISION CHECK (passport_latitude BETWEEN -90 AND 90),
+    ADD COLUMN IF NOT EXISTS passport_longitude DOUBLE PRECISION CHECK (passport_longitude BETWEEN -180 AND 180),
+    ADD COLUMN IF NOT EXISTS passport_city VARCHAR;
+
+INSERT INTO subscription_features (name, description) VALUES
+    ('passport', 'Browse candidates in another city')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO plan_features (plan_id, feature_id, value)
+SELECT p.id, sf.id, -1
+FROM plans p, subscription_features sf
+WHERE p.name = 'premium'
+    AND sf.name = 'passport'
+ON CONFLICT DO NOTHING;
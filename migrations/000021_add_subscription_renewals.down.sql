@@ -0,0 +1,3 @@
+ALTER TABLE payments
+    DROP COLUMN IF EXISTS renewal,
+    DROP COLUMN IF EXISTS payment_method;
@@ -0,0 +1,6 @@
+-- The payment method of a user's latest successful payment is charged for
+-- their subscription renewals. Renewal payments extend their subscription
+-- instead of starting it.
+ALTER TABLE payments
+    ADD COLUMN IF NOT EXISTS payment_method VARCHAR,
+    ADD COLUMN IF NOT EXISTS renewal BOOLEAN NOT NULL DEFAULT FALSE;
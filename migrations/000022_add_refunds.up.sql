@@ -0,0 +1,17 @@
+-- Refunds requested from the payment provider. A refund is recorded as
+-- pending before the provider is asked for it, and stays pending until the
+-- provider confirms it, so an interrupted refund is retried instead of lost.
+-- Its ID is the provider's idempotency reference, so a retry is never
+-- refunded twice.
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_pending ON refunds(updated_at) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+-- Set once the provider's webhook for a refund we requested has been
+-- matched to it, so the webhook does not record the refund a second time.
+ALTER TABLE refunds
+    ADD COLUMN IF NOT EXISTS confirmed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_features_pending_change;
+
+ALTER TABLE user_features
+    DROP COLUMN IF EXISTS replaces_id;
@@ -0,0 +1,9 @@
+-- A plan change awaiting payment creates its bundle as pending_change, which
+-- is not live, next to the subscription it replaces once the payment
+-- succeeds. A subscription has at most one pending change.
+ALTER TABLE user_features
+    ADD COLUMN IF NOT EXISTS replaces_id UUID REFERENCES user_features(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_features_pending_change
+    ON user_features(user_id, feature_id)
+    WHERE status = 'pending_change';
@@ -0,0 +1,7 @@
+-- Boost payments pay for a boost instead of a subscription bundle, and
+-- settle the boost's status.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS boost BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE payments
+SET boost = TRUE
+WHERE bundle_id IN (SELECT id FROM boosts);
@@ -0,0 +1,3 @@
+-- The time step of the latest accepted TOTP code. Codes of that step or an
+-- earlier one are refused so an intercepted code can't be replayed.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_payments_renewal_period;
+ALTER TABLE payments DROP COLUMN IF EXISTS period_end;
@@ -0,0 +1,8 @@
+-- A renewal payment records the end date of the period it renews. Only one
+-- renewal payment may exist per subscription and period, so sweep jobs
+-- running at once on several instances can't charge a renewal twice.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS period_end TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_renewal_period
+    ON payments(bundle_id, period_end)
+    WHERE renewal = TRUE;
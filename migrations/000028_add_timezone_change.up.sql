@@ -0,0 +1,6 @@
+-- A change of time zone records the zone daily limits reset in before it.
+-- That zone still applies until the day the change was made on ends there, so
+-- changing zones can't give a fresh day of limits early.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS previous_timezone VARCHAR,
+    ADD COLUMN IF NOT EXISTS timezone_changed_at TIMESTAMP;